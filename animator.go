@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// == Animator ================
+
+// Animator is a state machine choosing between named clips, such as a character's
+// idle, run and jump animations.  The game sets parameters (e.g. speed, or a jump
+// trigger) and the Animator follows whichever Transitions they satisfy.
+// Like an Animation, it advances by Update, or by its Clock (if set) in GetImage,
+// and drives the Animations of its states itself (so their own Clocks go unused).
+type Animator struct {
+	Clock Clock // if set, GetImage advances by the time passed since it was last called
+
+	// OnEvent, if set, is called with each event of the frames the Animator shows
+	OnEvent func(AnimationEvent)
+	// Events, if set, is also sent each event.  Sends never block: events which
+	// don't fit in the channel's buffer are dropped, so give it room.
+	Events chan AnimationEvent
+
+	states      map[string]*AnimState
+	transitions []*Transition
+	current     *AnimState
+	bools       map[string]bool
+	floats      map[string]float64
+	triggers    map[string]bool
+	ticked      bool          // whether GetImage has read the Clock yet
+	lastTick    time.Duration // when GetImage last read the Clock
+}
+
+// AnimState is a named state of an Animator, showing Clip
+type AnimState struct {
+	Name string
+	Clip ImageProvider // an *Animation is played from the start whenever the state is entered
+	// Events holds the names of events fired when each frame of Clip is shown, by
+	// frame index (a Clip which isn't an *Animation only has frame 0), on top of
+	// those of the frames themselves
+	Events map[int][]string
+}
+
+// AddEvent fires an event called name whenever frame of the state's clip is shown
+func (s *AnimState) AddEvent(frame int, name string) {
+	if s.Events == nil {
+		s.Events = make(map[int][]string)
+	}
+	s.Events[frame] = append(s.Events[frame], name)
+}
+
+// AnimationEvent is an event of a frame an Animator has just shown, e.g. a
+// footstep, or a hitbox becoming active
+type AnimationEvent struct {
+	State string
+	Frame int
+	Name  string
+}
+
+// Condition is something a Transition waits for
+type Condition func(a *Animator) bool
+
+// Transition moves an Animator from one state to another, once the current clip
+// has played for ExitTime, Trigger has been set, and every Condition holds.
+type Transition struct {
+	From string // the state it leaves, or "" for any state (but To)
+	To   string
+	// ExitTime, if positive, is how many cycles of From's clip must have played
+	// first, e.g. 1 to wait for the end of a Once clip.  It only applies to clips
+	// which are *Animations.
+	ExitTime float64
+	// Trigger, if set, is a trigger parameter the Transition waits for, and resets
+	// when taken
+	Trigger    string
+	Conditions []Condition
+}
+
+// NewAnimator returns an Animator with no states, driven by Update
+func NewAnimator() *Animator {
+	return &Animator{
+		states:   make(map[string]*AnimState),
+		bools:    make(map[string]bool),
+		floats:   make(map[string]float64),
+		triggers: make(map[string]bool),
+	}
+}
+
+// AddState adds a state called name showing clip, replacing any state of that
+// name.  The first state added is the one the Animator starts in.
+func (a *Animator) AddState(name string, clip ImageProvider) *AnimState {
+	state := &AnimState{Name: name, Clip: clip}
+	if old, ok := a.states[name]; ok && a.current == old {
+		a.current = nil
+	}
+	a.states[name] = state
+	if a.current == nil {
+		a.enter(state)
+	}
+	return state
+}
+
+// AddTransition adds a transition from one state to another (or from any state,
+// if from is ""), taken when conditions all hold.  Transitions are checked in the
+// order they were added, and the first which can be taken is.
+func (a *Animator) AddTransition(from, to string, conditions ...Condition) (*Transition, error) {
+	if _, ok := a.states[from]; !ok && from != "" {
+		return nil, fmt.Errorf("animator has no state %q to transition from", from)
+	}
+	if _, ok := a.states[to]; !ok {
+		return nil, fmt.Errorf("animator has no state %q to transition to", to)
+	}
+	t := &Transition{From: from, To: to, Conditions: conditions}
+	a.transitions = append(a.transitions, t)
+	return t, nil
+}
+
+// Play switches straight to the named state, playing its clip from the start
+func (a *Animator) Play(name string) error {
+	state, ok := a.states[name]
+	if !ok {
+		return fmt.Errorf("animator has no state %q", name)
+	}
+	a.enter(state)
+	return nil
+}
+
+// GetState returns the name of the current state, or "" if there are no states
+func (a *Animator) GetState() string {
+	if a.current == nil {
+		return ""
+	}
+	return a.current.Name
+}
+
+// == Parameters ========
+
+// SetBool sets a bool parameter (which are false until set)
+func (a *Animator) SetBool(name string, value bool) {
+	a.bools[name] = value
+}
+
+func (a *Animator) GetBool(name string) bool {
+	return a.bools[name]
+}
+
+// SetFloat sets a float parameter (which are 0 until set)
+func (a *Animator) SetFloat(name string, value float64) {
+	a.floats[name] = value
+}
+
+func (a *Animator) GetFloat(name string) float64 {
+	return a.floats[name]
+}
+
+// SetTrigger sets a trigger parameter, which stays set until a Transition waiting
+// for it is taken (or ResetTrigger is called)
+func (a *Animator) SetTrigger(name string) {
+	a.triggers[name] = true
+}
+
+func (a *Animator) ResetTrigger(name string) {
+	delete(a.triggers, name)
+}
+
+// IsTrue is a Condition holding while the named bool parameter is true
+func IsTrue(name string) Condition {
+	return func(a *Animator) bool { return a.GetBool(name) }
+}
+
+// IsFalse is a Condition holding while the named bool parameter is false
+func IsFalse(name string) Condition {
+	return func(a *Animator) bool { return !a.GetBool(name) }
+}
+
+// Above is a Condition holding while the named float parameter is more than value
+func Above(name string, value float64) Condition {
+	return func(a *Animator) bool { return a.GetFloat(name) > value }
+}
+
+// Below is a Condition holding while the named float parameter is less than value
+func Below(name string, value float64) Condition {
+	return func(a *Animator) bool { return a.GetFloat(name) < value }
+}
+
+// == Playback ========
+
+// Update advances the current clip by dt, firing the events of the frames it
+// shows, then takes the first Transition that it can (at most one per Update)
+func (a *Animator) Update(dt time.Duration) {
+	if a.current == nil {
+		return
+	}
+	state := a.current
+	if anim, ok := state.Clip.(*Animation); ok {
+		anim.step(dt, func(frame int) { a.fire(state, frame) })
+	}
+	for _, t := range a.transitions {
+		if a.canTake(t) {
+			if t.Trigger != "" {
+				a.ResetTrigger(t.Trigger)
+			}
+			a.enter(a.states[t.To])
+			return
+		}
+	}
+}
+
+// canTake returns whether Transition t can be taken from the current state
+func (a *Animator) canTake(t *Transition) bool {
+	if t.From != "" && t.From != a.current.Name || t.From == "" && t.To == a.current.Name {
+		return false
+	}
+	if t.Trigger != "" && !a.triggers[t.Trigger] {
+		return false
+	}
+	if anim, ok := a.current.Clip.(*Animation); ok && t.ExitTime > 0 && anim.GetProgress() < t.ExitTime {
+		return false
+	}
+	for _, condition := range t.Conditions {
+		if !condition(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// enter switches to state, playing its clip from the start.  The events of an
+// Animation's first frame are sent when it starts playing, at the next Update.
+func (a *Animator) enter(state *AnimState) {
+	a.current = state
+	if anim, ok := state.Clip.(*Animation); ok {
+		anim.Reset()
+		return
+	}
+	a.fire(state, 0)
+}
+
+// fire sends the events of frame of state
+func (a *Animator) fire(state *AnimState, frame int) {
+	names := state.Events[frame]
+	if anim, ok := state.Clip.(*Animation); ok {
+		names = append(append([]string(nil), anim.Frames[frame].Events...), names...)
+	}
+	for _, name := range names {
+		event := AnimationEvent{state.Name, frame, name}
+		if a.OnEvent != nil {
+			a.OnEvent(event)
+		}
+		if a.Events != nil {
+			select {
+			case a.Events <- event:
+			default:
+			}
+		}
+	}
+}
+
+// GetBoxes returns the boxes of the current clip's frame, or nil if it has none
+func (a *Animator) GetBoxes() []FrameBox {
+	if a.current == nil {
+		return nil
+	}
+	if anim, ok := a.current.Clip.(*Animation); ok {
+		return anim.GetBoxes()
+	}
+	return nil
+}
+
+// GetImage returns the current clip's image, first advancing the Animator by its
+// Clock (if it has one), or nil if it has no states
+func (a *Animator) GetImage() *ebiten.Image {
+	if a.Clock != nil {
+		now := a.Clock.Now()
+		if a.ticked {
+			a.Update(now - a.lastTick)
+		}
+		a.lastTick, a.ticked = now, true
+	}
+	if a.current == nil {
+		return nil
+	}
+	if anim, ok := a.current.Clip.(*Animation); ok {
+		// not anim.GetImage, which would also advance anim by its own Clock
+		if len(anim.Frames) == 0 {
+			return nil
+		}
+		return anim.Frames[anim.GetFrameNum()].Image
+	}
+	return a.current.Clip.GetImage()
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestAnimator returns an Animator driven by Update, with an idle loop, a run
+// loop with footsteps on frames 0 and 2, and a jump played once
+func newTestAnimator(t *testing.T) *Animator {
+	a := NewAnimator()
+	a.AddState("idle", newTestAnimation(Loop, 100, 100))
+	run := a.AddState("run", newTestAnimation(Loop, 100, 100, 100, 100))
+	run.AddEvent(0, "footstep")
+	run.AddEvent(2, "footstep")
+	a.AddState("jump", newTestAnimation(Once, 100, 100))
+
+	add := func(from, to string, conditions ...Condition) *Transition {
+		transition, err := a.AddTransition(from, to, conditions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return transition
+	}
+	add("idle", "run", Above("speed", 0.1))
+	add("run", "idle", Below("speed", 0.1))
+	add("", "jump", IsTrue("grounded")).Trigger = "jump"
+	add("jump", "idle", Below("speed", 0.1)).ExitTime = 1
+	add("jump", "run", Above("speed", 0.1)).ExitTime = 1
+	return a
+}
+
+func Test_AnimatorTransitions(t *testing.T) {
+	a := newTestAnimator(t)
+	step := func(ms int) {
+		for ; ms > 0; ms -= 50 {
+			a.Update(50 * time.Millisecond)
+		}
+	}
+	expect := func(name, state string) {
+		if a.GetState() != state {
+			t.Errorf("Animator (%s): expected state %s, got %s", name, state, a.GetState())
+		}
+	}
+
+	expect("start", "idle")
+	step(200)
+	expect("no parameters set", "idle")
+	a.SetFloat("speed", 2)
+	step(50)
+	expect("speed set", "run")
+
+	a.SetTrigger("jump")
+	step(50)
+	expect("jump while airborne", "run")
+	a.SetBool("grounded", true)
+	step(50)
+	expect("jump while grounded", "jump")
+	step(150)
+	expect("before the exit time", "jump")
+	step(50)
+	expect("at the exit time", "run")
+	step(50)
+	expect("trigger used up", "run")
+
+	a.SetFloat("speed", 0)
+	a.SetTrigger("jump")
+	a.ResetTrigger("jump")
+	step(50)
+	expect("trigger reset", "idle")
+
+	if err := a.Play("jump"); err != nil || a.GetState() != "jump" {
+		t.Errorf("Animator.Play: expected state jump, got %s (%v)", a.GetState(), err)
+	}
+	if err := a.Play("swim"); err == nil {
+		t.Errorf("Animator.Play (unknown state): expected an error")
+	}
+	if _, err := a.AddTransition("swim", "idle"); err == nil {
+		t.Errorf("Animator.AddTransition (unknown state): expected an error")
+	}
+}
+
+func Test_AnimatorEvents(t *testing.T) {
+	a := newTestAnimator(t)
+	var events []AnimationEvent
+	a.OnEvent = func(e AnimationEvent) { events = append(events, e) }
+	a.Events = make(chan AnimationEvent, 1)
+
+	a.SetFloat("speed", 2)
+	a.Update(50 * time.Millisecond)  // into run, frame 0
+	a.Update(450 * time.Millisecond) // frames 1 to 3, then round to 0 and 1
+	expected := []AnimationEvent{{"run", 0, "footstep"}, {"run", 2, "footstep"}, {"run", 0, "footstep"}}
+	if len(events) != len(expected) {
+		t.Fatalf("Animator.OnEvent: expected %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Animator.OnEvent: expected %v, got %v", expected, events)
+			break
+		}
+	}
+
+	// the channel only had room for the first
+	if e := <-a.Events; e != expected[0] {
+		t.Errorf("Animator.Events: expected %v, got %v", expected[0], e)
+	}
+	select {
+	case e := <-a.Events:
+		t.Errorf("Animator.Events: expected events past the buffer dropped, got %v", e)
+	default:
+	}
+}
+
+func Test_AnimatorClock(t *testing.T) {
+	clock := &ManualClock{}
+	a := newTestAnimator(t)
+	a.Clock = clock
+	a.SetFloat("speed", 2)
+	a.GetImage()
+	clock.Advance(50 * time.Millisecond)
+	a.GetImage()
+	if a.GetState() != "run" {
+		t.Errorf("Animator (clock): expected state run, got %s", a.GetState())
+	}
+	clock.Advance(250 * time.Millisecond)
+	a.GetImage()
+	if frame := a.states["run"].Clip.(*Animation).GetFrameNum(); frame != 2 {
+		t.Errorf("Animator (clock): expected run frame 2 after 250ms, got %d", frame)
+	}
+}
+
+func Test_AnimatorFrameEvents(t *testing.T) {
+	a := newTestAnimator(t)
+	run := a.states["run"].Clip.(*Animation)
+	run.Frames[1].Events = []string{"dust"}
+	run.Frames[1].Boxes = []FrameBox{{Name: "hurtbox", Kind: Hurtbox}}
+	var events []AnimationEvent
+	a.OnEvent = func(e AnimationEvent) { events = append(events, e) }
+
+	a.SetFloat("speed", 2)
+	a.Update(50 * time.Millisecond)
+	a.Update(150 * time.Millisecond)
+	expected := []AnimationEvent{{"run", 0, "footstep"}, {"run", 1, "dust"}}
+	if len(events) != len(expected) || events[0] != expected[0] || events[1] != expected[1] {
+		t.Errorf("Animator.OnEvent (frame events): expected %v, got %v", expected, events)
+	}
+	if boxes := a.GetBoxes(); len(boxes) != 1 || boxes[0].Kind != Hurtbox {
+		t.Errorf("Animator.GetBoxes: expected the hurtbox of run frame 1, got %v", boxes)
+	}
+}
@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// Aseprite sprite sheets: https://www.aseprite.org/docs/sprite-sheet/
+// exported with JSON data, listing frames as either a hash or an array.
+// Slices become FrameBoxes (see boxKind for telling hitboxes from hurtboxes),
+// and frame tags named "@event" put that event on the frames they cover instead
+// of making a clip.
+
+// AsepriteSheet is a sprite sheet exported from Aseprite, cut into its frames
+type AsepriteSheet struct {
+	Frames []Frame               // every frame of the sprite, in order
+	Clips  map[string]*Animation // an Animation for each frame tag, by tag name
+
+	image     *ebiten.Image // the sheet image, if the AsepriteSheet loaded it
+	imagePath string
+}
+
+// GetImagePath returns the path of the sheet image file, if the AsepriteSheet
+// loaded it
+func (s *AsepriteSheet) GetImagePath() string {
+	return s.imagePath
+}
+
+// Dispose frees the sheet image, if the AsepriteSheet loaded it (the images of
+// its Frames are part of it)
+func (s *AsepriteSheet) Dispose() {
+	if s.image != nil {
+		s.image.Dispose()
+	}
+}
+
+// Animation returns an Animation of every frame of the sprite
+func (s *AsepriteSheet) Animation() *Animation {
+	return newAnimation(s.Frames)
+}
+
+// NewAsepriteSheet loads the Aseprite JSON data at path, and the sheet image it
+// names (relative to the JSON file)
+func NewAsepriteSheet(path string) (*AsepriteSheet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeAseprite(data)
+	if err != nil {
+		return nil, fmt.Errorf("aseprite %s: %v", path, err)
+	}
+	imagePath := filepath.Join(filepath.Dir(path), raw.Meta.Image)
+	img, _, err := ebitenutil.NewImageFromFile(imagePath, ebiten.FilterDefault)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := raw.sheet(img.Bounds(), cutter(img))
+	if err != nil {
+		img.Dispose()
+		return nil, fmt.Errorf("aseprite %s: %v", path, err)
+	}
+	sheet.image, sheet.imagePath = img, imagePath
+	return sheet, nil
+}
+
+// NewAsepriteSheetFromImage cuts an already loaded sheet image by its Aseprite JSON data
+func NewAsepriteSheetFromImage(img *ebiten.Image, data []byte) (*AsepriteSheet, error) {
+	raw, err := decodeAseprite(data)
+	if err != nil {
+		return nil, err
+	}
+	return raw.sheet(img.Bounds(), cutter(img))
+}
+
+// cutter returns a function cutting rectangles out of img
+func cutter(img *ebiten.Image) func(image.Rectangle) *ebiten.Image {
+	return func(r image.Rectangle) *ebiten.Image {
+		return img.SubImage(r).(*ebiten.Image)
+	}
+}
+
+// == JSON ========
+
+type asepriteJSON struct {
+	Frames json.RawMessage `json:"frames"` // hash or array of asepriteFrameJSON
+	Meta   struct {
+		Image     string              `json:"image"`
+		FrameTags []asepriteTagJSON   `json:"frameTags"`
+		Slices    []asepriteSliceJSON `json:"slices"`
+	} `json:"meta"`
+}
+
+type asepriteFrameJSON struct {
+	Frame            asepriteRectJSON `json:"frame"`
+	Rotated          bool             `json:"rotated"`
+	Trimmed          bool             `json:"trimmed"`
+	SpriteSourceSize asepriteRectJSON `json:"spriteSourceSize"`
+	Duration         int              `json:"duration"` // milliseconds
+}
+
+type asepriteRectJSON struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (r asepriteRectJSON) rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+type asepriteTagJSON struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+}
+
+type asepriteSliceJSON struct {
+	Name string `json:"name"`
+	Keys []struct {
+		Frame  int              `json:"frame"`
+		Bounds asepriteRectJSON `json:"bounds"`
+	} `json:"keys"`
+}
+
+// decodeAseprite unmarshals Aseprite JSON data
+func decodeAseprite(data []byte) (*asepriteJSON, error) {
+	var raw asepriteJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return &raw, nil
+}
+
+// frames returns the frames in the order Aseprite listed them
+func (raw *asepriteJSON) frames() ([]asepriteFrameJSON, error) {
+	var frames []asepriteFrameJSON
+	trimmed := bytes.TrimSpace(raw.Frames)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &frames)
+		return frames, err
+	}
+	// a hash keyed by file name, which a map would lose the order of
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("frames should be an array or a hash")
+	}
+	for dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		var frame asepriteFrameJSON
+		if err := dec.Decode(&frame); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// sheet cuts the frames of a sheet image with the given bounds, and builds a
+// clip for each frame tag
+func (raw *asepriteJSON) sheet(bounds image.Rectangle, cut func(image.Rectangle) *ebiten.Image) (*AsepriteSheet, error) {
+	rawFrames, err := raw.frames()
+	if err != nil {
+		return nil, err
+	}
+	if len(rawFrames) == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+	sheet := AsepriteSheet{Clips: make(map[string]*Animation)}
+	for i, f := range rawFrames {
+		r := f.Frame.rect()
+		switch {
+		case f.Rotated:
+			return nil, fmt.Errorf("frame %d is rotated, which isn't supported", i)
+		case r.Empty() || !r.In(bounds):
+			return nil, fmt.Errorf("frame %d at %v isn't inside sheet %v", i, r, bounds)
+		case f.Duration <= 0:
+			return nil, fmt.Errorf("frame %d has no duration", i)
+		}
+		frame := Frame{Image: cut(r), Duration: time.Duration(f.Duration) * time.Millisecond}
+		if f.Trimmed {
+			frame.Offset = image.Point{f.SpriteSourceSize.X, f.SpriteSourceSize.Y}
+		}
+		sheet.Frames = append(sheet.Frames, frame)
+	}
+
+	// each slice key holds from its frame until the next key
+	for _, slice := range raw.Meta.Slices {
+		for _, key := range slice.Keys {
+			var box *FrameBox
+			r := key.Bounds.rect()
+			if !r.Empty() {
+				b, err := rectBox(slice.Name, r)
+				if err != nil {
+					return nil, fmt.Errorf("slice %q: %v", slice.Name, err)
+				}
+				box = &b
+			}
+			for i := key.Frame; i >= 0 && i < len(sheet.Frames); i++ {
+				frame := &sheet.Frames[i]
+				frame.Boxes = withBox(frame.Boxes, slice.Name, box)
+				// an empty key hides the slice from its frame on
+				if box == nil {
+					delete(frame.Slices, slice.Name)
+					continue
+				}
+				if frame.Slices == nil {
+					frame.Slices = make(map[string]image.Rectangle)
+				}
+				frame.Slices[slice.Name] = r
+			}
+		}
+	}
+
+	for _, tag := range raw.Meta.FrameTags {
+		if tag.From < 0 || tag.To >= len(sheet.Frames) || tag.From > tag.To {
+			return nil, fmt.Errorf("frame tag %q covers frames %d to %d of %d", tag.Name, tag.From, tag.To, len(sheet.Frames))
+		}
+		if strings.HasPrefix(tag.Name, "@") {
+			for i := tag.From; i <= tag.To; i++ {
+				sheet.Frames[i].Events = append(sheet.Frames[i].Events, tag.Name[1:])
+			}
+		}
+	}
+
+	// clips copy the frames, so come after anything added to them
+	for _, tag := range raw.Meta.FrameTags {
+		if strings.HasPrefix(tag.Name, "@") {
+			continue
+		}
+		if _, ok := sheet.Clips[tag.Name]; ok {
+			return nil, fmt.Errorf("frame tag %q appears twice", tag.Name)
+		}
+		order, mode, err := tagOrder(tag.From, tag.To, tag.Direction)
+		if err != nil {
+			return nil, fmt.Errorf("frame tag %q: %v", tag.Name, err)
+		}
+		frames := make([]Frame, len(order))
+		for i, index := range order {
+			frames[i] = sheet.Frames[index]
+		}
+		clip := newAnimation(frames)
+		clip.Mode = mode
+		sheet.Clips[tag.Name] = clip
+	}
+	return &sheet, nil
+}
+
+// withBox returns boxes with the box called name replaced by box, or removed if
+// box is nil
+func withBox(boxes []FrameBox, name string, box *FrameBox) []FrameBox {
+	kept := boxes[:0]
+	for _, b := range boxes {
+		if b.Name != name {
+			kept = append(kept, b)
+		}
+	}
+	if box != nil {
+		kept = append(kept, *box)
+	}
+	return kept
+}
+
+// tagOrder returns the frames a tag from..to plays, in order, and how it loops
+func tagOrder(from, to int, direction string) ([]int, LoopMode, error) {
+	var forward, reverse []int
+	for i := from; i <= to; i++ {
+		forward = append(forward, i)
+		reverse = append(reverse, to-(i-from))
+	}
+	switch direction {
+	case "", "forward":
+		return forward, Loop, nil
+	case "reverse":
+		return reverse, Loop, nil
+	case "pingpong":
+		return forward, PingPong, nil
+	case "pingpong_reverse":
+		return reverse, PingPong, nil
+	}
+	return nil, Loop, fmt.Errorf("unknown direction %q", direction)
+}
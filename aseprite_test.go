@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r2"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// asepriteHash is a four frame 16x16 sprite exported as a hash, with frames listed
+// out of name order to check they keep Aseprite's order
+const asepriteHash = `{
+	"frames": {
+		"hero 2.aseprite": {"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100},
+		"hero 0.aseprite": {"frame": {"x": 16, "y": 0, "w": 16, "h": 16}, "duration": 110},
+		"hero 1.aseprite": {"frame": {"x": 32, "y": 0, "w": 16, "h": 16}, "duration": 120},
+		"hero 3.aseprite": {"frame": {"x": 48, "y": 0, "w": 12, "h": 14}, "duration": 130,
+			"trimmed": true, "spriteSourceSize": {"x": 2, "y": 1, "w": 12, "h": 14}}
+	},
+	"meta": {
+		"image": "hero.png",
+		"frameTags": [
+			{"name": "walk", "from": 0, "to": 3, "direction": "forward"},
+			{"name": "back", "from": 0, "to": 2, "direction": "reverse"},
+			{"name": "bob", "from": 0, "to": 3, "direction": "pingpong"},
+			{"name": "sway", "from": 1, "to": 3, "direction": "pingpong_reverse"},
+			{"name": "@footstep", "from": 1, "to": 1},
+			{"name": "@footstep", "from": 3, "to": 3}
+		],
+		"slices": [{"name": "hitbox", "keys": [
+			{"frame": 1, "bounds": {"x": 4, "y": 2, "w": 8, "h": 14}},
+			{"frame": 3, "bounds": {"x": 5, "y": 4, "w": 6, "h": 12}}
+		]}, {"name": "hurtbox", "keys": [
+			{"frame": 0, "bounds": {"x": 2, "y": 2, "w": 12, "h": 14}},
+			{"frame": 2, "bounds": {"x": 0, "y": 0, "w": 0, "h": 0}}
+		]}]
+	}
+}`
+
+const asepriteArray = `{
+	"frames": [
+		{"filename": "a", "frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100},
+		{"filename": "b", "frame": {"x": 16, "y": 0, "w": 16, "h": 16}, "duration": 110}
+	],
+	"meta": {"image": "hero.png"}
+}`
+
+// cutSheet decodes Aseprite data for a 64x16 sheet, cutting frames with no images
+func cutSheet(data string) (*AsepriteSheet, error) {
+	raw, err := decodeAseprite([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return raw.sheet(image.Rect(0, 0, 64, 16), func(image.Rectangle) *ebiten.Image { return nil })
+}
+
+// durations returns the durations of a's frames in milliseconds, which the tests
+// use to tell frames apart
+func durations(a *Animation) []int {
+	ms := make([]int, len(a.Frames))
+	for i, f := range a.Frames {
+		ms[i] = int(f.Duration / time.Millisecond)
+	}
+	return ms
+}
+
+func Test_AsepriteClips(t *testing.T) {
+	sheet, err := cutSheet(asepriteHash)
+	if err != nil {
+		t.Fatalf("AsepriteSheet (hash): expected no error, got %v", err)
+	}
+	cases := []struct {
+		clip     string
+		mode     LoopMode
+		expected []int
+	}{
+		{"walk", Loop, []int{100, 110, 120, 130}},
+		{"back", Loop, []int{120, 110, 100}},
+		{"bob", PingPong, []int{100, 110, 120, 130}},
+		{"sway", PingPong, []int{130, 120, 110}},
+	}
+	for _, c := range cases {
+		clip, ok := sheet.Clips[c.clip]
+		if !ok {
+			t.Errorf("AsepriteSheet.Clips (%s): expected a clip", c.clip)
+			continue
+		}
+		if clip.Mode != c.mode {
+			t.Errorf("AsepriteSheet.Clips (%s): expected mode %v, got %v", c.clip, c.mode, clip.Mode)
+		}
+		if got := durations(clip); len(got) != len(c.expected) {
+			t.Errorf("AsepriteSheet.Clips (%s): expected frames %v, got %v", c.clip, c.expected, got)
+		} else {
+			for i := range got {
+				if got[i] != c.expected[i] {
+					t.Errorf("AsepriteSheet.Clips (%s): expected frames %v, got %v", c.clip, c.expected, got)
+					break
+				}
+			}
+		}
+	}
+
+	if _, ok := sheet.Frames[0].Slices["hitbox"]; ok {
+		t.Errorf("AsepriteSheet (slices): expected no hitbox before its first key")
+	}
+	if box := sheet.Frames[2].Slices["hitbox"]; box != image.Rect(4, 2, 12, 16) {
+		t.Errorf("AsepriteSheet (slices): expected first key's hitbox on frame 2, got %v", box)
+	}
+	if box := sheet.Frames[3].Slices["hitbox"]; box != image.Rect(5, 4, 11, 16) {
+		t.Errorf("AsepriteSheet (slices): expected second key's hitbox on frame 3, got %v", box)
+	}
+	if boxes := sheet.Frames[0].Boxes; len(boxes) != 1 || boxes[0].Kind != Hurtbox {
+		t.Errorf("AsepriteSheet (boxes): expected only the hurtbox before the hitbox's first key, got %v", boxes)
+	}
+	for i := 2; i < 4; i++ {
+		if _, ok := sheet.Frames[i].Slices["hurtbox"]; ok || len(sheet.Frames[i].Boxes) != 1 {
+			t.Errorf("AsepriteSheet (empty key): expected no hurtbox slice or box on frame %d, got %v and %v", i, sheet.Frames[i].Slices, sheet.Frames[i].Boxes)
+		}
+	}
+	if boxes := sheet.Clips["walk"].Frames[3].Boxes; len(boxes) != 1 || boxes[0].Kind != Hitbox {
+		t.Errorf("AsepriteSheet (boxes): expected a hitbox on the walk clip's last frame, got %v", boxes)
+	} else if min, max := boxes[0].Shape.Bounds(); min != (r2.Point{5, 4}) || max != (r2.Point{11, 16}) {
+		t.Errorf("AsepriteSheet (boxes): expected the second key's hitbox, got (%v, %v)", min, max)
+	}
+
+	if _, ok := sheet.Clips["@footstep"]; ok {
+		t.Errorf("AsepriteSheet (events): expected no clip for an event tag")
+	}
+	for i, expected := range []int{0, 1, 0, 1} {
+		if events := sheet.Clips["walk"].Frames[i].Events; len(events) != expected || expected == 1 && events[0] != "footstep" {
+			t.Errorf("AsepriteSheet (events): expected %d footsteps on walk frame %d, got %v", expected, i, events)
+		}
+	}
+
+	if offset := sheet.Frames[3].Offset; offset != (image.Point{2, 1}) {
+		t.Errorf("AsepriteSheet (trimmed): expected offset (2, 1), got %v", offset)
+	}
+
+	array, err := cutSheet(asepriteArray)
+	if err != nil || len(array.Animation().Frames) != 2 || len(array.Clips) != 0 {
+		t.Errorf("AsepriteSheet (array): expected two frames and no clips, got %v (%v)", array, err)
+	}
+}
+
+func Test_AsepriteErrors(t *testing.T) {
+	cases := map[string]string{
+		"not json":          `{"frames": `,
+		"no frames":         `{"frames": [], "meta": {}}`,
+		"off the sheet":     `{"frames": [{"frame": {"x": 60, "y": 0, "w": 16, "h": 16}, "duration": 100}]}`,
+		"no duration":       `{"frames": [{"frame": {"x": 0, "y": 0, "w": 16, "h": 16}}]}`,
+		"rotated":           `{"frames": [{"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100, "rotated": true}]}`,
+		"tag past the end":  `{"frames": [{"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100}], "meta": {"frameTags": [{"name": "a", "from": 0, "to": 1}]}}`,
+		"unknown direction": `{"frames": [{"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100}], "meta": {"frameTags": [{"name": "a", "from": 0, "to": 0, "direction": "sideways"}]}}`,
+	}
+	for name, data := range cases {
+		if _, err := cutSheet(data); err == nil {
+			t.Errorf("AsepriteSheet (%s): expected an error", name)
+		}
+	}
+}
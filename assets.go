@@ -0,0 +1,432 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+
+	"github.com/jwlarocque/engine/tiled"
+)
+
+// == Asset Manager ========
+
+// AssetKind is the type of an asset, and so how it's loaded
+type AssetKind int
+
+const (
+	ImageAsset    AssetKind = iota // *ebiten.Image, from an image file
+	TilesetAsset                   // *tiled.Tileset, from a TSX or JSON file
+	MapAsset                       // *tiled.Map, from a TMX or JSON file
+	AsepriteAsset                  // *AsepriteSheet, from Aseprite JSON data
+)
+
+// AssetLoader loads the asset at path.  Loaders get any assets their asset is
+// made from (e.g. a map's tileset) from m, which counts them as its dependencies.
+type AssetLoader func(m *AssetManager, path string) (interface{}, error)
+
+// AssetManager loads images, tilesets, maps and Aseprite sheets, and caches them
+// by path so everything using the same file shares one copy.  Getting an asset
+// counts a reference to it, and it's unloaded once each has been given back by
+// Release.
+//
+// In development, Poll reloads assets whose files have changed.  Reloaded assets
+// are updated where they are, so pointers handed out stay good, and Animations and
+// Levels from the manager pick up the changes too.
+//
+// An AssetManager is not safe for concurrent use.
+type AssetManager struct {
+	// Loaders load each kind of asset; NewAssetManager fills it in
+	Loaders map[AssetKind]AssetLoader
+
+	assets  map[string]*asset
+	loading *asset // the asset being loaded, which collects dependencies
+}
+
+// asset is one cached asset
+type asset struct {
+	kind       AssetKind
+	path       string
+	value      interface{}
+	refs       int
+	deps       []string             // paths of the assets it was made from
+	files      map[string]time.Time // files it was loaded from, and when they were last changed
+	animations []*assetAnimation    // Animations made from an AsepriteAsset
+	levels     []*Level             // Levels made from a MapAsset
+}
+
+// assetAnimation is an Animation of a clip of an Aseprite sheet, or the whole
+// sheet if clip is ""
+type assetAnimation struct {
+	anim *Animation
+	clip string
+}
+
+// NewAssetManager returns an AssetManager loading assets with the usual constructors
+func NewAssetManager() *AssetManager {
+	return &AssetManager{
+		Loaders: map[AssetKind]AssetLoader{
+			ImageAsset:    loadImage,
+			TilesetAsset:  loadTileset,
+			MapAsset:      loadMap,
+			AsepriteAsset: loadAseprite,
+		},
+		assets: make(map[string]*asset),
+	}
+}
+
+func loadImage(m *AssetManager, path string) (interface{}, error) {
+	img, _, err := ebitenutil.NewImageFromFile(path, ebiten.FilterDefault)
+	return img, err
+}
+
+func loadTileset(m *AssetManager, path string) (interface{}, error) {
+	return tiled.LoadTileset(path)
+}
+
+func loadMap(m *AssetManager, path string) (interface{}, error) {
+	return tiled.LoadMap(path, m.Tileset)
+}
+
+func loadAseprite(m *AssetManager, path string) (interface{}, error) {
+	return NewAsepriteSheet(path)
+}
+
+// get returns the asset of the given kind at path, loading it if it isn't cached,
+// and counts a reference to it
+func (m *AssetManager) get(kind AssetKind, path string) (interface{}, error) {
+	path = filepath.Clean(path)
+	a, ok := m.assets[path]
+	if !ok {
+		a = &asset{kind: kind, path: path}
+		if err := m.load(a); err != nil {
+			return nil, err
+		}
+		m.assets[path] = a
+	} else if a.kind != kind {
+		return nil, fmt.Errorf("asset %s is already loaded as another kind", path)
+	}
+	a.refs++
+	if m.loading != nil {
+		m.loading.deps = append(m.loading.deps, path)
+	}
+	return a.value, nil
+}
+
+// load (re)loads a's value, dependencies and files, releasing the dependencies it
+// had before if it succeeds
+func (m *AssetManager) load(a *asset) error {
+	loader, ok := m.Loaders[a.kind]
+	if !ok {
+		return fmt.Errorf("asset %s: no loader for kind %d", a.path, a.kind)
+	}
+	outer, oldDeps := m.loading, a.deps
+	loading := &asset{kind: a.kind, path: a.path}
+	m.loading = loading
+	value, err := loader(m, a.path)
+	m.loading = outer
+	if err != nil {
+		m.releaseAll(loading.deps)
+		return fmt.Errorf("asset %s: %v", a.path, err)
+	}
+	if a.value != nil {
+		if err := a.replace(value); err != nil {
+			m.releaseAll(loading.deps)
+			return fmt.Errorf("asset %s: %v", a.path, err)
+		}
+	} else {
+		a.value = value
+	}
+	a.deps = loading.deps
+	m.releaseAll(oldDeps)
+
+	a.files = map[string]time.Time{a.path: modTime(a.path)}
+	var imagePath string
+	switch value := a.value.(type) {
+	case *tiled.Tileset:
+		imagePath = value.GetImagePath()
+	case *AsepriteSheet:
+		imagePath = value.GetImagePath()
+	}
+	if imagePath != "" {
+		a.files[imagePath] = modTime(imagePath)
+	}
+	return nil
+}
+
+// modTime returns when the file at path last changed, or the zero time if it
+// can't tell
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// replace updates the asset's value in place to match the newly loaded value, and
+// whatever was made from it
+func (a *asset) replace(value interface{}) error {
+	switch old := a.value.(type) {
+	case *ebiten.Image:
+		img := value.(*ebiten.Image)
+		if img.Bounds().Size() != old.Bounds().Size() {
+			img.Dispose()
+			return fmt.Errorf("image changed size from %v to %v, which needs a restart", old.Bounds().Size(), img.Bounds().Size())
+		}
+		old.Clear()
+		old.DrawImage(img, &ebiten.DrawImageOptions{})
+		img.Dispose()
+	case *tiled.Tileset:
+		old.Dispose()
+		*old = *value.(*tiled.Tileset)
+	case *tiled.Map:
+		// make the Levels first, so the old map's kept if they can't be
+		loaded := value.(*tiled.Map)
+		levels := make([]*Level, len(a.levels))
+		for i := range a.levels {
+			level, err := newLevel(loaded)
+			if err != nil {
+				loaded.Dispose()
+				return err
+			}
+			levels[i] = level
+		}
+		old.Dispose()
+		*old = *loaded
+		for i, level := range a.levels {
+			level.reload(levels[i])
+		}
+	case *AsepriteSheet:
+		old.Dispose()
+		*old = *value.(*AsepriteSheet)
+		for _, use := range a.animations {
+			src := old.Animation()
+			if use.clip != "" {
+				if src = old.Clips[use.clip]; src == nil {
+					continue // the clip's gone; leave the Animation as it was
+				}
+			}
+			use.anim.Frames = append([]Frame(nil), src.Frames...)
+			use.anim.Mode = src.Mode
+			use.anim.Reset()
+		}
+	default:
+		a.value = value
+	}
+	return nil
+}
+
+// unload frees an asset nothing uses any more
+func (m *AssetManager) unload(a *asset) {
+	delete(m.assets, a.path)
+	switch value := a.value.(type) {
+	case *ebiten.Image:
+		value.Dispose()
+	case *tiled.Tileset:
+		value.Dispose()
+	case *tiled.Map:
+		value.Dispose()
+	case *AsepriteSheet:
+		value.Dispose()
+	}
+	m.releaseAll(a.deps)
+}
+
+// Release gives back a reference to the asset at path, unloading it (and releasing
+// what it was made from) once it has none
+func (m *AssetManager) Release(path string) {
+	a, ok := m.assets[filepath.Clean(path)]
+	if !ok {
+		return
+	}
+	if a.refs--; a.refs <= 0 {
+		m.unload(a)
+	}
+}
+
+func (m *AssetManager) releaseAll(paths []string) {
+	for _, path := range paths {
+		m.Release(path)
+	}
+}
+
+// Loaded returns the paths of the loaded assets, in order
+func (m *AssetManager) Loaded() []string {
+	paths := make([]string, 0, len(m.assets))
+	for path := range m.assets {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Refs returns how many references there are to the asset at path
+func (m *AssetManager) Refs(path string) int {
+	if a, ok := m.assets[filepath.Clean(path)]; ok {
+		return a.refs
+	}
+	return 0
+}
+
+// == Getting Assets ========
+
+// Image returns the image file at path
+func (m *AssetManager) Image(path string) (*ebiten.Image, error) {
+	value, err := m.get(ImageAsset, path)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*ebiten.Image), nil
+}
+
+// Tileset returns the Tiled tileset file (.tsx or .json) at path
+func (m *AssetManager) Tileset(path string) (*tiled.Tileset, error) {
+	value, err := m.get(TilesetAsset, path)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*tiled.Tileset), nil
+}
+
+// Map returns the Tiled map file (.tmx or .json) at path.  Its tileset is shared
+// with other maps using the same one.
+func (m *AssetManager) Map(path string) (*tiled.Map, error) {
+	value, err := m.get(MapAsset, path)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*tiled.Map), nil
+}
+
+// Aseprite returns the Aseprite sheet whose JSON data is at path
+func (m *AssetManager) Aseprite(path string) (*AsepriteSheet, error) {
+	value, err := m.get(AsepriteAsset, path)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*AsepriteSheet), nil
+}
+
+// Animation returns a new Animation of the named clip of the Aseprite sheet at
+// path, or of the whole sheet if clip is "".  Animations share their frames but
+// play independently.  Give it back with ReleaseAnimation.
+func (m *AssetManager) Animation(path, clip string) (*Animation, error) {
+	sheet, err := m.Aseprite(path)
+	if err != nil {
+		return nil, err
+	}
+	src := sheet.Animation()
+	if clip != "" {
+		if src = sheet.Clips[clip]; src == nil {
+			m.Release(path)
+			return nil, fmt.Errorf("aseprite %s has no clip %q", path, clip)
+		}
+	}
+	anim := newAnimation(src.Frames)
+	anim.Mode = src.Mode
+	a := m.assets[filepath.Clean(path)]
+	a.animations = append(a.animations, &assetAnimation{anim, clip})
+	return anim, nil
+}
+
+// ReleaseAnimation gives back an Animation from the manager
+func (m *AssetManager) ReleaseAnimation(anim *Animation) {
+	for _, a := range m.assets {
+		for i, use := range a.animations {
+			if use.anim == anim {
+				a.animations = append(a.animations[:i], a.animations[i+1:]...)
+				m.Release(a.path)
+				return
+			}
+		}
+	}
+}
+
+// Level returns a new Level of the map at path, which reloads with the map (making
+// its terrain and entities anew).  Give it back with ReleaseLevel.
+func (m *AssetManager) Level(path string) (*Level, error) {
+	loaded, err := m.Map(path)
+	if err != nil {
+		return nil, err
+	}
+	level, err := newLevel(loaded)
+	if err != nil {
+		m.Release(path)
+		return nil, err
+	}
+	a := m.assets[filepath.Clean(path)]
+	a.levels = append(a.levels, level)
+	return level, nil
+}
+
+// ReleaseLevel gives back a Level from the manager
+func (m *AssetManager) ReleaseLevel(level *Level) {
+	for _, a := range m.assets {
+		for i, l := range a.levels {
+			if l == level {
+				a.levels = append(a.levels[:i], a.levels[i+1:]...)
+				m.Release(a.path)
+				return
+			}
+		}
+	}
+}
+
+// == Hot Reloading ========
+
+// Poll reloads every asset whose files have changed since it was loaded, along
+// with the assets made from them (e.g. maps using a changed tileset), and returns
+// their paths.  Call it every second or so in development.  Assets which fail to
+// reload are left as they were, and the first error is returned.
+func (m *AssetManager) Poll() ([]string, error) {
+	changed := make(map[string]bool)
+	for path, a := range m.assets {
+		for file, t := range a.files {
+			if mod := modTime(file); !mod.IsZero() && !mod.Equal(t) {
+				changed[path] = true
+			}
+		}
+	}
+	for grew := len(changed) > 0; grew; {
+		grew = false
+		for path, a := range m.assets {
+			for _, dep := range a.deps {
+				if changed[dep] && !changed[path] {
+					changed[path], grew = true, true
+				}
+			}
+		}
+	}
+
+	// what an asset is made from reloads before it
+	var reloads []*asset
+	for path := range changed {
+		reloads = append(reloads, m.assets[path])
+	}
+	sort.Slice(reloads, func(i, j int) bool {
+		if reloads[i].kind != reloads[j].kind {
+			return reloads[i].kind < reloads[j].kind
+		}
+		return reloads[i].path < reloads[j].path
+	})
+	var paths []string
+	var firstErr error
+	for _, a := range reloads {
+		if m.assets[a.path] != a {
+			continue // unloaded, because what it was made from changed
+		}
+		if err := m.load(a); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		paths = append(paths, a.path)
+	}
+	return paths, firstErr
+}
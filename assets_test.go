@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwlarocque/engine/tiled"
+)
+
+// fakeAssets returns an AssetManager whose loaders read plain text files: a
+// tileset keeps its text as property "text" of tile 0, a map is named by its
+// text and uses the tileset in the same folder, and an Aseprite sheet has a frame
+// for each character with clip "all" showing them all, and sheet image hero.png.  loads counts the loads of
+// each path.
+func fakeAssets(dir string, loads map[string]int) *AssetManager {
+	read := func(path string) (string, error) {
+		loads[filepath.Base(path)]++
+		data, err := ioutil.ReadFile(path)
+		return string(data), err
+	}
+	m := NewAssetManager()
+	m.Loaders[TilesetAsset] = func(m *AssetManager, path string) (interface{}, error) {
+		text, err := read(path)
+		return &tiled.Tileset{Properties: map[int]map[string]string{0: {"text": text}}}, err
+	}
+	m.Loaders[MapAsset] = func(m *AssetManager, path string) (interface{}, error) {
+		text, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := m.Tileset(filepath.Join(dir, "tiles.tsx"))
+		return &tiled.Map{Tileset: ts, ObjectGroups: []tiled.ObjectGroup{{Name: text}}}, err
+	}
+	m.Loaders[AsepriteAsset] = func(m *AssetManager, path string) (interface{}, error) {
+		text, err := read(path)
+		sheet := &AsepriteSheet{Frames: make([]Frame, len(text)), Clips: make(map[string]*Animation)}
+		sheet.imagePath = filepath.Join(dir, "hero.png")
+		sheet.Clips["all"] = sheet.Animation()
+		return sheet, err
+	}
+	return m
+}
+
+// writeAsset writes text to the file name in dir, marking it changed at t
+func writeAsset(t *testing.T, dir, name, text string, at time.Time) {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_AssetCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now().Add(-time.Hour)
+	for _, name := range []string{"tiles.tsx", "a.tmx", "b.tmx"} {
+		writeAsset(t, dir, name, name, start)
+	}
+	loads := make(map[string]int)
+	m := fakeAssets(dir, loads)
+	a, b := filepath.Join(dir, "a.tmx"), filepath.Join(dir, "b.tmx")
+	tiles := filepath.Join(dir, "tiles.tsx")
+
+	mapA, err := m.Map(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapB, _ := m.Map(b)
+	again, _ := m.Map(filepath.Join(dir, ".", "a.tmx"))
+	if again != mapA || loads["a.tmx"] != 1 {
+		t.Errorf("AssetManager.Map (cached): expected the same map loaded once, got %d loads", loads["a.tmx"])
+	}
+	if mapA.Tileset != mapB.Tileset || loads["tiles.tsx"] != 1 || m.Refs(tiles) != 2 {
+		t.Errorf("AssetManager.Map (shared tileset): expected one tileset with two references, got %d loads and %d references",
+			loads["tiles.tsx"], m.Refs(tiles))
+	}
+	if _, err := m.Aseprite(a); err == nil {
+		t.Errorf("AssetManager.Aseprite (a map): expected an error")
+	}
+
+	m.Release(a)
+	if m.Refs(a) != 1 || m.Refs(tiles) != 2 {
+		t.Errorf("AssetManager.Release: expected map a and the tileset kept, got %d and %d references", m.Refs(a), m.Refs(tiles))
+	}
+	m.Release(a)
+	if m.Refs(a) != 0 || m.Refs(tiles) != 1 {
+		t.Errorf("AssetManager.Release (last): expected map a unloaded and one tileset reference left, got %d and %d", m.Refs(a), m.Refs(tiles))
+	}
+	m.Release(b)
+	if loaded := m.Loaded(); len(loaded) != 0 {
+		t.Errorf("AssetManager.Release (everything): expected nothing loaded, got %v", loaded)
+	}
+	if _, err := m.Map(filepath.Join(dir, "missing.tmx")); err == nil || len(m.Loaded()) != 0 {
+		t.Errorf("AssetManager.Map (missing): expected an error and nothing left loaded, got %v and %v", err, m.Loaded())
+	}
+}
+
+func Test_AssetReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now().Add(-time.Hour)
+	writeAsset(t, dir, "tiles.tsx", "grass", start)
+	writeAsset(t, dir, "level.tmx", "cave", start)
+	writeAsset(t, dir, "hero.json", "abc", start)
+	writeAsset(t, dir, "hero.png", "", start)
+	loads := make(map[string]int)
+	m := fakeAssets(dir, loads)
+
+	level, err := m.Level(filepath.Join(dir, "level.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileset := level.Tileset
+	anim, err := m.Animation(filepath.Join(dir, "hero.json"), "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Animation(filepath.Join(dir, "hero.json"), "fly"); err == nil || m.Refs(filepath.Join(dir, "hero.json")) != 1 {
+		t.Errorf("AssetManager.Animation (missing clip): expected an error and no reference kept")
+	}
+
+	if changed, err := m.Poll(); len(changed) != 0 || err != nil {
+		t.Errorf("AssetManager.Poll (nothing changed): expected no reloads, got %v (%v)", changed, err)
+	}
+
+	// a changed tileset reloads the map using it too
+	writeAsset(t, dir, "tiles.tsx", "snow", start.Add(time.Minute))
+	writeAsset(t, dir, "hero.json", "abcd", start.Add(time.Minute))
+	changed, err := m.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(changed, " ") != strings.Join([]string{filepath.Join(dir, "tiles.tsx"), filepath.Join(dir, "level.tmx"), filepath.Join(dir, "hero.json")}, " ") {
+		t.Errorf("AssetManager.Poll: expected the tileset, then the map, then the sheet, got %v", changed)
+	}
+	if level.Tileset != tileset || tileset.Properties[0]["text"] != "snow" {
+		t.Errorf("AssetManager.Poll (tileset): expected the tileset updated in place, got %v", level.Tileset.Properties)
+	}
+	if loads["level.tmx"] != 2 || m.Refs(filepath.Join(dir, "tiles.tsx")) != 1 {
+		t.Errorf("AssetManager.Poll (map): expected the map reloaded keeping one tileset reference, got %d loads and %d references",
+			loads["level.tmx"], m.Refs(filepath.Join(dir, "tiles.tsx")))
+	}
+	if len(anim.Frames) != 4 {
+		t.Errorf("AssetManager.Poll (animation): expected the clip's four new frames, got %d", len(anim.Frames))
+	}
+
+	// a re-exported sheet image reloads the sheet
+	writeAsset(t, dir, "hero.png", "", start.Add(time.Minute))
+	changed, err = m.Poll()
+	if err != nil || len(changed) != 1 || changed[0] != filepath.Join(dir, "hero.json") || loads["hero.json"] != 3 {
+		t.Errorf("AssetManager.Poll (sheet image): expected the sheet reloaded, got %v (%v)", changed, err)
+	}
+
+	// the level picks up map changes
+	writeAsset(t, dir, "level.tmx", "castle", start.Add(2*time.Minute))
+	if _, err := m.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if level.ObjectGroups[0].Name != "castle" {
+		t.Errorf("AssetManager.Poll (level): expected the level's map reloaded, got %v", level.ObjectGroups)
+	}
+
+	m.ReleaseLevel(level)
+	m.ReleaseAnimation(anim)
+	if loaded := m.Loaded(); len(loaded) != 0 {
+		t.Errorf("AssetManager (released): expected nothing loaded, got %v", loaded)
+	}
+}
+
+func Test_AssetLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now().Add(-time.Hour)
+	writeAsset(t, dir, "tiles.tsx", "grass", start)
+	writeAsset(t, dir, "level.tmx", `<map width="1" height="1">
+	<tileset firstgid="1" source="`+filepath.Join(dir, "tiles.tsx")+`"/>
+	<layer><data encoding="csv">1</data></layer>
+</map>`, start)
+	level := filepath.Join(dir, "level.tmx")
+
+	// the real map loader, with a tileset which fails to load
+	m := fakeAssets(dir, make(map[string]int))
+	m.Loaders[MapAsset] = loadMap
+	m.Loaders[TilesetAsset] = func(m *AssetManager, path string) (interface{}, error) {
+		return nil, fmt.Errorf("bad tileset")
+	}
+	if _, err := m.Map(level); err == nil || len(m.Loaded()) != 0 {
+		t.Errorf("AssetManager.Map (bad tileset): expected an error and nothing loaded, got %v and %v", err, m.Loaded())
+	}
+
+	// a map saved half written while the game runs
+	m = fakeAssets(dir, make(map[string]int))
+	loaded, err := m.Map(level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Loaders[MapAsset] = loadMap
+	writeAsset(t, dir, "level.tmx", `<map width="1" height="1">
+	<tileset firstgid="1" sour`, start.Add(time.Minute))
+	if changed, err := m.Poll(); err == nil || len(changed) != 0 {
+		t.Errorf("AssetManager.Poll (malformed TMX): expected an error and no reloads, got %v (%v)", changed, err)
+	}
+	if !strings.HasPrefix(loaded.ObjectGroups[0].Name, "<map") || m.Refs(level) != 1 {
+		t.Errorf("AssetManager.Poll (malformed TMX): expected the map left as it was, got %v", loaded.ObjectGroups)
+	}
+}
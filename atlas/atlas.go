@@ -0,0 +1,288 @@
+// Package atlas packs many small images (sprites, animation frames, tilesets)
+// onto a few large texture pages, so drawing them doesn't keep switching
+// textures.  Packed images come back as sub-images of the pages, which draw just
+// like the images they replace.
+package atlas
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// LayoutFile is the name of the layout Atlas.Save writes alongside the pages
+const LayoutFile = "atlas.json"
+
+// Builder collects images to pack into an Atlas
+type Builder struct {
+	PageWidth, PageHeight int
+	Padding               int // transparent pixels between images, so filtering doesn't bleed
+	names                 []string
+	images                []image.Image
+}
+
+// NewBuilder returns a Builder packing onto pages of the given size
+func NewBuilder(pageWidth, pageHeight, padding int) *Builder {
+	return &Builder{PageWidth: pageWidth, PageHeight: pageHeight, Padding: padding}
+}
+
+// Add adds img to be packed under name
+func (b *Builder) Add(name string, img image.Image) error {
+	for _, other := range b.names {
+		if other == name {
+			return fmt.Errorf("atlas already has an image called %q", name)
+		}
+	}
+	b.names = append(b.names, name)
+	b.images = append(b.images, img)
+	return nil
+}
+
+// AddFile adds the image file at path, named by its path
+func (b *Builder) AddFile(path string) error {
+	img, err := decodeFile(path)
+	if err != nil {
+		return err
+	}
+	return b.Add(path, img)
+}
+
+// AddFolder adds every image file in a folder (see AddFile), and returns their
+// names in name order, e.g. the frames of an animation
+func (b *Builder) AddFolder(path string) ([]string, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		name := filepath.Join(path, f.Name())
+		if err := b.AddFile(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Build packs the images added so far and uploads the pages
+func (b *Builder) Build() (*Atlas, error) {
+	layout, err := b.layout()
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]*image.RGBA, layout.Pages)
+	for i := range pages {
+		pages[i] = image.NewRGBA(image.Rect(0, 0, layout.PageWidth, layout.PageHeight))
+	}
+	for i, name := range b.names {
+		region := layout.Regions[name]
+		img := b.images[i]
+		draw.Draw(pages[region.Page], region.Rect(), img, img.Bounds().Min, draw.Src)
+	}
+	sources := make([]image.Image, len(pages))
+	for i := range pages {
+		sources[i] = pages[i]
+	}
+	return newAtlas(layout, sources)
+}
+
+// layout packs the images added so far
+func (b *Builder) layout() (*Layout, error) {
+	sizes := make([]image.Point, len(b.images))
+	for i, img := range b.images {
+		sizes[i] = img.Bounds().Size()
+	}
+	placements, pages, err := pack(sizes, image.Point{b.PageWidth, b.PageHeight}, b.Padding)
+	if err != nil {
+		return nil, fmt.Errorf("atlas: %v", err)
+	}
+	layout := Layout{PageWidth: b.PageWidth, PageHeight: b.PageHeight, Pages: pages, Regions: make(map[string]Region)}
+	for i, p := range placements {
+		r := p.Rect
+		layout.Regions[b.names[i]] = Region{p.Page, r.Min.X, r.Min.Y, r.Dx(), r.Dy()}
+	}
+	return &layout, nil
+}
+
+// == Atlas ========
+
+// Atlas is a set of texture pages and where each packed image is on them
+type Atlas struct {
+	Layout  Layout
+	Pages   []*ebiten.Image
+	sources []image.Image // the pages' pixels, for saving
+}
+
+// Layout says where each packed image is in an Atlas
+type Layout struct {
+	PageWidth  int               `json:"pageWidth"`
+	PageHeight int               `json:"pageHeight"`
+	Pages      int               `json:"pages"`
+	Regions    map[string]Region `json:"regions"`
+}
+
+// Region is where one image is in an Atlas
+type Region struct {
+	Page int `json:"page"`
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	W    int `json:"w"`
+	H    int `json:"h"`
+}
+
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+// check returns an error if the Layout has regions off its pages
+func (l *Layout) check() error {
+	page := image.Rect(0, 0, l.PageWidth, l.PageHeight)
+	for name, region := range l.Regions {
+		if region.Page < 0 || region.Page >= l.Pages || region.Rect().Empty() || !region.Rect().In(page) {
+			return fmt.Errorf("region %q at %v on page %d isn't on one of %d %v pages", name, region.Rect(), region.Page, l.Pages, page.Size())
+		}
+	}
+	return nil
+}
+
+// newAtlas uploads the given pages
+func newAtlas(layout *Layout, sources []image.Image) (*Atlas, error) {
+	if err := layout.check(); err != nil {
+		return nil, fmt.Errorf("atlas: %v", err)
+	}
+	if len(sources) != layout.Pages {
+		return nil, fmt.Errorf("atlas: %d pages for a layout of %d", len(sources), layout.Pages)
+	}
+	a := Atlas{Layout: *layout, sources: sources}
+	for _, src := range sources {
+		page, err := ebiten.NewImageFromImage(src, ebiten.FilterDefault)
+		if err != nil {
+			return nil, err
+		}
+		a.Pages = append(a.Pages, page)
+	}
+	return &a, nil
+}
+
+// Image returns the image packed under name, as a sub-image of its page, or nil
+// if there isn't one
+func (a *Atlas) Image(name string) *ebiten.Image {
+	region, ok := a.Layout.Regions[name]
+	if !ok {
+		return nil
+	}
+	return a.Pages[region.Page].SubImage(region.Rect()).(*ebiten.Image)
+}
+
+// Names returns the names of the packed images, in order
+func (a *Atlas) Names() []string {
+	names := make([]string, 0, len(a.Layout.Regions))
+	for name := range a.Layout.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// == Saving and Loading ========
+
+// pageFile returns the name of the file Save writes page i to
+func pageFile(i int) string {
+	return fmt.Sprintf("page%d.png", i)
+}
+
+// Save writes the Atlas to the folder dir (which must exist), as its layout
+// (LayoutFile) and a PNG file for each page
+func (a *Atlas) Save(dir string) error {
+	for i, src := range a.sources {
+		if err := writePNG(filepath.Join(dir, pageFile(i)), src); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, LayoutFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return a.Layout.Write(f)
+}
+
+// Load reads an Atlas written by Save from the folder dir
+func Load(dir string) (*Atlas, error) {
+	f, err := os.Open(filepath.Join(dir, LayoutFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	layout, err := ReadLayout(f)
+	if err != nil {
+		return nil, fmt.Errorf("atlas %s: %v", dir, err)
+	}
+	sources := make([]image.Image, layout.Pages)
+	for i := range sources {
+		if sources[i], err = decodeFile(filepath.Join(dir, pageFile(i))); err != nil {
+			return nil, err
+		}
+		if sources[i].Bounds().Size() != (image.Point{layout.PageWidth, layout.PageHeight}) {
+			return nil, fmt.Errorf("atlas %s: page %d is %v, not %dx%d", dir, i, sources[i].Bounds().Size(), layout.PageWidth, layout.PageHeight)
+		}
+	}
+	return newAtlas(layout, sources)
+}
+
+// Write writes the Layout as JSON
+func (l *Layout) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(l)
+}
+
+// ReadLayout reads a Layout written by Layout.Write
+func ReadLayout(r io.Reader) (*Layout, error) {
+	var layout Layout
+	if err := json.NewDecoder(r).Decode(&layout); err != nil {
+		return nil, err
+	}
+	if err := layout.check(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package atlas
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// solid returns a w x h image of a single colour
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func Test_Atlas(t *testing.T) {
+	colors := map[string]color.RGBA{"red": {255, 0, 0, 255}, "green": {0, 255, 0, 255}, "blue": {0, 0, 255, 255}}
+	b := NewBuilder(32, 32, 1)
+	b.Add("red", solid(20, 20, colors["red"]))
+	b.Add("green", solid(8, 30, colors["green"]))
+	b.Add("blue", solid(30, 4, colors["blue"]))
+	if err := b.Add("red", solid(1, 1, colors["red"])); err == nil {
+		t.Errorf("Builder.Add (duplicate): expected an error")
+	}
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Pages) != 2 || len(a.Names()) != 3 {
+		t.Fatalf("Builder.Build: expected three images on two pages, got %v on %d", a.Names(), len(a.Pages))
+	}
+
+	dir, err := ioutil.TempDir("", "atlas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := a.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range colors {
+		region := loaded.Layout.Regions[name]
+		if region != a.Layout.Regions[name] {
+			t.Errorf("Load (%s): expected region %v, got %v", name, a.Layout.Regions[name], region)
+		}
+		r := region.Rect()
+		page := loaded.sources[region.Page]
+		if got := color.RGBAModel.Convert(page.At(r.Min.X, r.Min.Y)); got != c {
+			t.Errorf("Load (%s): expected %v at the region's corner, got %v", name, c, got)
+		}
+		if got := color.RGBAModel.Convert(page.At(r.Max.X-1, r.Max.Y-1)); got != c {
+			t.Errorf("Load (%s): expected %v at the region's far corner, got %v", name, c, got)
+		}
+		if img := loaded.Image(name); img == nil || img.Bounds() != r {
+			t.Errorf("Atlas.Image (%s): expected a sub-image at %v, got %v", name, r, img)
+		}
+	}
+	if loaded.Image("purple") != nil {
+		t.Errorf("Atlas.Image (missing): expected nil")
+	}
+}
+
+func Test_ReadLayout(t *testing.T) {
+	layout := Layout{PageWidth: 16, PageHeight: 16, Pages: 1, Regions: map[string]Region{"a": {0, 2, 2, 4, 4}}}
+	var buf bytes.Buffer
+	if err := layout.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadLayout(&buf)
+	if err != nil || read.Regions["a"] != layout.Regions["a"] {
+		t.Errorf("ReadLayout: expected %v, got %v (%v)", layout, read, err)
+	}
+
+	bad := map[string]string{
+		"not json":     `{"pages": `,
+		"off the page": `{"pageWidth": 16, "pageHeight": 16, "pages": 1, "regions": {"a": {"page": 0, "x": 10, "y": 0, "w": 8, "h": 8}}}`,
+		"missing page": `{"pageWidth": 16, "pageHeight": 16, "pages": 1, "regions": {"a": {"page": 1, "x": 0, "y": 0, "w": 8, "h": 8}}}`,
+		"empty region": `{"pageWidth": 16, "pageHeight": 16, "pages": 1, "regions": {"a": {"page": 0, "x": 0, "y": 0, "w": 0, "h": 8}}}`,
+	}
+	for name, data := range bad {
+		if _, err := ReadLayout(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("ReadLayout (%s): expected an error", name)
+		}
+	}
+}
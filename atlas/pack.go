@@ -0,0 +1,145 @@
+package atlas
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// == MaxRects Packing ========
+
+// Placement is where pack put one rectangle
+type Placement struct {
+	Page int
+	Rect image.Rectangle
+}
+
+// pack places rectangles of the given sizes on as few pages as it can manage,
+// leaving at least padding pixels between them.  Placements are in the order of
+// sizes.  It uses MaxRects with the best short side fit heuristic, placing the
+// biggest rectangles first (see Jukka Jylänki, "A Thousand Ways to Pack the Bin").
+func pack(sizes []image.Point, page image.Point, padding int) ([]Placement, int, error) {
+	if page.X <= 0 || page.Y <= 0 || padding < 0 {
+		return nil, 0, fmt.Errorf("page size %v with padding %d", page, padding)
+	}
+	order := make([]int, len(sizes))
+	for i, size := range sizes {
+		if size.X <= 0 || size.Y <= 0 || size.X > page.X || size.Y > page.Y {
+			return nil, 0, fmt.Errorf("image %d of size %v doesn't fit on a %v page", i, size, page)
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := sizes[order[i]], sizes[order[j]]
+		if longA, longB := max(a.X, a.Y), max(b.X, b.Y); longA != longB {
+			return longA > longB
+		}
+		return a.X*a.Y > b.X*b.Y
+	})
+
+	// padding goes to the right of and below each rectangle, so the page grows by
+	// padding to take the last ones
+	pad := image.Point{padding, padding}
+	var pages []*maxRects
+	placements := make([]Placement, len(sizes))
+	for _, i := range order {
+		size := sizes[i].Add(pad)
+		var r image.Rectangle
+		on := -1
+		for p := range pages {
+			if placed, ok := pages[p].insert(size); ok {
+				r, on = placed, p
+				break
+			}
+		}
+		if on < 0 {
+			pages = append(pages, newMaxRects(page.Add(pad)))
+			on = len(pages) - 1
+			r, _ = pages[on].insert(size) // fits on an empty page, as checked above
+		}
+		placements[i] = Placement{on, image.Rectangle{r.Min, r.Max.Sub(pad)}}
+	}
+	return placements, len(pages), nil
+}
+
+// maxRects tracks the free space of a page as the (overlapping) maximal
+// rectangles which fit in it
+type maxRects struct {
+	free []image.Rectangle
+}
+
+func newMaxRects(size image.Point) *maxRects {
+	return &maxRects{free: []image.Rectangle{{image.Point{}, size}}}
+}
+
+// insert places a rectangle of the given size where it leaves the least space on
+// its shorter side, and returns where, or false if it doesn't fit
+func (m *maxRects) insert(size image.Point) (image.Rectangle, bool) {
+	best, bestShort, bestLong, found := image.Rectangle{}, 0, 0, false
+	for _, free := range m.free {
+		if size.X > free.Dx() || size.Y > free.Dy() {
+			continue
+		}
+		leftX, leftY := free.Dx()-size.X, free.Dy()-size.Y
+		short, long := min(leftX, leftY), max(leftX, leftY)
+		if !found || short < bestShort || short == bestShort && long < bestLong {
+			best, bestShort, bestLong, found = image.Rectangle{free.Min, free.Min.Add(size)}, short, long, true
+		}
+	}
+	if found {
+		m.place(best)
+	}
+	return best, found
+}
+
+// place takes used out of the free rectangles
+func (m *maxRects) place(used image.Rectangle) {
+	var free []image.Rectangle
+	for _, r := range m.free {
+		if !r.Overlaps(used) {
+			free = append(free, r)
+			continue
+		}
+		// the parts of r on each side of used
+		if used.Min.X > r.Min.X {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, used.Min.X, r.Max.Y))
+		}
+		if used.Max.X < r.Max.X {
+			free = append(free, image.Rect(used.Max.X, r.Min.Y, r.Max.X, r.Max.Y))
+		}
+		if used.Min.Y > r.Min.Y {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, r.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < r.Max.Y {
+			free = append(free, image.Rect(r.Min.X, used.Max.Y, r.Max.X, r.Max.Y))
+		}
+	}
+	// drop rectangles inside others
+	m.free = nil
+	for i, r := range free {
+		contained := false
+		for j, other := range free {
+			if i != j && r.In(other) && (r != other || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			m.free = append(m.free, r)
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
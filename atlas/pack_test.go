@@ -0,0 +1,78 @@
+package atlas
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// checkPlacements reports placements which don't match sizes, leave their page,
+// or come within padding of each other
+func checkPlacements(t *testing.T, name string, sizes []image.Point, placements []Placement, pages int, page image.Point, padding int) {
+	bounds := image.Rectangle{Max: page}
+	for i, p := range placements {
+		if p.Rect.Size() != sizes[i] || !p.Rect.In(bounds) || p.Page < 0 || p.Page >= pages {
+			t.Errorf("pack (%s): expected image %d of size %v on one of %d pages, got %v on page %d", name, i, sizes[i], pages, p.Rect, p.Page)
+		}
+		pad := image.Point{padding, padding}
+		for j := i + 1; j < len(placements); j++ {
+			q := placements[j]
+			if p.Page == q.Page && (image.Rectangle{p.Rect.Min, p.Rect.Max.Add(pad)}.Overlaps(q.Rect) || image.Rectangle{q.Rect.Min, q.Rect.Max.Add(pad)}.Overlaps(p.Rect)) {
+				t.Errorf("pack (%s): expected images %d at %v and %d at %v at least %d apart", name, i, p.Rect, j, q.Rect, padding)
+			}
+		}
+	}
+}
+
+func Test_Pack(t *testing.T) {
+	page := image.Point{64, 64}
+	cases := []struct {
+		name    string
+		sizes   []image.Point
+		padding int
+		pages   int
+	}{
+		{"nothing", nil, 0, 0},
+		{"one", []image.Point{{16, 16}}, 0, 1},
+		{"exactly full", []image.Point{{32, 32}, {32, 32}, {32, 32}, {32, 32}}, 0, 1},
+		{"padding spills over", []image.Point{{32, 32}, {32, 32}, {32, 32}, {32, 32}}, 1, 4},
+		{"padding fits", []image.Point{{31, 31}, {32, 32}, {31, 32}, {32, 31}}, 1, 1},
+		{"whole page each", []image.Point{{64, 64}, {64, 64}}, 2, 2},
+		{"strips and squares", []image.Point{{64, 8}, {8, 56}, {16, 16}, {40, 40}, {16, 16}, {16, 24}}, 0, 1},
+	}
+	for _, c := range cases {
+		placements, pages, err := pack(c.sizes, page, c.padding)
+		if err != nil {
+			t.Errorf("pack (%s): expected no error, got %v", c.name, err)
+			continue
+		}
+		if pages != c.pages {
+			t.Errorf("pack (%s): expected %d pages, got %d", c.name, c.pages, pages)
+		}
+		checkPlacements(t, c.name, c.sizes, placements, pages, page, c.padding)
+	}
+
+	// lots of random sprites pack without overlapping, mostly filling the pages
+	r := rand.New(rand.NewSource(1))
+	sizes := make([]image.Point, 300)
+	area := 0
+	for i := range sizes {
+		sizes[i] = image.Point{1 + r.Intn(24), 1 + r.Intn(24)}
+		area += sizes[i].X * sizes[i].Y
+	}
+	placements, pages, err := pack(sizes, page, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlacements(t, "random", sizes, placements, pages, page, 0)
+	if used := float64(area) / float64(pages*page.X*page.Y); used < 0.75 {
+		t.Errorf("pack (random): expected pages at least 75%% used, got %.0f%% of %d pages", used*100, pages)
+	}
+
+	bad := map[string][]image.Point{"too wide": {{65, 1}}, "empty": {{0, 4}}}
+	for name, sizes := range bad {
+		if _, _, err := pack(sizes, page, 0); err == nil {
+			t.Errorf("pack (%s): expected an error", name)
+		}
+	}
+}
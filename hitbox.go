@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+	"github.com/jwlarocque/engine/tiled"
+)
+
+// == Frame Boxes ========
+
+// BoxKind is what a FrameBox is for
+type BoxKind int
+
+const (
+	OtherBox BoxKind = iota
+	Hitbox           // deals damage, e.g. the blade of a sword during a swing
+	Hurtbox          // takes damage
+)
+
+// boxKind tells the kind of a box from its name: names starting "hit" are
+// Hitboxes and names starting "hurt" are Hurtboxes (in any case)
+func boxKind(name string) BoxKind {
+	switch name = strings.ToLower(name); {
+	case strings.HasPrefix(name, "hit"):
+		return Hitbox
+	case strings.HasPrefix(name, "hurt"):
+		return Hurtbox
+	}
+	return OtherBox
+}
+
+// FrameBox is a named collider shape attached to a Frame, active while the frame
+// is shown
+type FrameBox struct {
+	Name string
+	Kind BoxKind
+	// Shape is in pixels relative to the top left corner of the whole (untrimmed)
+	// frame.  It's shared by every Animation made from the same frames, so use
+	// Place rather than moving it.
+	Shape *mech.PolyCollider
+}
+
+// Place returns a copy of the box's Shape placed by t, e.g. where the frame is
+// drawn, to test against other colliders
+func (b FrameBox) Place(t mech.Transform) *mech.PolyCollider {
+	coll, _ := mech.NewPolyCollider(b.Shape.Vertices) // already a valid shape
+	coll.Transform = t
+	return coll
+}
+
+// newFrameBox returns a FrameBox called name with the given outline
+func newFrameBox(name string, outline []r2.Point) (FrameBox, error) {
+	vertices := make([]*r2.Point, len(outline))
+	for i := range outline {
+		vertices[i] = &outline[i]
+	}
+	shape, err := mech.NewPolyCollider(vertices)
+	if err != nil {
+		return FrameBox{}, fmt.Errorf("box %q: %v", name, err)
+	}
+	return FrameBox{name, boxKind(name), shape}, nil
+}
+
+// rectBox returns a FrameBox called name covering r
+func rectBox(name string, r image.Rectangle) (FrameBox, error) {
+	min, max := r2.Point{float64(r.Min.X), float64(r.Min.Y)}, r2.Point{float64(r.Max.X), float64(r.Max.Y)}
+	return newFrameBox(name, []r2.Point{min, {max.X, min.Y}, max, {min.X, max.Y}})
+}
+
+// objectBoxes returns a FrameBox for each rectangle or polygon among objects
+// (e.g. a tile's collision objects), named by the object's name, or else its type
+func objectBoxes(objects []tiled.Object) ([]FrameBox, error) {
+	var boxes []FrameBox
+	for i := range objects {
+		obj := &objects[i]
+		outline := obj.Outline()
+		if outline == nil {
+			continue
+		}
+		name := obj.Name
+		if name == "" {
+			name = obj.Type
+		}
+		box, err := newFrameBox(name, outline)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %v", obj.ID, err)
+		}
+		boxes = append(boxes, box)
+	}
+	return boxes, nil
+}
+
+// splitEvents splits a comma separated list of event names
+func splitEvents(list string) []string {
+	var events []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			events = append(events, name)
+		}
+	}
+	return events
+}
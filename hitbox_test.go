@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+	"github.com/jwlarocque/engine/tiled"
+)
+
+func Test_BoxKind(t *testing.T) {
+	cases := map[string]BoxKind{"hitbox": Hitbox, "HitBlade": Hitbox, "hurtbox": Hurtbox, "Hurt head": Hurtbox, "pivot": OtherBox, "": OtherBox}
+	for name, expected := range cases {
+		if kind := boxKind(name); kind != expected {
+			t.Errorf("boxKind (%q): expected %v, got %v", name, expected, kind)
+		}
+	}
+}
+
+func Test_ObjectBoxes(t *testing.T) {
+	objects := []tiled.Object{
+		{ID: 1, Name: "hurtbox", Position: r2.Point{2, 3}, Width: 10, Height: 12},
+		{ID: 2, Type: "hitbox", Position: r2.Point{8, 4}, Polygon: []r2.Point{{0, 0}, {6, 0}, {6, 4}}},
+		{ID: 3, Name: "path", Polyline: []r2.Point{{0, 0}, {4, 4}}},
+		{ID: 4, Name: "spawn", Position: r2.Point{1, 1}},
+	}
+	boxes, err := objectBoxes(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 || boxes[0].Kind != Hurtbox || boxes[1].Kind != Hitbox || boxes[1].Name != "hitbox" {
+		t.Fatalf("objectBoxes: expected a hurtbox then a hitbox, got %v", boxes)
+	}
+	if min, max := boxes[0].Shape.Bounds(); min != (r2.Point{2, 3}) || max != (r2.Point{12, 15}) {
+		t.Errorf("objectBoxes (rectangle): expected (2, 3) to (12, 15), got (%v, %v)", min, max)
+	}
+
+	concave := []tiled.Object{{ID: 5, Name: "hitbox", Polygon: []r2.Point{{0, 0}, {4, 0}, {2, 1}, {4, 4}, {0, 4}}}}
+	if _, err := objectBoxes(concave); err == nil {
+		t.Errorf("objectBoxes (concave): expected an error")
+	}
+}
+
+func Test_FrameBoxPlace(t *testing.T) {
+	box, err := newFrameBox("hitbox", []r2.Point{{0, 0}, {4, 0}, {4, 2}, {0, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	placed := box.Place(mech.Transform{Position: r2.Point{10, 20}, Orientation: mech.Orientation{HorizFlip: true}})
+	if min, max := placed.Bounds(); min != (r2.Point{6, 20}) || max != (r2.Point{10, 22}) {
+		t.Errorf("FrameBox.Place: expected (6, 20) to (10, 22), got (%v, %v)", min, max)
+	}
+	if min, _ := box.Shape.Bounds(); min != (r2.Point{0, 0}) {
+		t.Errorf("FrameBox.Place: expected the Shape left where it was, got it at %v", min)
+	}
+}
@@ -1,12 +1,17 @@
 package engine
 
 import (
+	"fmt"
+	"image"
 	"io/ioutil"
 	"log"
 	"time"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
+
+	"github.com/jwlarocque/engine/atlas"
+	"github.com/jwlarocque/engine/tiled"
 )
 
 type ImageProvider interface {
@@ -15,33 +20,370 @@ type ImageProvider interface {
 
 // == Animation ================
 
+// Frame is one image of an Animation, and how long it's shown for
+type Frame struct {
+	Image    *ebiten.Image
+	Duration time.Duration
+	Offset   image.Point // where Image goes within the whole frame, if it was trimmed
+	// Slices holds named regions of the frame (as marked in Aseprite), relative
+	// to its top left corner
+	Slices map[string]image.Rectangle
+	Events []string   // names of events fired when the frame is shown, e.g. "footstep"
+	Boxes  []FrameBox // hitboxes, hurtboxes and the like, active while the frame is shown
+}
+
+// LoopMode is what an Animation does after its last frame
+type LoopMode int
+
+const (
+	Loop     LoopMode = iota // start again from the first frame
+	Once                     // stop on the last frame
+	PingPong                 // play backwards to the first frame, then forwards again
+)
+
+// Clock tells the time to Animations which advance themselves (see Animation.Clock)
+type Clock interface {
+	Now() time.Duration
+}
+
+// ManualClock is a Clock which only moves when told to, e.g. once per game update.
+// Sharing one between animations keeps them in step with the game and each
+// other, and makes playback the same in replays and tests.
+type ManualClock struct {
+	T time.Duration
+}
+
+func (c *ManualClock) Now() time.Duration {
+	return c.T
+}
+
+// Advance moves the clock on by dt
+func (c *ManualClock) Advance(dt time.Duration) {
+	c.T += dt
+}
+
+type wallClock struct{}
+
+var startTime = time.Now()
+
+func (wallClock) Now() time.Duration {
+	return time.Since(startTime)
+}
+
+// WallClock is a Clock keeping real time, for Animations which should simply
+// play while they're drawn (at the cost of depending on the frame rate)
+var WallClock Clock = wallClock{}
+
+// Animation plays Frames in turn, each for its Duration.  It advances by Update,
+// or, if given a Clock, by itself in GetImage (but shouldn't do both).  Without a
+// Clock, which is how constructors leave it, playback only depends on the updates
+// it's given.
 type Animation struct {
-	frameNum      int
-	frameDuration time.Duration
-	lastFrameTime time.Time
-	Images        []*ebiten.Image
+	Frames []Frame
+	Mode   LoopMode
+	Speed  float64 // how fast time passes for the Animation; 0 (unset) plays at 1
+	Paused bool
+	Clock  Clock // if set, GetImage advances by the time passed since it was last called
+
+	OnLoop   func() // if set, called whenever a Loop or PingPong Animation starts over
+	OnFinish func() // if set, called when a Once Animation has shown its last frame
+	// OnEvent, if set, is called with each of the Events of the frames the
+	// Animation shows as it plays (including the frame it starts from)
+	OnEvent func(event string)
+
+	pos      int           // position in the cycle (see frameAt)
+	elapsed  time.Duration // time shown at pos
+	cycles   int           // cycles played since the start
+	finished bool
+	started  bool          // whether the current frame has been announced since Seek
+	ticked   bool          // whether GetImage has read the Clock yet
+	lastTick time.Duration // when GetImage last read the Clock
 }
 
+// newAnimation returns an Animation of (a copy of) frames, driven by Update
+func newAnimation(frames []Frame) *Animation {
+	return &Animation{Frames: append([]Frame(nil), frames...), Speed: 1}
+}
+
+// GetImage returns the image of the current frame, first advancing the Animation
+// by its Clock (if it has one), or nil if it has no frames
 func (a *Animation) GetImage() *ebiten.Image {
-	// TODO: global frame counter
-	if time.Since(a.lastFrameTime) > a.frameDuration {
-		a.lastFrameTime = time.Now()
-		a.frameNum = (a.frameNum + 1) % len(a.Images)
+	if a.Clock != nil {
+		now := a.Clock.Now()
+		if a.ticked {
+			a.Update(now - a.lastTick)
+		}
+		a.lastTick, a.ticked = now, true
+	}
+	if len(a.Frames) == 0 {
+		return nil
+	}
+	return a.Frames[a.GetFrameNum()].Image
+}
+
+// GetFrameNum returns the index in Frames of the current frame
+func (a *Animation) GetFrameNum() int {
+	return a.frameAt(a.pos)
+}
+
+// Finished returns whether a Once Animation has shown its last frame
+func (a *Animation) Finished() bool {
+	return a.finished
+}
+
+// cycleLen returns the number of frames shown before the Animation starts over
+// (PingPong shows each end once per cycle)
+func (a *Animation) cycleLen() int {
+	n := len(a.Frames)
+	if a.Mode == PingPong && n > 2 {
+		return 2*n - 2
+	}
+	return n
+}
+
+// frameAt returns the index in Frames of the frame shown at pos in the cycle
+func (a *Animation) frameAt(pos int) int {
+	if n := len(a.Frames); pos >= n {
+		return 2*(n-1) - pos
+	}
+	return pos
+}
+
+// cycleDuration returns how long one cycle lasts
+func (a *Animation) cycleDuration() time.Duration {
+	var total time.Duration
+	for pos := 0; pos < a.cycleLen(); pos++ {
+		total += a.Frames[a.frameAt(pos)].Duration
+	}
+	return total
+}
+
+// Update advances the Animation by dt (scaled by Speed), through as many frames
+// as that takes, calling OnLoop and OnFinish as it goes.  It does nothing while Paused.
+func (a *Animation) Update(dt time.Duration) {
+	a.step(dt, nil)
+}
+
+// step is Update, also calling entered (if set) with each frame it shows
+func (a *Animation) step(dt time.Duration, entered func(frame int)) {
+	if a.Paused || a.finished || dt <= 0 || len(a.Frames) == 0 || a.cycleDuration() <= 0 {
+		return
+	}
+	if !a.started {
+		a.started = true
+		a.announce(entered)
+	}
+	speed := a.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	a.elapsed += time.Duration(float64(dt) * speed)
+	for a.elapsed >= a.Frames[a.GetFrameNum()].Duration {
+		a.elapsed -= a.Frames[a.GetFrameNum()].Duration
+		switch {
+		case a.pos < a.cycleLen()-1:
+			a.pos++
+		case a.Mode == Once:
+			a.elapsed, a.finished = 0, true
+			if a.OnFinish != nil {
+				a.OnFinish()
+			}
+			return
+		default:
+			a.pos = 0
+			a.cycles++
+			if a.OnLoop != nil {
+				a.OnLoop()
+			}
+		}
+		a.announce(entered)
+	}
+}
+
+// announce calls OnEvent with the current frame's Events, and entered with the frame
+func (a *Animation) announce(entered func(frame int)) {
+	frame := a.GetFrameNum()
+	if a.OnEvent != nil {
+		for _, event := range a.Frames[frame].Events {
+			a.OnEvent(event)
+		}
+	}
+	if entered != nil {
+		entered(frame)
 	}
-	return a.Images[a.frameNum]
 }
 
-// NewAnimationFromSheet returns an Animation given a folder of image files and a frameDuration
-// WIP: function not complete, since no animation sheets in use
-// ? needs additional args for position, size, ... ?
-func NewAnimationFromSheet(path string, frameDuration time.Duration) *Animation {
-	return &Animation{}
+// GetBoxes returns the boxes of the current frame, or nil if it has none
+func (a *Animation) GetBoxes() []FrameBox {
+	if len(a.Frames) == 0 {
+		return nil
+	}
+	return a.Frames[a.GetFrameNum()].Boxes
 }
 
+// GetProgress returns how many cycles the Animation has played since it started
+// (or was sought), e.g. 1.5 halfway through its second time round.  A finished
+// Once Animation has played 1.
+func (a *Animation) GetProgress() float64 {
+	total := a.cycleDuration()
+	if a.finished || total <= 0 {
+		return float64(a.cycles) + 1
+	}
+	played := a.elapsed
+	for pos := 0; pos < a.pos; pos++ {
+		played += a.Frames[a.frameAt(pos)].Duration
+	}
+	return float64(a.cycles) + float64(played)/float64(total)
+}
+
+// Seek jumps to t into the Animation (from the start of the first frame), without
+// calling OnLoop or OnFinish.  Looping Animations wrap t round; Once Animations
+// sought past their end are finished.
+func (a *Animation) Seek(t time.Duration) {
+	a.pos, a.elapsed, a.cycles, a.finished, a.started = 0, 0, 0, false, false
+	total := a.cycleDuration()
+	if len(a.Frames) == 0 || total <= 0 || t < 0 {
+		return
+	}
+	if a.Mode == Once && t >= total {
+		a.pos, a.finished = a.cycleLen()-1, true
+		return
+	}
+	a.cycles = int(t / total)
+	t %= total
+	for t >= a.Frames[a.GetFrameNum()].Duration {
+		t -= a.Frames[a.GetFrameNum()].Duration
+		a.pos++
+	}
+	a.elapsed = t
+}
+
+// Reset goes back to the start of the first frame
+func (a *Animation) Reset() {
+	a.Seek(0)
+}
+
+// SheetOptions describes how to cut the frames of an Animation out of a sprite sheet.
+// Frames are either listed in Rects, or taken row by row from a grid of
+// FrameWidth by FrameHeight cells, Margin pixels in from the edge of the sheet
+// and Spacing pixels apart (as in a Tiled tileset).
+type SheetOptions struct {
+	FrameWidth, FrameHeight int
+	Margin                  int
+	Spacing                 int
+	StartRow, StartCol      int // first cell of the grid to use
+	Rows, Cols              int // how many rows and columns of cells to use, or 0 for the rest of the sheet
+	Count                   int // at most this many frames (e.g. for a part-filled last row), or 0 for all
+
+	Rects []image.Rectangle // the frames, in sheet pixels, instead of a grid
+
+	FrameDuration time.Duration   // how long every frame is shown
+	Durations     []time.Duration // or how long each frame is shown, one per frame
+}
+
+// frames returns the rectangle of each frame on a sheet with the given bounds,
+// or an error if opts don't fit it
+func (opts SheetOptions) frames(sheet image.Rectangle) ([]image.Rectangle, error) {
+	if opts.Rects != nil {
+		for _, r := range opts.Rects {
+			if r.Empty() || !r.In(sheet) {
+				return nil, fmt.Errorf("frame %v isn't inside sheet %v", r, sheet)
+			}
+		}
+		return opts.Rects, nil
+	}
+
+	if opts.FrameWidth <= 0 || opts.FrameHeight <= 0 {
+		return nil, fmt.Errorf("bad frame size %dx%d", opts.FrameWidth, opts.FrameHeight)
+	}
+	if opts.Margin < 0 || opts.Spacing < 0 || opts.StartRow < 0 || opts.StartCol < 0 || opts.Rows < 0 || opts.Cols < 0 || opts.Count < 0 {
+		return nil, fmt.Errorf("sheet options can't be negative: %+v", opts)
+	}
+	sheetCols := (sheet.Dx() - 2*opts.Margin + opts.Spacing) / (opts.FrameWidth + opts.Spacing)
+	sheetRows := (sheet.Dy() - 2*opts.Margin + opts.Spacing) / (opts.FrameHeight + opts.Spacing)
+	rows, cols := opts.Rows, opts.Cols
+	if rows == 0 {
+		rows = sheetRows - opts.StartRow
+	}
+	if cols == 0 {
+		cols = sheetCols - opts.StartCol
+	}
+	if rows <= 0 || cols <= 0 || opts.StartRow+rows > sheetRows || opts.StartCol+cols > sheetCols {
+		return nil, fmt.Errorf("%d rows and %d columns from (%d, %d) don't fit on a sheet of %dx%d frames",
+			rows, cols, opts.StartRow, opts.StartCol, sheetRows, sheetCols)
+	}
+
+	var rects []image.Rectangle
+	for row := opts.StartRow; row < opts.StartRow+rows; row++ {
+		for col := opts.StartCol; col < opts.StartCol+cols; col++ {
+			if opts.Count > 0 && len(rects) == opts.Count {
+				return rects, nil
+			}
+			min := sheet.Min.Add(image.Point{
+				opts.Margin + col*(opts.FrameWidth+opts.Spacing),
+				opts.Margin + row*(opts.FrameHeight+opts.Spacing),
+			})
+			rects = append(rects, image.Rectangle{min, min.Add(image.Point{opts.FrameWidth, opts.FrameHeight})})
+		}
+	}
+	return rects, nil
+}
+
+// durations returns how long each of n frames is shown
+func (opts SheetOptions) durations(n int) ([]time.Duration, error) {
+	durations := opts.Durations
+	if durations == nil {
+		durations = make([]time.Duration, n)
+		for i := range durations {
+			durations[i] = opts.FrameDuration
+		}
+	}
+	if len(durations) != n {
+		return nil, fmt.Errorf("%d durations given for %d frames", len(durations), n)
+	}
+	for i, d := range durations {
+		if d <= 0 {
+			return nil, fmt.Errorf("frame %d has no duration", i)
+		}
+	}
+	return durations, nil
+}
+
+// NewAnimationFromImage returns an Animation cut from the given sprite sheet (see SheetOptions)
+func NewAnimationFromImage(sheet *ebiten.Image, opts SheetOptions) (*Animation, error) {
+	rects, err := opts.frames(sheet.Bounds())
+	if err != nil {
+		return nil, err
+	}
+	durations, err := opts.durations(len(rects))
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, len(rects))
+	for i, r := range rects {
+		frames[i] = Frame{Image: sheet.SubImage(r).(*ebiten.Image), Duration: durations[i]}
+	}
+	return newAnimation(frames), nil
+}
+
+// NewAnimationFromSheet returns an Animation cut from the sprite sheet image file at path
+// (see SheetOptions)
+func NewAnimationFromSheet(path string, opts SheetOptions) (*Animation, error) {
+	sheet, _, err := ebitenutil.NewImageFromFile(path, ebiten.FilterDefault)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewAnimationFromImage(sheet, opts)
+	if err != nil {
+		return nil, fmt.Errorf("sprite sheet %s: %v", path, err)
+	}
+	return a, nil
+}
+
+// NewAnimationFromFolder returns an Animation of the image files in a folder (in
+// name order), each shown for frameDuration
 func NewAnimationFromFolder(path string, frameDuration time.Duration) *Animation {
-	a := Animation{}
-	a.lastFrameTime = time.Now()
-	a.frameDuration = frameDuration
+	a := newAnimation(nil)
 
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -53,19 +395,68 @@ func NewAnimationFromFolder(path string, frameDuration time.Duration) *Animation
 		if err != nil {
 			log.Fatal(err)
 		}
-		a.Images = append(a.Images, img)
+		a.Frames = append(a.Frames, Frame{Image: img, Duration: frameDuration})
 	}
 
-	return &a
+	return a
+}
+
+// NewAnimationFromAtlas returns an Animation of the named images packed in a, each
+// shown for frameDuration, e.g. the names atlas.Builder.AddFolder returned
+func NewAnimationFromAtlas(a *atlas.Atlas, names []string, frameDuration time.Duration) (*Animation, error) {
+	frames := make([]Frame, len(names))
+	for i, name := range names {
+		if frames[i].Image = a.Image(name); frames[i].Image == nil {
+			return nil, fmt.Errorf("atlas has no image %q", name)
+		}
+		frames[i].Duration = frameDuration
+	}
+	return newAnimation(frames), nil
+}
+
+// NewAnimationsFromTileset returns an Animation for each animated tile of ts, by
+// local tile ID.  Each frame has a FrameBox for each rectangle or polygon drawn on
+// its tile in Tiled's collision editor, and the events listed (comma separated)
+// in the tile's "events" property.
+func NewAnimationsFromTileset(ts *tiled.Tileset) (map[int]*Animation, error) {
+	animations := make(map[int]*Animation, len(ts.Animations))
+	tileBoxes := make(map[int][]FrameBox) // shared by every frame showing the tile
+	for id, tileFrames := range ts.Animations {
+		frames := make([]Frame, len(tileFrames))
+		for i, f := range tileFrames {
+			boxes, ok := tileBoxes[f.TileID]
+			if !ok {
+				var err error
+				if boxes, err = objectBoxes(ts.Collisions[f.TileID]); err != nil {
+					return nil, fmt.Errorf("tile %d: %v", f.TileID, err)
+				}
+				tileBoxes[f.TileID] = boxes
+			}
+			frames[i] = Frame{
+				Image:    ts.GetTileImage(f.TileID),
+				Duration: f.Duration,
+				Events:   splitEvents(ts.Properties[f.TileID]["events"]),
+				Boxes:    boxes,
+			}
+		}
+		animations[id] = newAnimation(frames)
+	}
+	return animations, nil
 }
 
 // == Static Sprite ================
 
 type Sprite struct {
-	Mirrors int
-	Image   *ebiten.Image
+	Orientation Orientation
+	Image       *ebiten.Image
 }
 
 func (s *Sprite) GetImage() *ebiten.Image {
 	return s.Image
 }
+
+// GetGeoM returns the matrix drawing the Sprite's Image with its Orientation, to
+// be followed by the Sprite's placement
+func (s *Sprite) GetGeoM() ebiten.GeoM {
+	return OrientedGeoM(s.Orientation, s.Image)
+}
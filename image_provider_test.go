@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func Test_SheetFrames(t *testing.T) {
+	// 4 columns and 3 rows of 16x16 frames, 1 pixel apart and 2 in from the edge
+	sheet := image.Rect(0, 0, 2+4*16+3+2, 2+3*16+2+2)
+	grid := SheetOptions{FrameWidth: 16, FrameHeight: 16, Margin: 2, Spacing: 1}
+	cases := []struct {
+		name     string
+		opts     SheetOptions
+		expected []image.Rectangle
+		count    int
+	}{
+		{"whole sheet", grid, []image.Rectangle{image.Rect(2, 2, 18, 18), image.Rect(19, 2, 35, 18)}, 12},
+		{"row", SheetOptions{FrameWidth: 16, FrameHeight: 16, Margin: 2, Spacing: 1, StartRow: 1, Rows: 1},
+			[]image.Rectangle{image.Rect(2, 19, 18, 35)}, 4},
+		{"columns", SheetOptions{FrameWidth: 16, FrameHeight: 16, Margin: 2, Spacing: 1, StartCol: 2, Cols: 2},
+			[]image.Rectangle{image.Rect(36, 2, 52, 18), image.Rect(53, 2, 69, 18), image.Rect(36, 19, 52, 35)}, 6},
+		{"count", SheetOptions{FrameWidth: 16, FrameHeight: 16, Margin: 2, Spacing: 1, StartRow: 2, Count: 3},
+			[]image.Rectangle{image.Rect(2, 36, 18, 52)}, 3},
+		{"rects", SheetOptions{Rects: []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(10, 0, 30, 8)}},
+			[]image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(10, 0, 30, 8)}, 2},
+	}
+	for _, c := range cases {
+		rects, err := c.opts.frames(sheet)
+		if err != nil {
+			t.Errorf("SheetOptions.frames (%s): expected no error, got %v", c.name, err)
+			continue
+		}
+		if len(rects) != c.count {
+			t.Errorf("SheetOptions.frames (%s): expected %d frames, got %d", c.name, c.count, len(rects))
+			continue
+		}
+		for i, r := range c.expected {
+			if rects[i] != r {
+				t.Errorf("SheetOptions.frames (%s): expected frame %d at %v, got %v", c.name, i, r, rects[i])
+			}
+		}
+	}
+
+	bad := map[string]SheetOptions{
+		"no frame size":   {},
+		"too many rows":   {FrameWidth: 16, FrameHeight: 16, Margin: 2, Spacing: 1, StartRow: 2, Rows: 2},
+		"past the edge":   {FrameWidth: 16, FrameHeight: 16, StartCol: 5},
+		"negative margin": {FrameWidth: 16, FrameHeight: 16, Margin: -1},
+		"rect off sheet":  {Rects: []image.Rectangle{image.Rect(60, 0, 80, 16)}},
+		"empty rect":      {Rects: []image.Rectangle{image.Rect(4, 4, 4, 8)}},
+	}
+	for name, opts := range bad {
+		if _, err := opts.frames(sheet); err == nil {
+			t.Errorf("SheetOptions.frames (%s): expected an error", name)
+		}
+	}
+}
+
+func Test_SheetDurations(t *testing.T) {
+	opts := SheetOptions{FrameDuration: 100 * time.Millisecond}
+	if durations, err := opts.durations(3); err != nil || len(durations) != 3 || durations[2] != 100*time.Millisecond {
+		t.Errorf("SheetOptions.durations (shared): expected three of 100ms, got %v (%v)", durations, err)
+	}
+	opts.Durations = []time.Duration{50 * time.Millisecond, 200 * time.Millisecond}
+	if durations, err := opts.durations(2); err != nil || durations[1] != 200*time.Millisecond {
+		t.Errorf("SheetOptions.durations (each): expected 50ms then 200ms, got %v (%v)", durations, err)
+	}
+	if _, err := opts.durations(3); err == nil {
+		t.Errorf("SheetOptions.durations (too few): expected an error")
+	}
+	if _, err := (SheetOptions{}).durations(1); err == nil {
+		t.Errorf("SheetOptions.durations (none): expected an error")
+	}
+}
+
+func Test_EmptyAnimation(t *testing.T) {
+	if img := (&Animation{}).GetImage(); img != nil {
+		t.Errorf("Animation.GetImage (empty): expected nil, got %v", img)
+	}
+}
+
+func Test_AnimationLiteral(t *testing.T) {
+	a := &Animation{Frames: []Frame{{Duration: 100 * time.Millisecond}, {Duration: 100 * time.Millisecond}}}
+	a.Update(150 * time.Millisecond)
+	if frame := a.GetFrameNum(); frame != 1 {
+		t.Errorf("Animation.Update (struct literal): expected it to play at speed 1 to frame 1, got frame %d", frame)
+	}
+}
+
+// newTestAnimation returns an Animation driven by Update, with frames lasting the
+// given numbers of milliseconds
+func newTestAnimation(mode LoopMode, ms ...int) *Animation {
+	frames := make([]Frame, len(ms))
+	for i := range ms {
+		frames[i].Duration = time.Duration(ms[i]) * time.Millisecond
+	}
+	a := newAnimation(frames)
+	a.Mode = mode
+	return a
+}
+
+func Test_AnimationUpdate(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   LoopMode
+		speed  float64
+		ms     int // total passed to Update, in 50ms steps
+		frame  int
+		loops  int
+		finish bool
+	}{
+		{"first frame", Loop, 1, 50, 0, 0, false},
+		{"several frames at once", Loop, 1, 350, 2, 0, false},
+		{"looped", Loop, 1, 650, 0, 1, false},
+		{"looped twice", Loop, 1, 1300, 1, 2, false},
+		{"double speed", Loop, 2, 200, 2, 0, false},
+		{"speed unset", Loop, 0, 350, 2, 0, false},
+		{"half speed", Loop, 0.5, 300, 1, 0, false},
+		{"once, before the end", Once, 1, 550, 2, 0, false},
+		{"once, finished", Once, 1, 1000, 2, 0, true},
+		{"ping-pong, turned", PingPong, 1, 650, 1, 0, false},
+		{"ping-pong, looped", PingPong, 1, 850, 0, 1, false},
+	}
+	for _, c := range cases {
+		a := newTestAnimation(c.mode, 100, 200, 300)
+		a.Speed = c.speed
+		loops, finishes := 0, 0
+		a.OnLoop = func() { loops++ }
+		a.OnFinish = func() { finishes++ }
+		for ms := 0; ms < c.ms; ms += 50 {
+			a.Update(50 * time.Millisecond)
+		}
+		if a.GetFrameNum() != c.frame || loops != c.loops || a.Finished() != c.finish {
+			t.Errorf("Animation.Update (%s): expected frame %d after %d loops (finished %v), got frame %d after %d (%v)",
+				c.name, c.frame, c.loops, c.finish, a.GetFrameNum(), loops, a.Finished())
+		}
+		if finishes > 1 || (finishes == 1) != c.finish {
+			t.Errorf("Animation.Update (%s): expected OnFinish called once when finished, got %d calls", c.name, finishes)
+		}
+	}
+
+	// one big step lands where many small ones do
+	a := newTestAnimation(PingPong, 100, 200, 300)
+	a.Update(1150 * time.Millisecond)
+	b := newTestAnimation(PingPong, 100, 200, 300)
+	for i := 0; i < 23; i++ {
+		b.Update(50 * time.Millisecond)
+	}
+	if a.GetFrameNum() != b.GetFrameNum() || a.elapsed != b.elapsed {
+		t.Errorf("Animation.Update (one step): expected frame %d, %v in, got %d, %v in", b.GetFrameNum(), b.elapsed, a.GetFrameNum(), a.elapsed)
+	}
+
+	a.Paused = true
+	a.Update(time.Second)
+	if a.GetFrameNum() != b.GetFrameNum() || a.elapsed != b.elapsed {
+		t.Errorf("Animation.Update (paused): expected the Animation to stay put")
+	}
+}
+
+func Test_AnimationSeek(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   LoopMode
+		ms     int
+		frame  int
+		finish bool
+	}{
+		{"start", Loop, 0, 0, false},
+		{"third frame", Loop, 350, 2, false},
+		{"wrapped", Loop, 650, 0, false},
+		{"ping-pong, on the way back", PingPong, 700, 1, false},
+		{"once, past the end", Once, 650, 2, true},
+	}
+	for _, c := range cases {
+		a := newTestAnimation(c.mode, 100, 200, 300)
+		a.OnLoop = func() { t.Errorf("Animation.Seek (%s): expected OnLoop not to be called", c.name) }
+		a.Update(450 * time.Millisecond)
+		a.Seek(time.Duration(c.ms) * time.Millisecond)
+		if a.GetFrameNum() != c.frame || a.Finished() != c.finish {
+			t.Errorf("Animation.Seek (%s): expected frame %d (finished %v), got %d (%v)", c.name, c.frame, c.finish, a.GetFrameNum(), a.Finished())
+		}
+	}
+}
+
+func Test_AnimationClock(t *testing.T) {
+	clock := &ManualClock{}
+	a := newTestAnimation(Loop, 100, 200, 300)
+	a.Clock = clock
+	a.GetImage()
+	clock.Advance(350 * time.Millisecond)
+	a.GetImage()
+	if a.GetFrameNum() != 2 {
+		t.Errorf("Animation (clock): expected frame 2 after 350ms, got %d", a.GetFrameNum())
+	}
+	a.GetImage()
+	if a.GetFrameNum() != 2 || a.elapsed != 50*time.Millisecond {
+		t.Errorf("Animation (clock): expected no change while the clock stands still, got frame %d, %v in", a.GetFrameNum(), a.elapsed)
+	}
+
+	// by default only Update moves it on, however long drawing takes
+	b := newAnimation(a.Frames)
+	b.Update(50 * time.Millisecond)
+	b.GetImage()
+	if b.Clock != nil || b.GetFrameNum() != 0 || b.elapsed != 50*time.Millisecond {
+		t.Errorf("Animation (no clock): expected frame 0, 50ms in, got frame %d, %v in", b.GetFrameNum(), b.elapsed)
+	}
+}
+
+func Test_AnimationEvents(t *testing.T) {
+	a := newTestAnimation(Loop, 100, 100, 100)
+	a.Frames[0].Events = []string{"step"}
+	a.Frames[2].Events = []string{"swing", "whoosh"}
+	a.Frames[2].Boxes = []FrameBox{{Name: "hitbox", Kind: Hitbox}}
+	var events []string
+	a.OnEvent = func(event string) { events = append(events, event) }
+
+	a.Update(50 * time.Millisecond)
+	if len(events) != 1 || events[0] != "step" {
+		t.Errorf("Animation.OnEvent (start): expected the first frame's step, got %v", events)
+	}
+	if boxes := a.GetBoxes(); len(boxes) != 0 {
+		t.Errorf("Animation.GetBoxes (frame 0): expected no boxes, got %v", boxes)
+	}
+	a.Update(300 * time.Millisecond)
+	expected := []string{"step", "swing", "whoosh", "step"}
+	if len(events) != len(expected) || events[1] != "swing" || events[3] != "step" {
+		t.Errorf("Animation.OnEvent: expected %v, got %v", expected, events)
+	}
+
+	a.Seek(250 * time.Millisecond)
+	if boxes := a.GetBoxes(); len(boxes) != 1 || boxes[0].Kind != Hitbox {
+		t.Errorf("Animation.GetBoxes (frame 2): expected the hitbox, got %v", boxes)
+	}
+	if len(events) != len(expected) {
+		t.Errorf("Animation.Seek: expected no events, got %v", events[len(expected):])
+	}
+}
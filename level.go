@@ -1,15 +1,166 @@
 package engine
 
 import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r2"
+
 	"github.com/jwlarocque/engine/mech"
 	"github.com/jwlarocque/engine/tiled"
 )
 
+// Level is a Tiled map ready to play: its solid tiles are Static colliders in the
+// Terrain index, and the objects on its object layers are Entities
 type Level struct {
-	terrainColliders []*mech.Collider
 	tiled.Map
+	Terrain  *mech.SpatialHash
+	Entities []*Entity
+	terrain  []mech.Collider // everything in Terrain, in the order it was added
+	worlds   []*mech.World   // the Worlds it has been added to (see AddTo)
+}
+
+// Entity is an object from one of a Level's object layers.  Objects of type
+// "platform" come with their moving Platform (see tiled.Map.Platforms).
+type Entity struct {
+	tiled.Object
+	Layer    string // the name of the object layer it's on
+	Platform *mech.Platform
+}
+
+// terrainMode is how a Level covers its solid tiles with colliders
+const terrainMode = tiled.MergedColliders
+
+// terrainCellTiles is the side of the Terrain index's cells, in tiles
+const terrainCellTiles = 4
+
+// NewLevelFromFile loads a Level of a TMX or JSON map file (see tiled.DecodeMap),
+// all at once on the calling goroutine, which must be the main thread.  See
+// LoadLevel for loading in the background.
+func NewLevelFromFile(filePath string) (*Level, error) {
+	decoded, err := tiled.DecodeMap(context.Background(), filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, err := decoded.Upload()
+	if err != nil {
+		return nil, err
+	}
+	level, err := newLevel(m)
+	if err != nil {
+		m.Dispose()
+		m.Tileset.Dispose()
+		return nil, err
+	}
+	return level, nil
+}
+
+// newLevel returns a Level of the loaded map m, with its terrain and entities
+func newLevel(m *tiled.Map) (*Level, error) {
+	platforms, err := m.Platforms()
+	if err != nil {
+		return nil, fmt.Errorf("level: %v", err)
+	}
+	var entities []*Entity
+	for _, group := range m.ObjectGroups {
+		for _, obj := range group.Objects {
+			entity := &Entity{Object: obj, Layer: group.Name}
+			// Platforms returns the platforms in the order they appear
+			if obj.Type == "platform" {
+				entity.Platform, platforms = platforms[0], platforms[1:]
+			}
+			entities = append(entities, entity)
+		}
+	}
+
+	tileWidth, tileHeight := m.GetTileSize()
+	cellSize := terrainCellTiles * math.Max(float64(tileWidth), float64(tileHeight))
+	terrain := mech.NewSpatialHash(math.Max(1, cellSize)) // 1 for maps without tiles
+	colliders := m.TerrainColliders(terrainMode)
+	for _, c := range colliders {
+		terrain.Insert(c)
+	}
+
+	return &Level{Map: *m, Terrain: terrain, Entities: entities, terrain: colliders}, nil
+}
+
+// reload takes on the map, terrain and entities of next (a newLevel of the
+// reloaded map), swapping them in the Worlds the Level is in.  Anything holding
+// the old terrain or entities needs to look them up again.
+func (l *Level) reload(next *Level) {
+	worlds := append([]*mech.World(nil), l.worlds...)
+	for _, w := range worlds {
+		l.RemoveFrom(w)
+	}
+	l.Map, l.Terrain, l.Entities, l.terrain = next.Map, next.Terrain, next.Entities, next.terrain
+	for _, w := range worlds {
+		l.AddTo(w)
+	}
+}
+
+// GetEntity returns the Level's first Entity with the given name, or nil if there
+// isn't one
+func (l *Level) GetEntity(name string) *Entity {
+	for _, entity := range l.Entities {
+		if entity.Name == name {
+			return entity
+		}
+	}
+	return nil
+}
+
+// GetEntities returns the Level's Entities of the given type, in map order
+func (l *Level) GetEntities(typ string) []*Entity {
+	var found []*Entity
+	for _, entity := range l.Entities {
+		if entity.Type == typ {
+			found = append(found, entity)
+		}
+	}
+	return found
+}
+
+// GetTerrain returns the Terrain colliders touching the box (min, max)
+func (l *Level) GetTerrain(min, max r2.Point) []mech.Collider {
+	return l.Terrain.Query(min, max, mech.QueryAll)
+}
+
+// AddTo puts the Level's terrain colliders and platforms into w, where they're
+// kept up to date if the Level is reloaded (see AssetManager.Level)
+func (l *Level) AddTo(w *mech.World) {
+	for _, added := range l.worlds {
+		if added == w {
+			return
+		}
+	}
+	l.worlds = append(l.worlds, w)
+	for _, c := range l.terrain {
+		w.Add(c)
+	}
+	for _, entity := range l.Entities {
+		if entity.Platform != nil {
+			w.Add(entity.Platform.Collider)
+			w.AddPlatform(entity.Platform)
+		}
+	}
 }
 
-func NewLevelFromFile(filePath string) *Level {
-	return &Level{}
+// RemoveFrom takes the Level's terrain colliders and platforms back out of w
+func (l *Level) RemoveFrom(w *mech.World) {
+	for i, added := range l.worlds {
+		if added == w {
+			l.worlds = append(l.worlds[:i], l.worlds[i+1:]...)
+			break
+		}
+	}
+	for _, c := range l.terrain {
+		w.Remove(c)
+	}
+	for _, entity := range l.Entities {
+		if entity.Platform != nil {
+			w.RemovePlatform(entity.Platform)
+			w.Remove(entity.Platform.Collider)
+		}
+	}
 }
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"github.com/jwlarocque/engine/tiled"
+)
+
+// == Loading Levels in the Background ========
+
+// LevelLoad is a Level loading in the background (see LoadLevel)
+type LevelLoad struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	progress uint64        // math.Float64bits of the progress, accessed atomically
+	decoded  chan struct{} // closed once decoding has finished, or failed
+	result   *tiled.DecodedMap
+	level    *Level
+	err      error
+}
+
+// decodeMap is tiled.DecodeMap, which tests replace
+var decodeMap = tiled.DecodeMap
+
+// uploadProgress is how much of a LevelLoad's progress the GPU upload makes up;
+// decoding takes the rest
+const uploadProgress = 0.05
+
+// LoadLevel starts loading a Level of the .tmx or .json map file at filePath on
+// another goroutine, and returns straight away.  Call Poll on the main thread each
+// update to finish it off, showing Progress on a loading screen in the meantime.
+// Cancelling ctx (or calling Cancel) abandons the load.
+func LoadLevel(ctx context.Context, filePath string) *LevelLoad {
+	l := &LevelLoad{decoded: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(l.decoded)
+		l.result, l.err = decodeMap(l.ctx, filePath, func(p float64) {
+			l.setProgress(p * (1 - uploadProgress))
+		})
+	}()
+	return l
+}
+
+func (l *LevelLoad) setProgress(p float64) {
+	atomic.StoreUint64(&l.progress, math.Float64bits(p))
+}
+
+// Progress returns how far along loading is, from 0 to 1.  It's safe to call from
+// any goroutine.
+func (l *LevelLoad) Progress() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&l.progress))
+}
+
+// Cancel abandons the load, if it hasn't finished
+func (l *LevelLoad) Cancel() {
+	l.cancel()
+}
+
+// Decoded returns a channel which is closed once everything but the GPU upload is
+// done (or has failed)
+func (l *LevelLoad) Decoded() <-chan struct{} {
+	return l.decoded
+}
+
+// Poll finishes the load once the background work is done, uploading the Level's
+// images to the GPU, so it must be called on the main thread.  done is false while
+// the load is still going; after that the Level or error is returned every time.
+func (l *LevelLoad) Poll() (level *Level, done bool, err error) {
+	select {
+	case <-l.decoded:
+	default:
+		return nil, false, nil
+	}
+	if l.level == nil && l.err == nil {
+		l.level, l.err = l.finish()
+		l.result = nil
+		l.cancel() // frees the context, now nothing's left to cancel
+		if l.err == nil {
+			l.setProgress(1)
+		}
+	}
+	return l.level, true, l.err
+}
+
+// finish uploads the decoded map and builds the Level from it
+func (l *LevelLoad) finish() (*Level, error) {
+	if err := l.ctx.Err(); err != nil {
+		return nil, err
+	}
+	m, err := l.result.Upload()
+	if err != nil {
+		return nil, err
+	}
+	level, err := newLevel(m)
+	if err != nil {
+		// the map and its tileset are this load's own, so nothing else frees them
+		m.Dispose()
+		m.Tileset.Dispose()
+		return nil, err
+	}
+	return level, nil
+}
+
+// Wait blocks until the load is done, and finishes it as Poll does, so it must be
+// called on the main thread
+func (l *LevelLoad) Wait() (*Level, error) {
+	<-l.decoded
+	level, _, err := l.Poll()
+	return level, err
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwlarocque/engine/tiled"
+)
+
+func Test_LevelLoadCancel(t *testing.T) {
+	decode := decodeMap
+	defer func() { decodeMap = decode }()
+	started := make(chan struct{})
+	decodeMap = func(ctx context.Context, filePath string, progress func(float64)) (*tiled.DecodedMap, error) {
+		progress(0.5)
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	load := LoadLevel(context.Background(), "level.tmx")
+	<-started
+	if p := load.Progress(); p <= 0 || p >= 1 {
+		t.Errorf("LevelLoad.Progress: expected partway, got %v", p)
+	}
+	if _, done, err := load.Poll(); done || err != nil {
+		t.Errorf("LevelLoad.Poll (loading): expected not done, got done %v (%v)", done, err)
+	}
+
+	load.Cancel()
+	select {
+	case <-load.Decoded():
+	case <-time.After(time.Second):
+		t.Fatal("LevelLoad.Cancel: expected decoding to stop")
+	}
+	if level, done, err := load.Poll(); !done || level != nil || err != context.Canceled {
+		t.Errorf("LevelLoad.Poll (cancelled): expected done with context.Canceled, got %v %v (%v)", level, done, err)
+	}
+}
+
+func Test_LevelLoadError(t *testing.T) {
+	decode := decodeMap
+	defer func() { decodeMap = decode }()
+	failure := errors.New("no such map")
+	decodeMap = func(ctx context.Context, filePath string, progress func(float64)) (*tiled.DecodedMap, error) {
+		return nil, failure
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	load := LoadLevel(ctx, "missing.tmx")
+	if level, err := load.Wait(); level != nil || err != failure {
+		t.Errorf("LevelLoad.Wait (failed): expected the decoding error, got %v (%v)", level, err)
+	}
+	if _, done, err := load.Poll(); !done || err != failure {
+		t.Errorf("LevelLoad.Poll (failed): expected the same error again, got done %v (%v)", done, err)
+	}
+}
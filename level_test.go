@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+	"github.com/jwlarocque/engine/tiled"
+)
+
+func Test_LevelEntities(t *testing.T) {
+	m := &tiled.Map{ObjectGroups: []tiled.ObjectGroup{
+		{Name: "spawns", Objects: []tiled.Object{
+			{ID: 1, Name: "player", Type: "spawn", Position: r2.Point{16, 32}},
+			{ID: 2, Name: "bat", Type: "enemy"},
+		}},
+		{Name: "moving", Objects: []tiled.Object{
+			{ID: 3, Type: "platform", Width: 32, Height: 8, Properties: map[string]string{"path": "4", "speed": "10"}},
+			{ID: 4, Polyline: []r2.Point{{0, 0}, {100, 0}}},
+			{ID: 5, Name: "rat", Type: "enemy"},
+		}},
+	}}
+	level, err := newLevel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(level.Entities) != 5 {
+		t.Fatalf("newLevel: expected an entity for each of the 5 objects, got %d", len(level.Entities))
+	}
+	if player := level.GetEntity("player"); player == nil || player.Position != (r2.Point{16, 32}) || player.Layer != "spawns" {
+		t.Errorf("Level.GetEntity (player): expected the player on layer spawns, got %+v", player)
+	}
+	if level.GetEntity("dragon") != nil {
+		t.Errorf("Level.GetEntity (missing): expected nil")
+	}
+	if enemies := level.GetEntities("enemy"); len(enemies) != 2 || enemies[0].Name != "bat" || enemies[1].Name != "rat" {
+		t.Errorf("Level.GetEntities (enemy): expected the bat then the rat, got %v", enemies)
+	}
+	platform := level.GetEntities("platform")[0].Platform
+	if platform == nil || len(platform.Path) != 2 || platform.Speed != 10 {
+		t.Fatalf("newLevel (platform): expected a platform following its path, got %+v", platform)
+	}
+
+	world := mech.NewWorld(1.0/60, 64)
+	level.AddTo(world)
+	if len(world.Platforms()) != 1 || len(world.Colliders()) != 1 {
+		t.Errorf("Level.AddTo: expected the platform and its collider, got %d and %d", len(world.Platforms()), len(world.Colliders()))
+	}
+	level.RemoveFrom(world)
+	if len(world.Platforms()) != 0 || len(world.Colliders()) != 0 {
+		t.Errorf("Level.RemoveFrom: expected an empty world, got %d platforms and %d colliders", len(world.Platforms()), len(world.Colliders()))
+	}
+
+	m.ObjectGroups[1].Objects[0].Properties["path"] = "5"
+	if _, err := newLevel(m); err == nil {
+		t.Errorf("newLevel (platform with a bad path): expected an error")
+	}
+}
+
+func Test_NewLevelFromFile(t *testing.T) {
+	if level, err := NewLevelFromFile("missing.tmx"); level != nil || err == nil {
+		t.Errorf("NewLevelFromFile (missing): expected an error, got %v (%v)", level, err)
+	}
+}
+
+func Test_LevelReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now().Add(-time.Hour)
+	writeAsset(t, dir, "level.tmx", "ab", start)
+	path := filepath.Join(dir, "level.tmx")
+
+	// a platform for each character of the map file, or a broken one for "!"
+	m := fakeAssets(dir, make(map[string]int))
+	m.Loaders[MapAsset] = func(m *AssetManager, path string) (interface{}, error) {
+		text, err := ioutil.ReadFile(path)
+		group := tiled.ObjectGroup{Name: "moving"}
+		for i, c := range text {
+			obj := tiled.Object{ID: i + 1, Type: "platform", Width: 8, Height: 8}
+			if c == '!' {
+				obj.Properties = map[string]string{"path": "99"}
+			}
+			group.Objects = append(group.Objects, obj)
+		}
+		return &tiled.Map{ObjectGroups: []tiled.ObjectGroup{group}}, err
+	}
+	level, err := m.Level(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := mech.NewWorld(1.0/60, 64)
+	level.AddTo(world)
+
+	// the world swaps the old platforms for the new ones
+	writeAsset(t, dir, "level.tmx", "abc", start.Add(time.Minute))
+	if _, err := m.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	platforms := level.GetEntities("platform")
+	if len(platforms) != 3 || len(world.Platforms()) != 3 || len(world.Colliders()) != 3 {
+		t.Fatalf("Level (reloaded): expected 3 platforms in the level and world, got %d, %d and %d colliders",
+			len(platforms), len(world.Platforms()), len(world.Colliders()))
+	}
+	for i, p := range world.Platforms() {
+		if p != platforms[i].Platform {
+			t.Errorf("Level (reloaded): expected the world to have the level's new platforms, got %v", world.Platforms())
+			break
+		}
+	}
+
+	// a reload which fails leaves the level and world as they were
+	writeAsset(t, dir, "level.tmx", "ab!", start.Add(2*time.Minute))
+	if _, err := m.Poll(); err == nil {
+		t.Errorf("AssetManager.Poll (bad platform): expected an error")
+	}
+	if len(level.GetEntities("platform")) != 3 || level.GetEntities("platform")[0] != platforms[0] || len(world.Platforms()) != 3 {
+		t.Errorf("Level (failed reload): expected the level's 3 platforms kept, got %v", level.Entities)
+	}
+
+	level.RemoveFrom(world)
+	if len(world.Platforms()) != 0 || len(world.Colliders()) != 0 {
+		t.Errorf("Level.RemoveFrom (reloaded): expected an empty world, got %d platforms and %d colliders",
+			len(world.Platforms()), len(world.Colliders()))
+	}
+}
+
+func Test_LevelTerrain(t *testing.T) {
+	// a floor, and a ledge up on the right
+	m, err := tiled.NewMapFromGIDs(4, 3, 16, 16, []uint32{
+		0, 0, 0, 1,
+		0, 0, 0, 1,
+		1, 1, 1, 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, err := newLevel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := level.GetTerrain(r2.Point{0, 0}, r2.Point{64, 48})
+	if len(all) != 2 {
+		t.Fatalf("Level.GetTerrain (everything): expected the floor and ledge, got %v", all)
+	}
+	for _, c := range all {
+		if c.GetFixture().Kind != mech.Static {
+			t.Errorf("Level.GetTerrain: expected Static colliders, got %v", c)
+		}
+	}
+	if found := level.GetTerrain(r2.Point{2, 2}, r2.Point{30, 30}); len(found) != 0 {
+		t.Errorf("Level.GetTerrain (open air): expected nothing, got %v", found)
+	}
+	found := level.GetTerrain(r2.Point{0, 40}, r2.Point{8, 44})
+	if len(found) != 1 {
+		t.Fatalf("Level.GetTerrain (floor): expected one collider, got %v", found)
+	}
+	if min, max := found[0].Bounds(); min.Y > 32 || max.Y < 48 || min.X > 0 {
+		t.Errorf("Level.GetTerrain (floor): expected a collider covering the bottom left tile, got %v to %v", min, max)
+	}
+
+	world := mech.NewWorld(1.0/60, 64)
+	level.AddTo(world)
+	if len(world.Colliders()) != 2 {
+		t.Errorf("Level.AddTo (terrain): expected 2 colliders, got %d", len(world.Colliders()))
+	}
+}
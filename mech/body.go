@@ -2,7 +2,51 @@ package mech
 
 import "github.com/golang/geo/r2"
 
+// BodyKind determines how a World moves a Body
+type BodyKind int
+
+const (
+	Dynamic   BodyKind = iota // moved by its velocity and gravity, pushed out of other bodies
+	Kinematic                 // moved by its velocity only, pushes dynamic bodies aside
+	Static                    // never moved by the World
+)
+
+// Body is the physical state of a collider, including the Transform placing it.
+// PrevPosition and PrevAngle are from before the last World step, for interpolation.
+//
+// A Dynamic Body with a Mass is a rigid body: the World resolves its contacts with
+// impulses (see World.Iterations), so it bounces, slides and tumbles.  Without a
+// Mass it is simply pushed out of whatever it overlaps, and never turns.
 type Body struct {
-	Position r2.Point
-	Velocity r2.Point
+	Transform
+	Velocity        r2.Point // of the centre of mass
+	PrevPosition    r2.Point
+	AngularVelocity float64 // radians per second, about the centre of mass
+	PrevAngle       float64
+	Kind            BodyKind
+
+	Mass        float64 // 0 for no rigid body dynamics
+	Inertia     float64 // moment of inertia; 0 to work it out from Mass and shape, +Inf to never turn
+	Restitution float64 // bounciness, 0 (none) to 1 (perfectly elastic)
+	Friction    float64 // friction coefficient (0 is frictionless)
+	// SurfaceSpeed is how fast the surface moves around the collider's outline
+	// (clockwise on screen), for conveyor belts
+	SurfaceSpeed float64
+}
+
+// Interpolate returns the position of the Body alpha of the way between its
+// previous and current World steps (see World.Alpha)
+func (b *Body) Interpolate(alpha float64) r2.Point {
+	return b.PrevPosition.Add(b.Position.Sub(b.PrevPosition).Mul(alpha))
+}
+
+// InterpolateAngle returns the angle of the Body alpha of the way between its
+// previous and current World steps (see World.Alpha)
+func (b *Body) InterpolateAngle(alpha float64) float64 {
+	return b.PrevAngle + (b.Angle-b.PrevAngle)*alpha
+}
+
+// rigid returns whether the World solves the Body's contacts with impulses
+func (b *Body) rigid() bool {
+	return b.Kind == Dynamic && b.Mass > 0
 }
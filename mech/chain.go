@@ -0,0 +1,212 @@
+package mech
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// == Chain Collider ========
+
+// ChainCollider is a connected series of one-sided edges, such as the outline of
+// a group of terrain tiles.  Vertices go clockwise around the solid side as seen
+// on screen (y down), i.e. the solid side is to the right of each edge.
+//
+// Each edge knows its neighbours ("ghost vertices"), so that a collider sliding
+// along the chain is never caught on the seam between two edges: only corners
+// which actually stick out can push colliders sideways.
+type ChainCollider struct {
+	Vertices             []r2.Point // placed by the Body's Transform; don't change after construction
+	Loop                 bool       // whether the last vertex connects back to the first
+	boundSmall, boundBig r2.Point   // bounding box, before the Transform
+	world                []edge     // edges in level space
+	cache                transformCache
+	Fixture
+}
+
+// ErrTooFewVertices is returned when a collider is given too few vertices to form its shape
+type ErrTooFewVertices struct {
+	ErrStr   string
+	Vertices []*r2.Point
+}
+
+func (e *ErrTooFewVertices) Error() string {
+	return fmt.Sprintf("%s : %v", e.ErrStr, e.Vertices)
+}
+
+// NewChainCollider constructs a ChainCollider through the given vertices.
+// If loop is set the chain is closed, and must have at least three vertices.
+func NewChainCollider(vertices []*r2.Point, loop bool) (*ChainCollider, error) {
+	if len(vertices) < 2 || (loop && len(vertices) < 3) {
+		return nil, &ErrTooFewVertices{"ChainCollider had too few vertices.", vertices}
+	}
+	coll := ChainCollider{}
+	coll.Filter = DefaultFilter
+	coll.Loop = loop
+	coll.Vertices = make([]r2.Point, len(vertices))
+	coll.boundSmall, coll.boundBig = *vertices[0], *vertices[0]
+	for i, v := range vertices {
+		coll.Vertices[i] = *v
+		coll.boundSmall = r2.Point{math.Min(coll.boundSmall.X, v.X), math.Min(coll.boundSmall.Y, v.Y)}
+		coll.boundBig = r2.Point{math.Max(coll.boundBig.X, v.X), math.Max(coll.boundBig.Y, v.Y)}
+	}
+	return &coll, nil
+}
+
+// Bounds returns the corners of the ChainCollider's bounding box in level space
+func (c *ChainCollider) Bounds() (min, max r2.Point) {
+	c.worldEdges()
+	return c.cache.min, c.cache.max
+}
+
+func (c *ChainCollider) String() string {
+	return fmt.Sprintf("ChainCollider with Bounds: (%v, %v), Vertices: %v, Loop: %v", c.boundSmall, c.boundBig, c.Vertices, c.Loop)
+}
+
+// Collides returns whether any edge of this ChainCollider touches the other
+// Collider, and their Filters allow them to interact
+func (c *ChainCollider) Collides(other Collider) bool {
+	return collides(c, other)
+}
+
+// WillCollide returns whether c and other _are_ colliding after dt seconds,
+// assuming both keep moving at their current Velocity.  Neither is actually moved.
+func (c *ChainCollider) WillCollide(other Collider, dt float64) bool {
+	return willCollide(c, other, dt)
+}
+
+// edge is a single one-sided edge of a ChainCollider in level space
+type edge struct {
+	v1, v2       r2.Point
+	normal       r2.Point // unit normal on the open (non-solid) side
+	convex1      bool     // whether the chain sticks out at v1, so it may push sideways there
+	convex2      bool     // likewise at v2
+	prev, next   bool     // whether the edge has neighbours at v1 and v2
+	prevV, nextV r2.Point // the neighbouring ("ghost") vertices
+}
+
+// worldEdges returns the ChainCollider's edges in level space, redoing them only
+// if the Transform has changed
+func (c *ChainCollider) worldEdges() []edge {
+	if !c.cache.stale(c.Transform) {
+		return c.world
+	}
+	n := len(c.Vertices)
+	count := n - 1
+	if c.Loop {
+		count = n
+	}
+	// a mirrored chain is walked backwards, so the solid side stays on the right
+	points := make([]r2.Point, n)
+	for i, v := range c.Vertices {
+		if c.Mirrored() {
+			points[n-1-i] = c.Apply(v)
+		} else {
+			points[i] = c.Apply(v)
+		}
+	}
+	vertex := func(i int) r2.Point {
+		return points[(i+n)%n]
+	}
+	edges := make([]edge, 0, count)
+	for i := 0; i < count; i++ {
+		e := edge{v1: vertex(i), v2: vertex(i + 1)}
+		dir := e.v2.Sub(e.v1)
+		if dir.Norm() == 0 {
+			continue
+		}
+		e.normal = r2.Point{dir.Y, -dir.X}.Normalize()
+		e.prev, e.next = c.Loop || i > 0, c.Loop || i+1 < n-1
+		e.convex1, e.convex2 = true, true
+		if e.prev {
+			e.prevV = vertex(i - 1)
+			e.convex1 = e.v1.Sub(e.prevV).Dot(e.normal) > skin
+		}
+		if e.next {
+			e.nextV = vertex(i + 2)
+			e.convex2 = e.nextV.Sub(e.v2).Dot(e.normal) < -skin
+		}
+		edges = append(edges, e)
+	}
+	c.world = edges
+	c.cache.update(c.Transform, points)
+	return edges
+}
+
+// segment returns the edge as a degenerate two vertex polygon
+func (e edge) segment() convex {
+	return convex{e.v1, e.v2}
+}
+
+// allows returns whether normal is a valid push direction for a collision with e.
+// The edge's own normal always is; a normal pointing past one of its ends is
+// only valid if the chain turns away there (otherwise the neighbouring edge
+// covers that region, and the contact is really a seam).
+func (e edge) allows(normal r2.Point) bool {
+	if normal.Dot(e.normal) < 0 {
+		return false
+	}
+	along := normal.Dot(e.v2.Sub(e.v1))
+	switch {
+	case along > skin:
+		return e.convex2
+	case along < -skin:
+		return e.convex1
+	}
+	return true
+}
+
+// depth returns how far p reaches behind the edge's line
+func (e edge) depth(p convex) float64 {
+	pMin, _ := p.project(e.normal)
+	return e.normal.Dot(e.v1) - pMin
+}
+
+// penetration returns the push-out normal and depth of p from the edge.
+// Polygons whose centre is behind the edge are coming from the solid side and
+// are ignored.
+func (e edge) penetration(p convex) (normal r2.Point, depth float64, ok bool) {
+	if e.normal.Dot(p.centroid().Sub(e.v1)) < 0 {
+		return r2.Point{}, 0, false
+	}
+	normal, depth, ok = p.penetration(e.segment())
+	if !ok {
+		return r2.Point{}, 0, false
+	}
+	if !e.allows(normal) {
+		normal, depth = e.normal, e.depth(p)
+	}
+	return normal, depth, depth > 0
+}
+
+// cast sweeps p along motion toward the edge (see convex.cast).
+// Only the open side of the edge can be hit.
+func (e edge) cast(p convex, motion r2.Point) (fraction float64, normal r2.Point, hit bool) {
+	if motion.Dot(e.normal) >= 0 || e.depth(p) > skin {
+		return 0, r2.Point{}, false
+	}
+	fraction, normal, hit = p.cast(e.segment(), motion)
+	if hit && !e.allows(normal) {
+		normal = e.normal
+	}
+	return fraction, normal, hit
+}
+
+// raycast returns where the ray from origin along unit vector dir crosses the
+// open side of the edge
+func (e edge) raycast(origin, dir r2.Point, maxDist float64) (dist float64, normal r2.Point, hit bool) {
+	den := dir.Dot(e.normal)
+	if den >= 0 {
+		return 0, r2.Point{}, false
+	}
+	dist = e.v1.Sub(origin).Dot(e.normal) / den
+	if dist < 0 || dist > maxDist {
+		return 0, r2.Point{}, false
+	}
+	along := origin.Add(dir.Mul(dist)).Sub(e.v1).Dot(e.v2.Sub(e.v1))
+	if along < 0 || along > e.v2.Sub(e.v1).Dot(e.v2.Sub(e.v1)) {
+		return 0, r2.Point{}, false
+	}
+	return dist, e.normal, true
+}
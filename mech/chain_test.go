@@ -0,0 +1,40 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_ChainSeams(t *testing.T) {
+	// a flat floor split into several edges, which a box sliding along it must not catch on
+	floor, err := NewChainCollider([]*r2.Point{{-100, 10}, {-5, 10}, {0, 10}, {5, 10}, {100, 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewController(newBox(-50, 0, 10, 10))
+	ctrl.Grounded = true
+	for i := 0; i < 60; i++ {
+		ctrl.Move(r2.Point{100, 0}, 1.0/60, []Collider{floor})
+	}
+	if math.Abs(ctrl.Position.X-50) > 1e-6 || math.Abs(ctrl.Position.Y) > 1e-6 {
+		t.Errorf("ChainCollider (seams): expected box at (50, 0), got %v", ctrl.Position)
+	}
+}
+
+func Test_ChainOneSided(t *testing.T) {
+	floor, err := NewChainCollider([]*r2.Point{{-100, 10}, {100, 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, hit := raycast(floor, r2.Point{0, 0}, r2.Point{0, 1}, 100); !hit {
+		t.Errorf("ChainCollider (raycast from above): expected hit, got none")
+	}
+	if _, _, hit := raycast(floor, r2.Point{0, 20}, r2.Point{0, -1}, 100); hit {
+		t.Errorf("ChainCollider (raycast from below): expected no hit, got one")
+	}
+	if _, err := NewChainCollider([]*r2.Point{{0, 0}, {1, 0}}, true); err == nil {
+		t.Errorf("ChainCollider (two vertex loop): expected ErrTooFewVertices, got nil")
+	}
+}
@@ -7,14 +7,30 @@ package mech
 
 import (
 	"fmt"
-	"log"
+	"math"
 
 	"github.com/golang/geo/r2"
-	"github.com/jwlarocque/engine/r2extra"
 )
 
 type Collider interface {
 	Collides(other Collider) bool
+	GetFixture() *Fixture
+	Bounds() (min, max r2.Point)
+}
+
+// Fixture holds the state shared by every kind of Collider: its Body, and
+// flags describing how other colliders should treat it.
+type Fixture struct {
+	Body
+	Filter
+	OneWay bool        // only solid when approached from above (see Controller)
+	Sensor bool        // reports overlaps (see SensorEvent) but is never pushed or pushes
+	Owner  interface{} // whatever game object this collider belongs to
+}
+
+// GetFixture returns f, so that every collider embedding a Fixture can hand it out
+func (f *Fixture) GetFixture() *Fixture {
+	return f
 }
 
 // == Axis Aligned Box Collider =========
@@ -27,40 +43,17 @@ type AABBCollider struct {
 
 // == Convex Polygon Collider ========
 
-// PolyCollider has Vertices and an Entity to keep track of its position in the level.
+// PolyCollider has Vertices and a Body to keep track of its place in the level.
 // It can determine whether it is intersecting/overlapping with another PolyCollider.
-// Note: Vertices must form a convex polygon (do not repeat first/last vertex).
+// Vertices form a convex polygon going clockwise on screen (see NewPolyCollider),
+// and are placed by the Body's Transform.  Don't change them after construction.
 type PolyCollider struct {
 	Vertices             []*r2.Point
 	center               r2.Point // middle of bounding box
-	boundSmall, boundBig r2.Point // bounding box
-	Body
-}
-
-// isConvex returns whether the given vertices form a convex polygon
-// does not handle wack polygons (e.g. self-intersecting)
-// FIXME: handle case where two adjacent segments are parallel (i.e., Cross ~= 0) (should always pass)
-// FIXME: I don't know how Collides will handle colliders with fewer than 4 vertices
-func isConvex(vertices []*r2.Point) bool {
-	// triangles, "lines," and "points" are convex
-	if len(vertices) <= 3 {
-		return true
-	}
-
-	// baseline is sign of second to last vector cross last vector
-	v1 := vertices[len(vertices)-1].Sub(*vertices[len(vertices)-2])
-	v2 := vertices[0].Sub(*vertices[len(vertices)-1])
-	positive := v1.Cross(v2) > 0
-
-	// check that sign of all Cross of adjacent vectors matches baseline
-	for i := 0; i < len(vertices)-1; i++ {
-		v1 = v2
-		v2 = vertices[i+1].Sub(*vertices[i])
-		if v1.Cross(v2) > 0 != positive {
-			return false
-		}
-	}
-	return true
+	boundSmall, boundBig r2.Point // bounding box, before the Transform
+	world                convex   // Vertices in level space
+	cache                transformCache
+	Fixture
 }
 
 // ErrNotConvex is returned by NewPolyCollider when the provided vertices are not convex
@@ -73,139 +66,191 @@ func (e *ErrNotConvex) Error() string {
 	return fmt.Sprintf("%s : %v", e.ErrStr, e.Vertices)
 }
 
-// NewPolyCollider constructs a new PolyCollider from the provided vertices
-func NewPolyCollider(vertices []*r2.Point) (*PolyCollider, error) {
-	if !isConvex(vertices) {
-		return nil, &ErrNotConvex{"PolyCollider vertices were not convex.", vertices}
-	}
-	coll := PolyCollider{}
-	coll.Vertices = vertices
-
-	// find bounding box
-	coll.boundSmall = *vertices[0]
-	coll.boundBig = *vertices[0]
-	for i := 1; i < len(vertices); i++ {
-		if vertices[i].X < coll.boundSmall.X {
-			coll.boundSmall.X = vertices[i].X
-		} else if vertices[i].X > coll.boundBig.X {
-			coll.boundBig.X = vertices[i].X
-		}
-		if vertices[i].Y < coll.boundSmall.Y {
-			coll.boundSmall.Y = vertices[i].Y
-		} else if vertices[i].Y > coll.boundBig.Y {
-			coll.boundBig.Y = vertices[i].Y
-		}
-	}
-
-	// find center of bounding box
-	coll.center = coll.boundSmall.Add((coll.boundBig.Sub(coll.boundSmall)).Mul(0.5))
-	return &coll, nil
+// ErrZeroArea is returned when a polygon's vertices all lie on one line
+type ErrZeroArea struct {
+	ErrStr   string
+	Vertices []*r2.Point
 }
 
-// GetVertexPos returns the position of the vertex at i (% len(vertices))
-// plus the PolyCollider's position
-func (c PolyCollider) GetVertexPos(i int) r2.Point {
-	return c.Vertices[i%len(c.Vertices)].Add(c.Position)
+func (e *ErrZeroArea) Error() string {
+	return fmt.Sprintf("%s : %v", e.ErrStr, e.Vertices)
 }
 
-func (c PolyCollider) String() string {
-	return fmt.Sprintf("PolyCollider with center: %v, Bounds: (%v, %v), Vertices: %v", c.center, c.boundSmall, c.boundBig, c.Vertices)
-}
+// straightness is the sine of the sharpest corner which is still treated as straight
+const straightness = 1e-9
 
-//
-// == Collision Detection ========
+// straight returns whether the corner at b, from a to c, doesn't turn
+// (including when b repeats a or c)
+func straight(a, b, c r2.Point) bool {
+	in, out := b.Sub(a), c.Sub(b)
+	return in.Norm() == 0 || out.Norm() == 0 || math.Abs(in.Cross(out)) <= straightness*in.Norm()*out.Norm()
+}
 
-// checks for bounding box collision
-func (c PolyCollider) bBoxCollides(other PolyCollider) bool {
-	// TODO: this is dumb, write cleaner way
-	cS := c.boundSmall.Add(c.Position)
-	cB := c.boundBig.Add(c.Position)
-	oS := other.boundSmall.Add(other.Position)
-	oB := other.boundBig.Add(other.Position)
-	if cS.X > oB.X || cS.Y > oB.Y || cB.X < oS.X || cB.Y < oS.Y {
-		return false
+// normalizePolygon checks that vertices form a convex polygon, and returns a copy
+// of them tidied up: repeated vertices and those partway along a straight edge
+// are dropped, and the rest go clockwise on screen (y down), starting from the
+// first one kept.  name says whose vertices they are in errors.
+func normalizePolygon(vertices []*r2.Point, name string) ([]r2.Point, error) {
+	distinct := make(map[r2.Point]bool)
+	points := make([]r2.Point, 0, len(vertices))
+	for _, v := range vertices {
+		if len(points) == 0 || *v != points[len(points)-1] {
+			points = append(points, *v)
+		}
+		distinct[*v] = true
+	}
+	if len(distinct) < 3 {
+		return nil, &ErrTooFewVertices{name + " had too few distinct vertices.", vertices}
+	}
+	if points[len(points)-1] == points[0] {
+		points = points[:len(points)-1]
 	}
-	return true
-}
-
-// check for polygon collision with separating axis theorem
-// idea: https://www.sevenson.com.au/actionscript/sat/
-// TODO: Only checks edges of c as separating line, so must
-//       && with other.satCollides(c) - combine into single call
-func (c PolyCollider) satCollides(other PolyCollider) bool {
-	// check each side of this PolyCollider (c)
-	var axis r2.Point
-	var current, cMin, cMax, otherMin, otherMax float64
-	for i := 0; i < len(c.Vertices); i++ {
-		// axis is ortho to a side of c
-		axis = c.GetVertexPos(i).Sub(c.GetVertexPos(i + 1)).Ortho()
-		// find projection/"shadow" of c onto axis
-		cMin = r2extra.ProjectOntoMagnitude(c.GetVertexPos(0), axis)
-		cMax = cMin
-		for j := 1; j < len(c.Vertices); j++ {
-			current = r2extra.ProjectOntoMagnitude(c.GetVertexPos(j), axis)
-			if current > cMax {
-				cMax = current
-			} else if current < cMin {
-				cMin = current
+
+	// dropping a corner can straighten its neighbours', so go until none are left
+	for dropped := true; dropped && len(points) >= 3; {
+		dropped = false
+		for i := range points {
+			n := len(points)
+			if straight(points[(i+n-1)%n], points[i], points[(i+1)%n]) {
+				points = append(points[:i], points[i+1:]...)
+				dropped = true
+				break
 			}
 		}
+	}
+	if len(points) < 3 {
+		return nil, &ErrZeroArea{name + " vertices were all in a line.", vertices}
+	}
 
-		// do the same for other
-		otherMin = r2extra.ProjectOntoMagnitude(other.GetVertexPos(0), axis)
-		otherMax = otherMin
-		for j := 1; j < len(other.Vertices); j++ {
-			current = r2extra.ProjectOntoMagnitude(other.GetVertexPos(j), axis)
-			if current > otherMax {
-				otherMax = current
-			} else if current < otherMin {
-				otherMin = current
-			}
+	n := len(points)
+	if edgesCross(points) {
+		return nil, &ErrSelfIntersecting{name + " edges crossed each other.", vertices}
+	}
+	area := signedArea(points)
+	if area == 0 {
+		return nil, &ErrZeroArea{name + " vertices enclosed no area.", vertices}
+	}
+	// with no crossings, a polygon which always turns the same way is convex
+	for i := range points {
+		turn := points[(i+1)%n].Sub(points[i]).Cross(points[(i+2)%n].Sub(points[(i+1)%n]))
+		if (turn > 0) != (area > 0) {
+			return nil, &ErrNotConvex{name + " vertices were not convex.", vertices}
 		}
+	}
 
-		// if projections don't overlap, there must be a gap between the colliders
-		if cMax < otherMin || otherMax < cMin {
-			return false
+	if area < 0 {
+		for i, j := 1, n-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
 		}
 	}
-	return true
+	return points, nil
 }
 
-// Collides returns whether this PolyCollider intersects with the other Collider
-func (poly PolyCollider) Collides(other Collider) bool {
-	switch other.(type) {
-	case PolyCollider:
-		otherPoly, ok := other.(PolyCollider)
-		if !ok {
-			log.Fatal("Collider type assertion to PolyCollider failed?!")
-		}
-		// check bounding box intersection
-		if !poly.bBoxCollides(otherPoly) {
-			return false
+// edgesCross returns whether any two edges of the polygon points meet, other than
+// neighbouring edges at their shared vertex
+func edgesCross(points []r2.Point) bool {
+	n := len(points)
+	for i := 0; i < n; i++ {
+		// neighbouring edges always share a vertex, so only later, unconnected ones are checked
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			if segmentsMeet(points[i], points[i+1], points[j], points[(j+1)%n]) {
+				return true
+			}
 		}
-		// check for gaps with separating axis theorem
-		// note: satCollides only considers the axes of c, so just and the results together to consider both
-		return poly.satCollides(otherPoly) && otherPoly.satCollides(poly)
-	default:
-		log.Fatal("fixme")
-		return false
 	}
+	return false
 }
 
-// WillCollide returns whether c and other _are_ colliding after timeSteps
-// assumes collider moves exactly velocity every time step (i.e., a time step is one unit of time)
-func (poly PolyCollider) WillCollide(other Collider, timeSteps int) bool {
-	switch other.(type) {
-	case PolyCollider:
-		otherPoly, ok := other.(PolyCollider)
-		if !ok {
-			log.Fatal("Collider type assertion to PolyCollider failed?!")
+// orientation returns the sign of the turn from a to b to c
+func orientation(a, b, c r2.Point) int {
+	cross := b.Sub(a).Cross(c.Sub(a))
+	switch {
+	case cross > 0:
+		return 1
+	case cross < 0:
+		return -1
+	}
+	return 0
+}
+
+// segmentsMeet returns whether the segments (a, b) and (c, d) share any point
+func segmentsMeet(a, b, c, d r2.Point) bool {
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+	return (o1 == 0 && within(a, b, c)) || (o2 == 0 && within(a, b, d)) ||
+		(o3 == 0 && within(c, d, a)) || (o4 == 0 && within(c, d, b))
+}
+
+// within returns whether p is in the bounding box of (a, b)
+func within(a, b, p r2.Point) bool {
+	return math.Min(a.X, b.X) <= p.X && p.X <= math.Max(a.X, b.X) &&
+		math.Min(a.Y, b.Y) <= p.Y && p.Y <= math.Max(a.Y, b.Y)
+}
+
+// NewPolyCollider constructs a new PolyCollider from a copy of the provided vertices,
+// normalized as described by normalizePolygon.  It returns ErrTooFewVertices,
+// ErrZeroArea, ErrSelfIntersecting or ErrNotConvex if they don't make a convex polygon.
+func NewPolyCollider(vertices []*r2.Point) (*PolyCollider, error) {
+	points, err := normalizePolygon(vertices, "PolyCollider")
+	if err != nil {
+		return nil, err
+	}
+	coll := PolyCollider{}
+	coll.Filter = DefaultFilter
+	coll.Vertices = make([]*r2.Point, len(points))
+	for i := range points {
+		coll.Vertices[i] = &points[i]
+	}
+	coll.boundSmall, coll.boundBig = boundsOf(points)
+
+	// find center of bounding box
+	coll.center = coll.boundSmall.Add((coll.boundBig.Sub(coll.boundSmall)).Mul(0.5))
+	return &coll, nil
+}
+
+// Bounds returns the corners of the PolyCollider's bounding box in level space
+func (c *PolyCollider) Bounds() (min, max r2.Point) {
+	c.worldVertices()
+	return c.cache.min, c.cache.max
+}
+
+// GetVertexPos returns the position of the vertex at i (% len(vertices))
+// in level space, i.e. with the Body's Transform applied
+func (c *PolyCollider) GetVertexPos(i int) r2.Point {
+	return c.worldVertices()[i%len(c.Vertices)]
+}
+
+func (c *PolyCollider) String() string {
+	return fmt.Sprintf("PolyCollider with center: %v, Bounds: (%v, %v), Vertices: %v", c.center, c.boundSmall, c.boundBig, c.Vertices)
+}
+
+// worldVertices returns the PolyCollider's vertices in level space, redoing them
+// only if the Transform has changed
+func (c *PolyCollider) worldVertices() convex {
+	if c.cache.stale(c.Transform) {
+		c.world = make(convex, len(c.Vertices))
+		for i, v := range c.Vertices {
+			c.world[i] = c.Apply(*v)
 		}
-		poly.Position.Add(poly.Velocity)
-		otherPoly.Position.Add(otherPoly.Velocity)
-		return poly.Collides(otherPoly)
-	default:
-		log.Fatal("fixme")
-		return false
+		c.cache.update(c.Transform, c.world)
 	}
+	return c.world
+}
+
+// Collides returns whether this PolyCollider intersects with the other Collider,
+// and their Filters allow them to interact
+func (poly *PolyCollider) Collides(other Collider) bool {
+	return collides(poly, other)
+}
+
+// WillCollide returns whether poly and other _are_ colliding after dt seconds,
+// assuming both keep moving at their current Velocity.  Neither is actually moved.
+func (poly *PolyCollider) WillCollide(other Collider, dt float64) bool {
+	return willCollide(poly, other, dt)
 }
@@ -1,56 +1,80 @@
 package mech
 
 import (
-	"image/color"
-	"log"
 	"testing"
 
 	"github.com/golang/geo/r2"
-
-	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
 )
 
-func Test_isConvex(t *testing.T) {
-	var convex bool
-	// square, expect convex = true
-	convex = isConvex([]*r2.Point{{0.0, 0.0}, {1.0, 0.0}, {1.0, 1.0}, {0.0, 1.0}})
-	if !convex {
-		t.Errorf("isConvex (square): expected true, got false!")
+func Test_NewPolyCollider(t *testing.T) {
+	square := []r2.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	cases := []struct {
+		name     string
+		vertices []*r2.Point
+		expected []r2.Point
+	}{
+		{"square", []*r2.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, square},
+		{"triangle", []*r2.Point{{0, 0}, {10, 0}, {0, 10}}, []r2.Point{{0, 0}, {10, 0}, {0, 10}}},
+		{"straight corners", []*r2.Point{{0, 0}, {5, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 5}}, square},
+		{"repeated vertices", []*r2.Point{{0, 0}, {10, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}, square},
+		{"spike", []*r2.Point{{0, 0}, {10, 0}, {15, 0}, {10, 0}, {10, 10}, {0, 10}}, square},
+		{"anticlockwise", []*r2.Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}}, square},
 	}
-
-	// chevron, expect convex = false
-	convex = isConvex([]*r2.Point{{1.0, 0.0}, {2.0, 2.0}, {1.0, 1.0}, {0.0, 2.0}})
-	if convex {
-		t.Errorf("isConvex (chevron): expected false, got true!")
+	for _, c := range cases {
+		coll, err := NewPolyCollider(c.vertices)
+		if err != nil {
+			t.Errorf("NewPolyCollider (%s): expected no error, got %v", c.name, err)
+			continue
+		}
+		got := make([]r2.Point, len(coll.Vertices))
+		for i, v := range coll.Vertices {
+			got[i] = *v
+		}
+		if len(got) != len(c.expected) {
+			t.Errorf("NewPolyCollider (%s): expected vertices %v, got %v", c.name, c.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.expected[i] {
+				t.Errorf("NewPolyCollider (%s): expected vertices %v, got %v", c.name, c.expected, got)
+				break
+			}
+		}
 	}
-}
-
-var testCollider Collider
-var testColliderImg *ebiten.Image
 
-func collidersInteractiveUpdate(screen *ebiten.Image) error {
-	for i := 0; i < len(testCollider.Vertices)-1; i++ {
-		//log.Printf("(%.4f, %.4f); (%.4f, %.4f)", testCollider.Vertices[i].X, testCollider.Vertices[i].Y, testCollider.Vertices[i+1].X, testCollider.Vertices[i+1].Y)
-		//ebitenutil.DrawLine(screen, testCollider.Vertices[i].X, testCollider.Vertices[i].Y, testCollider.Vertices[i+1].X, testCollider.Vertices[i+1].Y, color.Gray{90})
+	bad := []struct {
+		name     string
+		vertices []*r2.Point
+		check    func(error) bool
+	}{
+		{"two vertices", []*r2.Point{{0, 0}, {10, 0}},
+			func(err error) bool { _, ok := err.(*ErrTooFewVertices); return ok }},
+		{"repeated segment", []*r2.Point{{0, 0}, {10, 0}, {10, 0}, {0, 0}},
+			func(err error) bool { _, ok := err.(*ErrTooFewVertices); return ok }},
+		{"line", []*r2.Point{{0, 0}, {5, 5}, {10, 10}},
+			func(err error) bool { _, ok := err.(*ErrZeroArea); return ok }},
+		{"bowtie", []*r2.Point{{0, 0}, {10, 10}, {10, 0}, {0, 10}},
+			func(err error) bool { _, ok := err.(*ErrSelfIntersecting); return ok }},
+		{"pentagram", []*r2.Point{{0, -10}, {6, 8}, {-9.5, -3}, {9.5, -3}, {-6, 8}},
+			func(err error) bool { _, ok := err.(*ErrSelfIntersecting); return ok }},
+		{"chevron", []*r2.Point{{1, 0}, {2, 2}, {1, 1}, {0, 2}},
+			func(err error) bool { _, ok := err.(*ErrNotConvex); return ok }},
+	}
+	for _, c := range bad {
+		if _, err := NewPolyCollider(c.vertices); !c.check(err) {
+			t.Errorf("NewPolyCollider (%s): expected a %s error, got %T (%v)", c.name, c.name, err, err)
+		}
 	}
-	screen.DrawImage(testColliderImg, &ebiten.DrawImageOptions{})
-	ebitenutil.DrawLine(screen, 1.0, 1.0, 20.0, 20.0, color.Gray{80})
-	return nil
 }
 
-func Test_CollidersInteractive(t *testing.T) {
-	diamCollider, err := NewCollider([]*r2.Point{{10.0, 10.0}, {50.0, 50.0}})
-	if err != nil {
-		log.Fatal((err))
-	}
-	testCollider = *diamCollider
-	testColliderImg, err = ebiten.NewImage(50, 50, ebiten.FilterDefault)
+func Test_PolyColliderCopiesVertices(t *testing.T) {
+	vertices := []*r2.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	coll, err := NewPolyCollider(vertices)
 	if err != nil {
-		log.Fatal((err))
+		t.Fatal(err)
 	}
-
-	if err := ebiten.Run(collidersInteractiveUpdate, 400, 240, 2, "Interactive Colliders Test"); err != nil {
-		log.Fatal(err)
+	vertices[2].X = 100
+	if _, max := coll.Bounds(); max != (r2.Point{10, 10}) || coll.GetVertexPos(2) != (r2.Point{10, 10}) {
+		t.Errorf("NewPolyCollider (copy): expected caller's vertices not to move the collider, got max %v", max)
 	}
 }
@@ -0,0 +1,289 @@
+package mech
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// == Compound Collider ========
+
+// CompoundCollider is a single Collider made of several convex polygons, such as
+// the pieces of a concave shape.  It behaves like one collider in every query:
+// it collides if any of its parts do.
+type CompoundCollider struct {
+	parts                [][]r2.Point // convex parts, relative to Position
+	boundSmall, boundBig r2.Point     // bounding box of all parts, before the Transform
+	world                []convex     // parts in level space
+	cache                transformCache
+	Fixture
+}
+
+// ErrSelfIntersecting is returned when vertices do not form a simple polygon
+// (one whose edges only meet at shared vertices), so it can't be decomposed
+type ErrSelfIntersecting struct {
+	ErrStr   string
+	Vertices []*r2.Point
+}
+
+func (e *ErrSelfIntersecting) Error() string {
+	return fmt.Sprintf("%s : %v", e.ErrStr, e.Vertices)
+}
+
+// NewCompoundCollider constructs a CompoundCollider from several convex polygons,
+// each checked and normalized as for NewPolyCollider
+func NewCompoundCollider(parts [][]*r2.Point) (*CompoundCollider, error) {
+	coll := CompoundCollider{}
+	coll.Filter = DefaultFilter
+	for i, part := range parts {
+		vertices, err := normalizePolygon(part, "CompoundCollider part")
+		if err != nil {
+			return nil, err
+		}
+		small, big := boundsOf(vertices)
+		if i == 0 {
+			coll.boundSmall, coll.boundBig = small, big
+		}
+		coll.boundSmall = r2.Point{math.Min(coll.boundSmall.X, small.X), math.Min(coll.boundSmall.Y, small.Y)}
+		coll.boundBig = r2.Point{math.Max(coll.boundBig.X, big.X), math.Max(coll.boundBig.Y, big.Y)}
+		coll.parts = append(coll.parts, vertices)
+	}
+	return &coll, nil
+}
+
+// NewConcaveCollider constructs a CompoundCollider from the vertices of any simple
+// polygon (edges may not cross, no holes).  The polygon is triangulated by ear
+// clipping, then neighbouring triangles are merged back together wherever the
+// result stays convex (Hertel-Mehlhorn), which gives at most four times the
+// minimum number of convex parts.
+func NewConcaveCollider(vertices []*r2.Point) (*CompoundCollider, error) {
+	points := make([]r2.Point, len(vertices))
+	// repeated vertices would make empty edges, which meet their neighbours' neighbours
+	var outline []r2.Point
+	for i, v := range vertices {
+		points[i] = *v
+		if len(outline) == 0 || *v != outline[len(outline)-1] {
+			outline = append(outline, *v)
+		}
+	}
+	if len(outline) > 1 && outline[len(outline)-1] == outline[0] {
+		outline = outline[:len(outline)-1]
+	}
+	if edgesCross(outline) {
+		return nil, &ErrSelfIntersecting{"ConcaveCollider edges crossed each other.", vertices}
+	}
+	pieces, ok := decompose(points)
+	if !ok {
+		return nil, &ErrSelfIntersecting{"ConcaveCollider vertices did not form a simple polygon.", vertices}
+	}
+
+	parts := make([][]*r2.Point, len(pieces))
+	for i, piece := range pieces {
+		parts[i] = make([]*r2.Point, len(piece))
+		for j, index := range piece {
+			p := points[index]
+			parts[i][j] = &p
+		}
+	}
+	return NewCompoundCollider(parts)
+}
+
+// Bounds returns the corners of the CompoundCollider's bounding box in level space
+func (c *CompoundCollider) Bounds() (min, max r2.Point) {
+	c.worldParts()
+	return c.cache.min, c.cache.max
+}
+
+// Parts returns the convex parts of the CompoundCollider in level space
+func (c *CompoundCollider) Parts() [][]r2.Point {
+	parts := make([][]r2.Point, len(c.parts))
+	for i, part := range c.worldParts() {
+		parts[i] = append([]r2.Point(nil), part...)
+	}
+	return parts
+}
+
+// worldParts returns the convex parts of the CompoundCollider in level space,
+// redoing them only if the Transform has changed
+func (c *CompoundCollider) worldParts() []convex {
+	if c.cache.stale(c.Transform) {
+		c.world = make([]convex, len(c.parts))
+		var points []r2.Point
+		for i, part := range c.parts {
+			c.world[i] = make(convex, len(part))
+			for j, v := range part {
+				c.world[i][j] = c.Apply(v)
+			}
+			points = append(points, c.world[i]...)
+		}
+		c.cache.update(c.Transform, points)
+	}
+	return c.world
+}
+
+func (c *CompoundCollider) String() string {
+	return fmt.Sprintf("CompoundCollider with Bounds: (%v, %v), Parts: %v", c.boundSmall, c.boundBig, c.parts)
+}
+
+// Collides returns whether any part of this CompoundCollider intersects with the
+// other Collider, and their Filters allow them to interact
+func (c *CompoundCollider) Collides(other Collider) bool {
+	return collides(c, other)
+}
+
+// WillCollide returns whether c and other _are_ colliding after dt seconds,
+// assuming both keep moving at their current Velocity.  Neither is actually moved.
+func (c *CompoundCollider) WillCollide(other Collider, dt float64) bool {
+	return willCollide(c, other, dt)
+}
+
+// == Convex Decomposition ========
+
+// decompose splits the simple polygon points into convex pieces, each a list of
+// indices into points.  ok is false if the polygon couldn't be triangulated.
+func decompose(points []r2.Point) (pieces [][]int, ok bool) {
+	triangles, ok := earClip(points)
+	if !ok {
+		return nil, false
+	}
+	return mergeConvex(points, triangles), true
+}
+
+// signedArea returns twice the signed area of the polygon; its sign gives the winding
+func signedArea(points []r2.Point) float64 {
+	area := 0.0
+	for i := range points {
+		area += points[i].Cross(points[(i+1)%len(points)])
+	}
+	return area
+}
+
+// earClip triangulates a simple polygon by repeatedly cutting off "ears":
+// convex corners whose triangle contains no other vertex
+func earClip(points []r2.Point) (triangles [][]int, ok bool) {
+	winding := signedArea(points)
+	if len(points) < 3 || winding == 0 {
+		return nil, false
+	}
+	remaining := make([]int, len(points))
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	for len(remaining) > 3 {
+		clipped := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			turn := points[cur].Sub(points[prev]).Cross(points[next].Sub(points[cur]))
+			if turn == 0 {
+				// cur is on a straight line between its neighbours, and can go without a triangle
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				clipped = true
+				break
+			}
+			if (turn > 0) != (winding > 0) {
+				continue // reflex corner
+			}
+			if anyInTriangle(points, remaining, prev, cur, next) {
+				continue
+			}
+			triangles = append(triangles, []int{prev, cur, next})
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			clipped = true
+			break
+		}
+		if !clipped {
+			return nil, false
+		}
+	}
+	if signedArea([]r2.Point{points[remaining[0]], points[remaining[1]], points[remaining[2]]}) != 0 {
+		triangles = append(triangles, remaining)
+	}
+	return triangles, len(triangles) > 0
+}
+
+// anyInTriangle returns whether any of the indexed points other than the triangle's
+// own corners lies inside or on the triangle (a, b, c)
+func anyInTriangle(points []r2.Point, indices []int, a, b, c int) bool {
+	for _, i := range indices {
+		if i == a || i == b || i == c {
+			continue
+		}
+		p := points[i]
+		if p == points[a] || p == points[b] || p == points[c] {
+			continue
+		}
+		d1 := points[b].Sub(points[a]).Cross(p.Sub(points[a]))
+		d2 := points[c].Sub(points[b]).Cross(p.Sub(points[b]))
+		d3 := points[a].Sub(points[c]).Cross(p.Sub(points[c]))
+		hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+		hasPos := d1 > 0 || d2 > 0 || d3 > 0
+		if !(hasNeg && hasPos) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeConvex joins pairs of pieces across their shared edges (diagonals of the
+// triangulation) for as long as the merged piece remains convex
+func mergeConvex(points []r2.Point, pieces [][]int) [][]int {
+	winding := signedArea(points)
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(pieces) && !merged; i++ {
+			for j := i + 1; j < len(pieces) && !merged; j++ {
+				joined, ok := joinPieces(pieces[i], pieces[j])
+				if !ok || !isConvexPiece(points, joined, winding) {
+					continue
+				}
+				pieces[i] = joined
+				pieces = append(pieces[:j], pieces[j+1:]...)
+				merged = true
+			}
+		}
+	}
+	return pieces
+}
+
+// joinPieces returns the union of two pieces which share an edge, or false if
+// they don't.  Both pieces must have the same winding.
+func joinPieces(a, b []int) ([]int, bool) {
+	for i := range a {
+		from, to := a[i], a[(i+1)%len(a)]
+		for j := range b {
+			// b traverses the shared edge in the opposite direction
+			if b[j] != to || b[(j+1)%len(b)] != from {
+				continue
+			}
+			// walk a from `to` around to `from`, then b from `from` around to just before `to`
+			joined := make([]int, 0, len(a)+len(b)-2)
+			for k := 1; k <= len(a); k++ {
+				joined = append(joined, a[(i+k)%len(a)])
+			}
+			for k := 2; k < len(b); k++ {
+				joined = append(joined, b[(j+k)%len(b)])
+			}
+			return joined, true
+		}
+	}
+	return nil, false
+}
+
+// isConvexPiece returns whether the indexed polygon turns the same way as winding
+// (or goes straight) at every corner
+func isConvexPiece(points []r2.Point, piece []int, winding float64) bool {
+	for i := range piece {
+		prev := points[piece[(i+len(piece)-1)%len(piece)]]
+		cur := points[piece[i]]
+		next := points[piece[(i+1)%len(piece)]]
+		turn := cur.Sub(prev).Cross(next.Sub(cur))
+		if turn != 0 && (turn > 0) != (winding > 0) {
+			return false
+		}
+	}
+	return true
+}
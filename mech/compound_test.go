@@ -0,0 +1,83 @@
+package mech
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_NewConcaveCollider(t *testing.T) {
+	// L-shaped ledge, expect two convex parts
+	ledge, err := NewConcaveCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 10}, {30, 10}, {30, 20}, {0, 20}})
+	if err != nil {
+		t.Fatalf("NewConcaveCollider (L): unexpected error %v", err)
+	}
+	if len(ledge.Parts()) != 2 {
+		t.Errorf("NewConcaveCollider (L): expected 2 parts, got %v", ledge.Parts())
+	}
+	for _, part := range ledge.Parts() {
+		vertices := make([]*r2.Point, len(part))
+		for i := range part {
+			vertices[i] = &part[i]
+		}
+		if _, err := normalizePolygon(vertices, "part"); err != nil {
+			t.Errorf("NewConcaveCollider (L): part %v is not convex", part)
+		}
+	}
+
+	// crater (reversed winding), expect three convex parts
+	crater, err := NewConcaveCollider([]*r2.Point{{0, 0}, {0, 20}, {40, 20}, {40, 0}, {30, 0}, {20, 10}, {10, 0}})
+	if err != nil {
+		t.Fatalf("NewConcaveCollider (crater): unexpected error %v", err)
+	}
+	if len(crater.Parts()) > 3 {
+		t.Errorf("NewConcaveCollider (crater): expected at most 3 parts, got %v", crater.Parts())
+	}
+
+	// L with vertices partway along its edges, as traced from tiles
+	traced, err := NewConcaveCollider([]*r2.Point{{0, 0}, {5, 0}, {10, 0}, {10, 10}, {20, 10}, {30, 10}, {30, 20}, {0, 20}, {0, 10}})
+	if err != nil {
+		t.Fatalf("NewConcaveCollider (traced L): unexpected error %v", err)
+	}
+	if len(traced.Parts()) > 3 {
+		t.Errorf("NewConcaveCollider (traced L): expected at most 3 parts, got %v", traced.Parts())
+	}
+
+	// bowtie, expect ErrSelfIntersecting
+	if _, err := NewConcaveCollider([]*r2.Point{{0, 0}, {10, 10}, {10, 0}, {0, 10}}); err == nil {
+		t.Errorf("NewConcaveCollider (bowtie): expected an error")
+	} else if _, ok := err.(*ErrSelfIntersecting); !ok {
+		t.Errorf("NewConcaveCollider (bowtie): expected ErrSelfIntersecting, got %T", err)
+	}
+
+	// crossed edges around a nonzero area, expect ErrSelfIntersecting
+	if _, err := NewConcaveCollider([]*r2.Point{{0, 0}, {20, 0}, {20, 10}, {5, -5}, {0, 10}}); err == nil {
+		t.Errorf("NewConcaveCollider (crossed): expected an error")
+	} else if _, ok := err.(*ErrSelfIntersecting); !ok {
+		t.Errorf("NewConcaveCollider (crossed): expected ErrSelfIntersecting, got %T", err)
+	}
+}
+
+func Test_CompoundCollides(t *testing.T) {
+	ledge, err := NewConcaveCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 10}, {30, 10}, {30, 20}, {0, 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledge.Position = r2.Point{100, 100}
+
+	// in the notch of the L, inside its bounding box but not the shape
+	if box := newBox(115, 102, 5, 5); ledge.Collides(box) || box.Collides(ledge) {
+		t.Errorf("CompoundCollider (notch): expected no collision")
+	}
+	if box := newBox(115, 108, 5, 5); !ledge.Collides(box) || !box.Collides(ledge) {
+		t.Errorf("CompoundCollider (lower arm): expected collision")
+	}
+
+	// a box falling into the notch lands on the lower arm
+	world := NewWorld(1.0/60, 32)
+	world.Add(ledge)
+	hit, ok := world.Raycast(r2.Point{120, 50}, r2.Point{0, 1}, 100, QueryAll)
+	if !ok || hit.Point != (r2.Point{120, 110}) {
+		t.Errorf("CompoundCollider (raycast): expected hit at (120, 110), got %v, %v", ok, hit)
+	}
+}
@@ -0,0 +1,262 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// skin is the distance below which colliders are considered to be touching
+// rather than overlapping
+const skin = 1e-6
+
+// Controller moves a PolyCollider through level terrain kinematically:
+// it slides along walls, steps up small ledges, sticks to slopes and
+// records what it touched during the last Move.
+// Coordinates follow the screen convention (y grows downward) unless Up is changed.
+type Controller struct {
+	*PolyCollider
+	Up            r2.Point // unit vector pointing away from gravity
+	MaxSlope      float64  // steepest surface (radians from Up) which still counts as floor
+	StepHeight    float64  // tallest ledge climbed without jumping
+	SnapDistance  float64  // how far to pull down onto slopes/stairs while walking
+	MaxIterations int      // most surfaces slid along per Move
+	DropThrough   bool     // fall through OneWay colliders while set
+
+	// contact state, updated by Move
+	Grounded    bool
+	OnCeiling   bool
+	OnWall      bool
+	Floor       Collider // the collider being stood on, if Grounded
+	FloorNormal r2.Point
+	WallNormal  r2.Point
+}
+
+// NewController returns a Controller for coll with sensible platformer defaults
+func NewController(coll *PolyCollider) *Controller {
+	return &Controller{
+		PolyCollider:  coll,
+		Up:            r2.Point{0, -1},
+		MaxSlope:      math.Pi / 4,
+		MaxIterations: 4,
+	}
+}
+
+// Move moves the controller's collider by velocity*dt through terrain, sliding along
+// whatever it hits.  It returns the velocity left over after collisions (e.g. with
+// the component into the floor removed), which is also stored in Velocity.
+// A grounded controller is first carried along by its Floor (see Platform), so
+// Move should be called once per World Step.
+func (c *Controller) Move(velocity r2.Point, dt float64, terrain []Collider) r2.Point {
+	wasGrounded := c.Grounded
+	if wasGrounded && c.Floor != nil {
+		ride(c.PolyCollider, c.Floor, c.FloorNormal, dt)
+	}
+	c.Grounded, c.OnCeiling, c.OnWall = false, false, false
+	c.Floor = nil
+	c.FloorNormal, c.WallNormal = r2.Point{}, r2.Point{}
+
+	c.depenetrate(terrain)
+
+	motion := velocity.Mul(dt)
+	for i := 0; i < c.MaxIterations && motion.Norm() > skin; i++ {
+		fraction, normal, other, hit := c.castAll(motion, terrain)
+		if !hit {
+			c.Position = c.Position.Add(motion)
+			break
+		}
+		c.Position = c.Position.Add(motion.Mul(fraction))
+		remaining := motion.Mul(1 - fraction)
+
+		switch c.classify(normal) {
+		case floorContact:
+			c.setFloor(other, normal)
+			if v := velocity.Dot(c.Up); v < 0 {
+				velocity = velocity.Sub(c.Up.Mul(v))
+			}
+			remaining = c.alongFloor(remaining, normal)
+		case ceilingContact:
+			c.OnCeiling = true
+			velocity = clipInto(velocity, normal)
+			remaining = clipInto(remaining, normal)
+		case wallContact:
+			if (wasGrounded || c.Grounded) && c.StepHeight > 0 && c.stepUp(remaining, terrain) {
+				remaining = r2.Point{}
+				break
+			}
+			c.OnWall = true
+			c.WallNormal = normal
+			velocity = clipInto(velocity, normal)
+			remaining = clipInto(remaining, normal)
+		}
+		motion = remaining
+	}
+
+	if wasGrounded && !c.Grounded && velocity.Dot(c.Up) <= 0 && c.SnapDistance > 0 {
+		c.snap(terrain)
+	}
+
+	c.Velocity = velocity
+	return velocity
+}
+
+type contactKind int
+
+const (
+	floorContact contactKind = iota
+	ceilingContact
+	wallContact
+)
+
+// classify sorts a surface normal into floor, ceiling or wall according to MaxSlope
+func (c *Controller) classify(normal r2.Point) contactKind {
+	up := normal.Dot(c.Up)
+	minFloorDot := math.Cos(c.MaxSlope)
+	switch {
+	case up >= minFloorDot:
+		return floorContact
+	case up <= -minFloorDot:
+		return ceilingContact
+	default:
+		return wallContact
+	}
+}
+
+func (c *Controller) setFloor(floor Collider, normal r2.Point) {
+	c.Grounded = true
+	c.Floor = floor
+	c.FloorNormal = normal
+}
+
+// clipInto removes the part of v which points into a surface with the given normal
+func clipInto(v, normal r2.Point) r2.Point {
+	if into := v.Dot(normal); into < 0 {
+		return v.Sub(normal.Mul(into))
+	}
+	return v
+}
+
+// alongFloor converts the remaining motion after landing into walking along the
+// floor: the vertical part is dropped, and the horizontal part is kept at the same
+// horizontal distance, so slopes don't slow the controller down or slide it off.
+func (c *Controller) alongFloor(motion, normal r2.Point) r2.Point {
+	lateral := motion.Sub(c.Up.Mul(motion.Dot(c.Up)))
+	if lateral.Norm() <= skin {
+		return r2.Point{}
+	}
+	tangent := normal.Ortho()
+	if tangent.Dot(lateral) < 0 {
+		tangent = tangent.Mul(-1)
+	}
+	return tangent.Mul(lateral.Norm() / tangent.Dot(lateral.Normalize()))
+}
+
+// castAll sweeps the collider along motion through terrain and returns the first hit
+func (c *Controller) castAll(motion r2.Point, terrain []Collider) (fraction float64, normal r2.Point, hitColl Collider, hit bool) {
+	fraction = 1
+	sweepS, sweepB := c.Bounds()
+	moveS, moveB := sweepS.Add(motion), sweepB.Add(motion)
+	sweepS = r2.Point{math.Min(sweepS.X, moveS.X), math.Min(sweepS.Y, moveS.Y)}
+	sweepB = r2.Point{math.Max(sweepB.X, moveB.X), math.Max(sweepB.Y, moveB.Y)}
+
+	for _, t := range terrain {
+		if t == Collider(c.PolyCollider) || t.GetFixture().Sensor || !CanCollide(c.PolyCollider, t) {
+			continue
+		}
+		if tS, tB := t.Bounds(); !boundsOverlap(sweepS, sweepB, tS, tB) {
+			continue
+		}
+		f, n, ok := cast(c.PolyCollider, t, motion)
+		if !ok || f >= fraction && hit {
+			continue
+		}
+		// one-way colliders only stop us if we land on them from above
+		if t.GetFixture().OneWay && (c.DropThrough || c.classify(n) != floorContact) {
+			continue
+		}
+		fraction, normal, hitColl, hit = f, n, t, true
+	}
+	return fraction, normal, hitColl, hit
+}
+
+// depenetrate pushes the collider out of any solid terrain it already overlaps,
+// e.g. after being teleported or pushed by something else.
+// OneWay colliders are ignored, since being inside one is allowed, as are Sensors.
+func (c *Controller) depenetrate(terrain []Collider) {
+	for iter := 0; iter < c.MaxIterations; iter++ {
+		collided := false
+		for _, t := range terrain {
+			if t == Collider(c.PolyCollider) || t.GetFixture().OneWay || t.GetFixture().Sensor {
+				continue
+			}
+			normal, depth, ok := penetrate(c.PolyCollider, t)
+			if !ok || depth <= skin {
+				continue
+			}
+			collided = true
+			switch c.classify(normal) {
+			case floorContact:
+				// push straight up so that standing on a slope doesn't slide us down it
+				c.Position = c.Position.Add(c.Up.Mul(depth / normal.Dot(c.Up)))
+				c.setFloor(t, normal)
+			case ceilingContact:
+				c.Position = c.Position.Add(normal.Mul(depth))
+				c.OnCeiling = true
+			case wallContact:
+				c.Position = c.Position.Add(normal.Mul(depth))
+				c.OnWall = true
+				c.WallNormal = normal
+			}
+		}
+		if !collided {
+			break
+		}
+	}
+}
+
+// stepUp attempts to climb onto a ledge: the collider is lifted by StepHeight,
+// moved by the horizontal part of motion, then lowered onto whatever is below.
+// The position is left unchanged (and false returned) if that doesn't end on a
+// floor higher than where it started.
+func (c *Controller) stepUp(motion r2.Point, terrain []Collider) bool {
+	start := c.Position
+
+	lift := c.Up.Mul(c.StepHeight)
+	if fraction, _, _, hit := c.castAll(lift, terrain); hit {
+		lift = lift.Mul(fraction)
+	}
+	c.Position = c.Position.Add(lift)
+
+	lateral := motion.Sub(c.Up.Mul(motion.Dot(c.Up)))
+	fraction, _, _, hit := c.castAll(lateral, terrain)
+	if hit && fraction*lateral.Norm() <= skin {
+		c.Position = start
+		return false
+	}
+	c.Position = c.Position.Add(lateral.Mul(fraction))
+
+	drop := c.Up.Mul(-(lift.Norm() + skin))
+	fraction, normal, floor, hit := c.castAll(drop, terrain)
+	if !hit || c.classify(normal) != floorContact {
+		c.Position = start
+		return false
+	}
+	c.Position = c.Position.Add(drop.Mul(fraction))
+	if c.Position.Sub(start).Dot(c.Up) <= skin {
+		c.Position = start
+		return false
+	}
+	c.setFloor(floor, normal)
+	return true
+}
+
+// snap pulls a walking controller down onto the ground it just left (e.g. at the
+// top of a slope or the edge of a stair), if that ground is within SnapDistance
+func (c *Controller) snap(terrain []Collider) {
+	drop := c.Up.Mul(-c.SnapDistance)
+	fraction, normal, floor, hit := c.castAll(drop, terrain)
+	if hit && c.classify(normal) == floorContact {
+		c.Position = c.Position.Add(drop.Mul(fraction))
+		c.setFloor(floor, normal)
+	}
+}
@@ -0,0 +1,119 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// newBox returns a w by h rectangular PolyCollider with its top left corner at (x, y)
+func newBox(x, y, w, h float64) *PolyCollider {
+	coll, err := NewPolyCollider([]*r2.Point{{0, 0}, {w, 0}, {w, h}, {0, h}})
+	if err != nil {
+		panic(err)
+	}
+	coll.Position = r2.Point{x, y}
+	return coll
+}
+
+func Test_ControllerLands(t *testing.T) {
+	floor := newBox(-100, 20, 200, 10)
+	ctrl := NewController(newBox(0, 0, 10, 10))
+	for i := 0; i < 60; i++ {
+		ctrl.Move(r2.Point{0, 300}, 1.0/60, []Collider{floor})
+	}
+	if !ctrl.Grounded || ctrl.Floor != Collider(floor) {
+		t.Errorf("Controller (landing): expected grounded on floor, got %v, %v", ctrl.Grounded, ctrl.Floor)
+	}
+	if math.Abs(ctrl.Position.Y-10) > 1e-6 {
+		t.Errorf("Controller (landing): expected y = 10, got %v", ctrl.Position.Y)
+	}
+	if ctrl.Velocity.Y != 0 {
+		t.Errorf("Controller (landing): expected velocity into floor removed, got %v", ctrl.Velocity)
+	}
+}
+
+func Test_ControllerSlidesAlongWall(t *testing.T) {
+	wall := newBox(20, -100, 10, 200)
+	ctrl := NewController(newBox(0, 0, 10, 10))
+	ctrl.Move(r2.Point{60, 60}, 0.5, []Collider{wall})
+	if !ctrl.OnWall || ctrl.WallNormal.X >= 0 {
+		t.Errorf("Controller (wall): expected wall contact facing -x, got %v, %v", ctrl.OnWall, ctrl.WallNormal)
+	}
+	if math.Abs(ctrl.Position.X-10) > 1e-6 || math.Abs(ctrl.Position.Y-30) > 1e-6 {
+		t.Errorf("Controller (wall): expected to slide to (10, 30), got %v", ctrl.Position)
+	}
+}
+
+func Test_ControllerStepsUp(t *testing.T) {
+	terrain := []Collider{newBox(-100, 10, 200, 10), newBox(20, 6, 100, 4)}
+	ctrl := NewController(newBox(0, 0, 10, 10))
+	ctrl.StepHeight = 5
+	ctrl.Move(r2.Point{0, 60}, 1.0/60, terrain)
+	for i := 0; i < 30; i++ {
+		ctrl.Move(r2.Point{60, 60}, 1.0/60, terrain)
+	}
+	if !ctrl.Grounded || math.Abs(ctrl.Position.Y+4) > 1e-6 || ctrl.Position.X < 20 {
+		t.Errorf("Controller (step): expected to stand on the ledge, got %v (grounded: %v)", ctrl.Position, ctrl.Grounded)
+	}
+
+	// the same ledge is too tall without StepHeight
+	ctrl = NewController(newBox(0, 0, 10, 10))
+	for i := 0; i < 30; i++ {
+		ctrl.Move(r2.Point{60, 60}, 1.0/60, terrain)
+	}
+	if ctrl.Position.X > 10+1e-6 {
+		t.Errorf("Controller (no step): expected to be stopped by the ledge, got %v", ctrl.Position)
+	}
+}
+
+func Test_ControllerOneWay(t *testing.T) {
+	platform := newBox(-100, 0, 200, 4)
+	platform.OneWay = true
+	ctrl := NewController(newBox(0, 10, 10, 10))
+
+	// jumping up through the platform
+	for i := 0; i < 10; i++ {
+		ctrl.Move(r2.Point{0, -120}, 1.0/60, []Collider{platform})
+	}
+	if ctrl.Position.Y > -10 || ctrl.OnCeiling {
+		t.Errorf("Controller (one-way, up): expected to pass through, got %v", ctrl.Position)
+	}
+
+	// falling back onto it
+	for i := 0; i < 30; i++ {
+		ctrl.Move(r2.Point{0, 120}, 1.0/60, []Collider{platform})
+	}
+	if !ctrl.Grounded || math.Abs(ctrl.Position.Y+10) > 1e-6 {
+		t.Errorf("Controller (one-way, down): expected to land on top, got %v", ctrl.Position)
+	}
+
+	// dropping through on purpose
+	ctrl.DropThrough = true
+	for i := 0; i < 30; i++ {
+		ctrl.Move(r2.Point{0, 120}, 1.0/60, []Collider{platform})
+	}
+	if ctrl.Position.Y < 4 {
+		t.Errorf("Controller (one-way, drop): expected to fall through, got %v", ctrl.Position)
+	}
+}
+
+func Test_ControllerSnapsToSlope(t *testing.T) {
+	// a 30 degree downhill slope starting at x = 0
+	slope, err := NewPolyCollider([]*r2.Point{{0, 0}, {200, 200 * math.Tan(math.Pi/6)}, {0, 200 * math.Tan(math.Pi/6)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slope.Position = r2.Point{0, 10}
+	terrain := []Collider{newBox(-100, 10, 100, 10), slope}
+	ctrl := NewController(newBox(-20, 0, 10, 10))
+	ctrl.SnapDistance = 8
+	ctrl.Move(r2.Point{0, 60}, 1.0/60, terrain)
+	for i := 0; i < 60; i++ {
+		ctrl.Move(r2.Point{120, 0}, 1.0/60, terrain)
+		if !ctrl.Grounded {
+			t.Fatalf("Controller (snap): left the ground at %v", ctrl.Position)
+		}
+	}
+}
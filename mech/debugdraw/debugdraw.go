@@ -0,0 +1,176 @@
+// Package debugdraw draws what a mech.World is doing over the top of a game:
+// collider outlines, bounding boxes, contact normals, broad phase cells and
+// raycasts.  Each can be switched on and off while the game runs.
+package debugdraw
+
+import (
+	"image/color"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+// Line is a segment to draw, in level space
+type Line struct {
+	From, To r2.Point
+	Color    color.Color
+}
+
+// Drawer draws a mech.World onto an ebiten.Image.  Nothing is drawn while
+// Enabled is unset, and the other flags pick what is drawn.
+type Drawer struct {
+	Enabled   bool
+	Colliders bool // outlines of each collider (and the parts of compound colliders)
+	Bounds    bool // bounding boxes
+	Contacts  bool // normals of the contacts found in the last Step
+	Cells     bool // broad phase cells holding colliders
+	Rays      bool // raycasts made through Raycast or added with AddRay since the last Draw
+
+	// LayerColors colours colliders by their Filter's Category: each collider takes
+	// the color of the lowest layer bit it's in which has one, or else ColliderColor
+	LayerColors   map[uint32]color.Color
+	ColliderColor color.Color
+	BoundsColor   color.Color
+	ContactColor  color.Color
+	CellColor     color.Color
+	RayColor      color.Color
+	HitColor      color.Color // of a ray's hit normal
+	NormalLength  float64     // length of drawn normals, in level units
+
+	rays []ray
+}
+
+// ray is a recorded raycast
+type ray struct {
+	origin, end r2.Point
+	hit         mech.RaycastHit
+	ok          bool
+}
+
+// New returns an enabled Drawer which draws everything but the broad phase cells
+func New() *Drawer {
+	return &Drawer{
+		Enabled:       true,
+		Colliders:     true,
+		Bounds:        true,
+		Contacts:      true,
+		Rays:          true,
+		LayerColors:   make(map[uint32]color.Color),
+		ColliderColor: color.RGBA{0x40, 0xe0, 0x40, 0xff},
+		BoundsColor:   color.RGBA{0x40, 0x40, 0xa0, 0xff},
+		ContactColor:  color.RGBA{0xff, 0x40, 0x40, 0xff},
+		CellColor:     color.RGBA{0x30, 0x30, 0x30, 0xff},
+		RayColor:      color.RGBA{0xe0, 0xe0, 0x40, 0xff},
+		HitColor:      color.RGBA{0xff, 0x80, 0x00, 0xff},
+		NormalLength:  8,
+	}
+}
+
+// Toggle switches drawing on or off
+func (d *Drawer) Toggle() {
+	d.Enabled = !d.Enabled
+}
+
+// Raycast casts a ray through world (see mech.World.Raycast), and records it to be drawn
+func (d *Drawer) Raycast(world *mech.World, origin, dir r2.Point, maxDist float64, filter mech.Filter) (mech.RaycastHit, bool) {
+	hit, ok := world.Raycast(origin, dir, maxDist, filter)
+	d.AddRay(origin, dir, maxDist, hit, ok)
+	return hit, ok
+}
+
+// AddRay records a ray cast some other way to be drawn, along with what it hit (if ok)
+func (d *Drawer) AddRay(origin, dir r2.Point, maxDist float64, hit mech.RaycastHit, ok bool) {
+	end := origin.Add(dir.Mul(maxDist))
+	if ok {
+		end = hit.Point
+	}
+	d.rays = append(d.rays, ray{origin, end, hit, ok})
+}
+
+// colorOf returns the color c is drawn in (see LayerColors)
+func (d *Drawer) colorOf(c mech.Collider) color.Color {
+	category := c.GetFixture().Category
+	for bit := uint(0); bit < 32; bit++ {
+		layer := uint32(1) << bit
+		if category&layer == 0 {
+			continue
+		}
+		if clr, ok := d.LayerColors[layer]; ok {
+			return clr
+		}
+	}
+	return d.ColliderColor
+}
+
+// Lines returns the lines Draw would draw for world, back to front
+func (d *Drawer) Lines(world *mech.World) []Line {
+	if !d.Enabled {
+		return nil
+	}
+	var lines []Line
+	if d.Cells {
+		for _, cell := range world.BroadPhaseCells() {
+			lines = box(lines, cell[0], cell[1], d.CellColor)
+		}
+	}
+	if d.Bounds {
+		for _, c := range world.Colliders() {
+			min, max := c.Bounds()
+			lines = box(lines, min, max, d.BoundsColor)
+		}
+	}
+	if d.Colliders {
+		for _, c := range world.Colliders() {
+			clr := d.colorOf(c)
+			for _, part := range mech.Outline(c) {
+				if len(part) == 2 {
+					lines = append(lines, Line{part[0], part[1], clr})
+					continue
+				}
+				for i := range part {
+					lines = append(lines, Line{part[i], part[(i+1)%len(part)], clr})
+				}
+			}
+		}
+	}
+	if d.Contacts {
+		for _, contact := range world.Contacts() {
+			// the bodies have been pushed apart, so meet about here
+			_, point, _ := mech.Distance(contact.A, contact.B)
+			lines = append(lines, Line{point, point.Add(contact.Normal.Mul(d.NormalLength)), d.ContactColor})
+		}
+	}
+	if d.Rays {
+		for _, r := range d.rays {
+			lines = append(lines, Line{r.origin, r.end, d.RayColor})
+			if r.ok {
+				lines = append(lines, Line{r.end, r.end.Add(r.hit.Normal.Mul(d.NormalLength)), d.HitColor})
+			}
+		}
+	}
+	return lines
+}
+
+// box appends the edges of the box (min, max) to lines
+func box(lines []Line, min, max r2.Point, clr color.Color) []Line {
+	corners := [4]r2.Point{min, {max.X, min.Y}, max, {min.X, max.Y}}
+	for i := range corners {
+		lines = append(lines, Line{corners[i], corners[(i+1)%4], clr})
+	}
+	return lines
+}
+
+// Draw draws world onto dst, placing the level with camera (as the game draws its
+// sprites), then forgets the recorded rays
+func (d *Drawer) Draw(dst *ebiten.Image, world *mech.World, camera ebiten.GeoM) {
+	for _, line := range d.Lines(world) {
+		x1, y1 := camera.Apply(line.From.X, line.From.Y)
+		x2, y2 := camera.Apply(line.To.X, line.To.Y)
+		ebitenutil.DrawLine(dst, x1, y1, x2, y2, line.Color)
+	}
+	d.rays = d.rays[:0]
+}
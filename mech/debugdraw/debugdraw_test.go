@@ -0,0 +1,151 @@
+package debugdraw
+
+import (
+	"flag"
+	"image/color"
+	"log"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+var interactive = flag.Bool("interactive", false, "open a window showing the debug overlay")
+
+func newBox(x, y, w, h float64) *mech.PolyCollider {
+	coll, err := mech.NewPolyCollider([]*r2.Point{{0, 0}, {w, 0}, {w, h}, {0, h}})
+	if err != nil {
+		panic(err)
+	}
+	coll.Position = r2.Point{x, y}
+	return coll
+}
+
+// newWorld returns a World with a floor, a crate sunk a little into it and a slope
+func newWorld() (*mech.World, *mech.PolyCollider, *mech.PolyCollider) {
+	world := mech.NewWorld(1.0/60, 32)
+	world.Gravity = r2.Point{0, 500}
+	floor := newBox(0, 100, 200, 20)
+	floor.Kind = mech.Static
+	world.Add(floor)
+	crate := newBox(40, 82, 20, 20)
+	crate.Mass = 1
+	world.Add(crate)
+	slope, err := mech.NewChainCollider([]*r2.Point{{200, 100}, {300, 40}}, false)
+	if err != nil {
+		panic(err)
+	}
+	slope.Kind = mech.Static
+	world.Add(slope)
+	return world, floor, crate
+}
+
+// count returns the number of lines of the given color
+func count(lines []Line, clr color.Color) int {
+	n := 0
+	for _, line := range lines {
+		if line.Color == clr {
+			n++
+		}
+	}
+	return n
+}
+
+func Test_Lines(t *testing.T) {
+	world, _, crate := newWorld()
+	world.Step()
+	d := New()
+	enemies := color.RGBA{0xff, 0, 0xff, 0xff}
+	crate.Category = 0x2 | 0x8
+	d.LayerColors[0x8] = color.RGBA{0, 0xff, 0xff, 0xff}
+	d.LayerColors[0x2] = enemies
+	if hit, ok := d.Raycast(world, r2.Point{50, 0}, r2.Point{0, 1}, 200, mech.DefaultFilter); !ok || hit.Collider != crate {
+		t.Fatalf("Drawer.Raycast: expected to hit the crate, got %v", hit.Collider)
+	}
+	d.AddRay(r2.Point{-50, 0}, r2.Point{0, 1}, 50, mech.RaycastHit{}, false)
+
+	lines := d.Lines(world)
+	cases := []struct {
+		name     string
+		clr      color.Color
+		expected int
+	}{
+		{"floor and slope", d.ColliderColor, 5},
+		{"crate in its lowest colored layer", enemies, 4},
+		{"bounds", d.BoundsColor, 12},
+		{"contacts", d.ContactColor, len(world.Contacts())},
+		{"rays", d.RayColor, 2},
+		{"hit normal", d.HitColor, 1},
+		{"cells", d.CellColor, 0},
+	}
+	for _, c := range cases {
+		if got := count(lines, c.clr); got != c.expected {
+			t.Errorf("Drawer.Lines (%s): expected %d lines, got %d", c.name, c.expected, got)
+		}
+	}
+	if len(world.Contacts()) == 0 {
+		t.Errorf("Drawer.Lines (contacts): expected the crate to touch the floor")
+	}
+	for _, line := range lines {
+		if line.Color == d.RayColor && line.From == (r2.Point{50, 0}) && line.To.Sub(r2.Point{50, crate.Position.Y}).Norm() > 1e-6 {
+			t.Errorf("Drawer.Lines (rays): expected ray to stop at the crate, got %v", line.To)
+		}
+	}
+
+	d.Cells = true
+	if got := count(d.Lines(world), d.CellColor); got != 4*len(world.BroadPhaseCells()) || got == 0 {
+		t.Errorf("Drawer.Lines (cells): expected 4 lines per cell, got %d", got)
+	}
+	d.Toggle()
+	if lines := d.Lines(world); len(lines) != 0 {
+		t.Errorf("Drawer.Toggle: expected nothing drawn, got %d lines", len(lines))
+	}
+}
+
+// demo is the state of Test_Interactive
+type demo struct {
+	world  *mech.World
+	drawer *Drawer
+}
+
+func (g *demo) update(screen *ebiten.Image) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyD):
+		g.drawer.Toggle()
+	case inpututil.IsKeyJustPressed(ebiten.KeyB):
+		g.drawer.Bounds = !g.drawer.Bounds
+	case inpututil.IsKeyJustPressed(ebiten.KeyC):
+		g.drawer.Cells = !g.drawer.Cells
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		crate := newBox(100, -20, 16, 16)
+		crate.Mass = 1
+		crate.Category = 0x2
+		g.world.Add(crate)
+	}
+	g.world.Update(1.0 / 60)
+	x, y := ebiten.CursorPosition()
+	g.drawer.Raycast(g.world, r2.Point{float64(x), float64(y)}, r2.Point{0, 1}, 240, mech.DefaultFilter)
+	if ebiten.IsDrawingSkipped() {
+		return nil
+	}
+	g.drawer.Draw(screen, g.world, ebiten.GeoM{})
+	ebitenutil.DebugPrint(screen, "D: overlay  B: bounds  C: cells  space: crate")
+	return nil
+}
+
+func Test_Interactive(t *testing.T) {
+	if !*interactive {
+		t.Skip("run with -interactive to open the demo")
+	}
+	world, _, _ := newWorld()
+	g := &demo{world, New()}
+	g.drawer.LayerColors[0x2] = color.RGBA{0xff, 0, 0xff, 0xff}
+	if err := ebiten.Run(g.update, 400, 240, 2, "Debug Draw"); err != nil {
+		log.Fatal(err)
+	}
+}
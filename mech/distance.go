@@ -0,0 +1,243 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// Distance queries work on the same convex parts as the narrow phase, using
+// GJK: the distance between two convex polygons is the distance from the origin
+// to their Minkowski difference, which GJK closes in on one support point at a time.
+// idea: https://box2d.org/files/ErinCatto_GJK_GDC2010.pdf
+// These are purely geometric: Filters, Sensors and one-sidedness are ignored.
+
+// gjkIterations bounds the GJK loop, which normally finishes in a handful
+const gjkIterations = 32
+
+// Distance returns how far apart a and b are, and the nearest point of each.
+// Colliders which overlap (or touch) are 0 apart, and then pointA and pointB are
+// the same point, which is inside both.
+func Distance(a, b Collider) (dist float64, pointA, pointB r2.Point) {
+	dist = math.Inf(1)
+	bParts := convexParts(b)
+	for _, aPart := range convexParts(a) {
+		for _, bPart := range bParts {
+			d, pa, pb := aPart.distance(bPart)
+			if d < dist {
+				dist, pointA, pointB = d, pa, pb
+			}
+			if dist == 0 {
+				return dist, pointA, pointB
+			}
+		}
+	}
+	return dist, pointA, pointB
+}
+
+// ClosestPoint returns the point on the outline of c nearest to p, and how far
+// away it is.  This works from inside c too, e.g. for finding the nearest way out.
+// The seams between the parts of a CompoundCollider aren't part of its outline.
+func ClosestPoint(c Collider, p r2.Point) (closest r2.Point, dist float64) {
+	dist = math.Inf(1)
+	parts := convexParts(c)
+	for i, part := range parts {
+		for j := range part {
+			if len(part) == 2 && j == 1 {
+				break // a segment's only edge
+			}
+			q := closestOnSegment(part.vertex(j), part.vertex(j+1), p)
+			if d := q.Sub(p).Norm(); d < dist && !onSeam(parts, i, q, part.faceNormal(j)) {
+				closest, dist = q, d
+			}
+		}
+	}
+	return closest, dist
+}
+
+// onSeam returns whether q, on the edge of parts[i] facing normal, is covered by
+// another part: just outside the edge is still inside the collider
+func onSeam(parts []convex, i int, q, normal r2.Point) bool {
+	outside := q.Add(normal.Mul(skin))
+	for j, part := range parts {
+		if j != i && part.contains(outside, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether p is inside (or on the edge of) c.
+// A looping ChainCollider contains the points on its solid side (so a loop around
+// a hole contains everything outside the hole); an open one contains nothing.
+func Contains(c Collider, p r2.Point) bool {
+	if chain, isChain := c.(*ChainCollider); isChain {
+		return chain.contains(p)
+	}
+	for _, part := range convexParts(c) {
+		if part.contains(p, skin) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns whether p is on the solid side of a looping chain
+func (c *ChainCollider) contains(p r2.Point) bool {
+	if !c.Loop {
+		return false
+	}
+	edges := c.worldEdges()
+	if len(edges) < 3 {
+		return false
+	}
+	// count crossings of a ray from p toward +x
+	inside := false
+	var area float64
+	for _, e := range edges {
+		if (e.v1.Y > p.Y) != (e.v2.Y > p.Y) {
+			x := e.v1.X + (p.Y-e.v1.Y)/(e.v2.Y-e.v1.Y)*(e.v2.X-e.v1.X)
+			if x > p.X {
+				inside = !inside
+			}
+		}
+		area += e.v1.Cross(e.v2)
+	}
+	// going clockwise on screen (positive area, y down), the solid side is inside
+	return inside == (area > 0)
+}
+
+// contains returns whether q is inside p, or no further than margin outside it
+// (a negative margin requires q to be that far inside)
+func (p convex) contains(q r2.Point, margin float64) bool {
+	if len(p) < 3 {
+		return closestOnSegment(p.vertex(0), p.vertex(1), q).Sub(q).Norm() <= margin
+	}
+	for i := range p {
+		if p.faceNormal(i).Dot(q.Sub(p[i])) > margin {
+			return false
+		}
+	}
+	return true
+}
+
+// closestOnSegment returns the point on the segment from a to b nearest to p
+func closestOnSegment(a, b, p r2.Point) r2.Point {
+	ab := b.Sub(a)
+	length := ab.Dot(ab)
+	if length == 0 {
+		return a
+	}
+	t := math.Max(0, math.Min(1, p.Sub(a).Dot(ab)/length))
+	return a.Add(ab.Mul(t))
+}
+
+// == GJK ========
+
+// simplexVertex is a point of the Minkowski difference p - other, with the
+// vertices of each polygon it came from and its weight in the closest point
+type simplexVertex struct {
+	a, b, w r2.Point
+	u       float64
+}
+
+// support returns the vertex of p furthest along dir
+func (p convex) support(dir r2.Point) r2.Point {
+	best, bestDot := p[0], p[0].Dot(dir)
+	for _, v := range p[1:] {
+		if d := v.Dot(dir); d > bestDot {
+			best, bestDot = v, d
+		}
+	}
+	return best
+}
+
+// distance returns how far apart p and other are, and the nearest point of each
+// (see Distance)
+func (p convex) distance(other convex) (dist float64, pointP, pointOther r2.Point) {
+	simplex := []simplexVertex{{a: p[0], b: other[0], w: p[0].Sub(other[0]), u: 1}}
+	for i := 0; i < gjkIterations; i++ {
+		var closest r2.Point
+		simplex, closest = reduceSimplex(simplex)
+		if len(simplex) == 3 || closest.Norm() < 1e-12 {
+			// the origin is inside the Minkowski difference: they overlap
+			pointP, pointOther = witness(simplex)
+			return 0, pointP, pointP
+		}
+		dir := closest.Mul(-1)
+		a, b := p.support(dir), other.support(dir.Mul(-1))
+		w := a.Sub(b)
+		// stop once the new support point gets no closer to the origin
+		if w.Dot(dir)-closest.Dot(dir) <= 1e-10*(1+closest.Dot(closest)) {
+			break
+		}
+		simplex = append(simplex, simplexVertex{a: a, b: b, w: w})
+	}
+	pointP, pointOther = witness(simplex)
+	return pointP.Sub(pointOther).Norm(), pointP, pointOther
+}
+
+// witness returns the points of each polygon making up the simplex's closest point
+func witness(simplex []simplexVertex) (a, b r2.Point) {
+	for _, v := range simplex {
+		a = a.Add(v.a.Mul(v.u))
+		b = b.Add(v.b.Mul(v.u))
+	}
+	return a, b
+}
+
+// reduceSimplex finds the point of the simplex nearest the origin, and cuts the
+// simplex down to the smallest part of it (vertex, edge, or whole triangle if the
+// origin is inside) containing that point, setting each vertex's weight u
+func reduceSimplex(simplex []simplexVertex) ([]simplexVertex, r2.Point) {
+	switch len(simplex) {
+	case 1:
+		simplex[0].u = 1
+		return simplex, simplex[0].w
+	case 2:
+		return reduceSegment(simplex[0], simplex[1])
+	}
+
+	s0, s1, s2 := simplex[0], simplex[1], simplex[2]
+	e1, e2 := s1.w.Sub(s0.w), s2.w.Sub(s0.w)
+	area := e1.Cross(e2)
+	if area != 0 {
+		// barycentric weights of the origin
+		u1 := s0.w.Mul(-1).Cross(e2) / area
+		u2 := e1.Cross(s0.w.Mul(-1)) / area
+		if u1 >= 0 && u2 >= 0 && u1+u2 <= 1 {
+			s0.u, s1.u, s2.u = 1-u1-u2, u1, u2
+			return []simplexVertex{s0, s1, s2}, r2.Point{}
+		}
+	}
+	// otherwise the nearest point is on one of the edges
+	var best []simplexVertex
+	var bestPoint r2.Point
+	for _, pair := range [3][2]simplexVertex{{s0, s1}, {s1, s2}, {s2, s0}} {
+		reduced, point := reduceSegment(pair[0], pair[1])
+		if best == nil || point.Norm() < bestPoint.Norm() {
+			best, bestPoint = reduced, point
+		}
+	}
+	return best, bestPoint
+}
+
+// reduceSegment is reduceSimplex for a simplex of two vertices
+func reduceSegment(s0, s1 simplexVertex) ([]simplexVertex, r2.Point) {
+	e := s1.w.Sub(s0.w)
+	length := e.Dot(e)
+	t := 0.0
+	if length > 0 {
+		t = s0.w.Mul(-1).Dot(e) / length
+	}
+	switch {
+	case t <= 0:
+		s0.u = 1
+		return []simplexVertex{s0}, s0.w
+	case t >= 1:
+		s1.u = 1
+		return []simplexVertex{s1}, s1.w
+	}
+	s0.u, s1.u = 1-t, t
+	return []simplexVertex{s0, s1}, s0.w.Add(e.Mul(t))
+}
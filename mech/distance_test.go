@@ -0,0 +1,140 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_Distance(t *testing.T) {
+	turned := newBox(30, 0, 10, 10)
+	turned.Angle = math.Pi / 4
+	floor, err := NewChainCollider([]*r2.Point{{-100, 50}, {100, 50}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		a, b Collider
+		dist float64
+		// the nearest points, where only one pair is nearest (not between parallel sides)
+		unique         bool
+		pointA, pointB r2.Point
+	}{
+		{"side by side", newBox(0, 0, 10, 10), newBox(15, 0, 10, 10), 5, false, r2.Point{}, r2.Point{}},
+		{"diagonal", newBox(0, 0, 10, 10), newBox(13, 14, 10, 10), 5, true, r2.Point{10, 10}, r2.Point{13, 14}},
+		{"touching", newBox(0, 0, 10, 10), newBox(10, 0, 10, 10), 0, false, r2.Point{}, r2.Point{}},
+		// the turned box's left corner is at x = 30 - 10/√2
+		{"turned corner", newBox(0, 0, 10, 10), turned, 20 - 10/math.Sqrt2, true, r2.Point{10, 10 / math.Sqrt2}, r2.Point{30 - 10/math.Sqrt2, 10 / math.Sqrt2}},
+		{"chain", newBox(0, 0, 10, 10), floor, 40, false, r2.Point{}, r2.Point{}},
+	}
+	for _, c := range cases {
+		dist, pointA, pointB := Distance(c.a, c.b)
+		if math.Abs(dist-c.dist) > 1e-9 {
+			t.Errorf("Distance (%s): expected %v, got %v", c.name, c.dist, dist)
+		}
+		if math.Abs(pointB.Sub(pointA).Norm()-dist) > 1e-9 {
+			t.Errorf("Distance (%s): expected points %v apart, got %v, %v", c.name, dist, pointA, pointB)
+		}
+		if _, off := ClosestPoint(c.a, pointA); dist > 0 && off > 1e-9 {
+			t.Errorf("Distance (%s): expected point %v on the outline of a", c.name, pointA)
+		}
+		if _, off := ClosestPoint(c.b, pointB); dist > 0 && off > 1e-9 {
+			t.Errorf("Distance (%s): expected point %v on the outline of b", c.name, pointB)
+		}
+		if c.unique && (!near(pointA, c.pointA) || !near(pointB, c.pointB)) {
+			t.Errorf("Distance (%s): expected points %v, %v, got %v, %v", c.name, c.pointA, c.pointB, pointA, pointB)
+		}
+	}
+}
+
+func Test_DistanceOverlapping(t *testing.T) {
+	a, b := newBox(0, 0, 10, 10), newBox(5, 5, 10, 10)
+	dist, pointA, pointB := Distance(a, b)
+	if dist != 0 || pointA != pointB {
+		t.Errorf("Distance (overlapping): expected 0 and a shared point, got %v, %v, %v", dist, pointA, pointB)
+	}
+	if !Contains(a, pointA) || !Contains(b, pointA) {
+		t.Errorf("Distance (overlapping): expected %v to be inside both", pointA)
+	}
+}
+
+func Test_ClosestPoint(t *testing.T) {
+	box := newBox(0, 0, 10, 10)
+	cases := []struct {
+		name string
+		c    Collider
+		p    r2.Point
+		want r2.Point
+		dist float64
+	}{
+		{"outside", box, r2.Point{20, 5}, r2.Point{10, 5}, 10},
+		{"past corner", box, r2.Point{13, -4}, r2.Point{10, 0}, 5},
+		{"inside", box, r2.Point{2, 5}, r2.Point{0, 5}, 2},
+	}
+	// an L shape, whose parts meet along a seam which isn't part of its outline
+	ell, err := NewConcaveCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 20}, {20, 20}, {20, 30}, {0, 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases = append(cases, struct {
+		name string
+		c    Collider
+		p    r2.Point
+		want r2.Point
+		dist float64
+	}{"compound seam", ell, r2.Point{8, 24}, r2.Point{8, 30}, 6})
+
+	for _, c := range cases {
+		got, dist := ClosestPoint(c.c, c.p)
+		if !near(got, c.want) || math.Abs(dist-c.dist) > 1e-9 {
+			t.Errorf("ClosestPoint (%s): expected %v at %v, got %v at %v", c.name, c.want, c.dist, got, dist)
+		}
+	}
+}
+
+func Test_Contains(t *testing.T) {
+	box := newBox(0, 0, 10, 10)
+	box.Angle = math.Pi / 4
+	ell, err := NewConcaveCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 20}, {20, 20}, {20, 30}, {0, 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// clockwise on screen: solid inside
+	block, err := NewChainCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// anticlockwise: a hole, solid outside
+	hole, err := NewChainCollider([]*r2.Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := NewChainCollider([]*r2.Point{{0, 0}, {10, 0}, {10, 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		c    Collider
+		p    r2.Point
+		want bool
+	}{
+		{"turned box, inside", box, r2.Point{0, 7}, true},
+		{"turned box, corner cut off", box, r2.Point{5, 1}, false},
+		{"turned box, on edge", box, r2.Point{0, 0}, true},
+		{"L, inside", ell, r2.Point{15, 25}, true},
+		{"L, notch", ell, r2.Point{15, 15}, false},
+		{"block, inside", block, r2.Point{5, 5}, true},
+		{"block, outside", block, r2.Point{15, 5}, false},
+		{"hole, inside", hole, r2.Point{5, 5}, false},
+		{"hole, outside", hole, r2.Point{15, 5}, true},
+		{"open chain", open, r2.Point{5, 5}, false},
+	}
+	for _, c := range cases {
+		if got := Contains(c.c, c.p); got != c.want {
+			t.Errorf("Contains (%s): expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
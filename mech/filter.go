@@ -0,0 +1,55 @@
+package mech
+
+import "math"
+
+// Filter decides which colliders interact with each other.
+//
+// Each collider belongs to the layers set in Category, and only interacts with
+// colliders whose Category overlaps its Mask (this must hold both ways).
+// Colliders sharing a nonzero Group skip the layer check: a positive Group always
+// interacts and a negative Group never does (e.g. the parts of one ragdoll).
+// ShouldCollide, if set, is asked last and may veto any pair.
+type Filter struct {
+	Category uint32
+	Mask     uint32
+	Group    int
+	// ShouldCollide is called with the collider owning this Filter and the other one.
+	// For queries (Query, Raycast) self is nil.
+	ShouldCollide func(self, other Collider) bool
+}
+
+// DefaultFilter puts a collider in the first layer and lets it interact with every layer.
+// It's given to colliders by their constructors.
+var DefaultFilter = Filter{Category: 1, Mask: math.MaxUint32}
+
+// QueryAll is a query Filter matching every collider
+var QueryAll = Filter{Category: math.MaxUint32, Mask: math.MaxUint32}
+
+// allows returns whether filters f and other (belonging to self and otherColl)
+// let their colliders interact.  self is nil when f belongs to a query.
+func (f *Filter) allows(self Collider, other *Filter, otherColl Collider) bool {
+	if f.Group != 0 && f.Group == other.Group {
+		if f.Group < 0 {
+			return false
+		}
+	} else if f.Mask&other.Category == 0 || other.Mask&f.Category == 0 {
+		return false
+	}
+	if f.ShouldCollide != nil && !f.ShouldCollide(self, otherColl) {
+		return false
+	}
+	if self != nil && other.ShouldCollide != nil && !other.ShouldCollide(otherColl, self) {
+		return false
+	}
+	return true
+}
+
+// CanCollide returns whether the Filters of a and b let them interact
+func CanCollide(a, b Collider) bool {
+	return a.GetFixture().Filter.allows(a, &b.GetFixture().Filter, b)
+}
+
+// matches returns whether a query using Filter f should see c
+func (f *Filter) matches(c Collider) bool {
+	return f.allows(nil, &c.GetFixture().Filter, c)
+}
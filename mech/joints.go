@@ -0,0 +1,538 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// Joints connect two colliders' bodies, and are solved alongside contacts (see
+// solver.go): each constraint becomes impulses which stop the bodies' velocities
+// from breaking it, plus a little extra to correct whatever error has built up.
+// Anchors are kept relative to each collider (like Vertices), so they turn and
+// move with it.
+
+// Joint is a constraint between the bodies of two colliders, added to a World
+// with AddJoint.  B may be nil, fixing the joint to the level instead.
+type Joint interface {
+	Colliders() (a, b Collider)
+	base() *jointBase
+	prepare(dt float64)   // work out the constraint for this Step and apply last Step's impulses
+	solve()               // one solver iteration
+	impulses() []*float64 // accumulated impulses kept between Steps, for snapshots
+}
+
+// jointBase holds what every Joint has in common
+type jointBase struct {
+	A, B             Collider
+	CollideConnected bool // whether A and B still collide with each other
+
+	bodyA, bodyB *Body
+	massA, massB solverMass
+}
+
+// Colliders returns the colliders connected by the joint (b may be nil)
+func (j *jointBase) Colliders() (a, b Collider) {
+	return j.A, j.B
+}
+
+func (j *jointBase) base() *jointBase {
+	return j
+}
+
+// bind looks up the bodies and masses of A and B for the coming Step
+func (j *jointBase) bind(massOf func(Collider) solverMass) {
+	j.bodyA, j.massA = &j.A.GetFixture().Body, massOf(j.A)
+	if j.B == nil {
+		j.bodyB, j.massB = &Body{Kind: Static}, solverMass{}
+	} else {
+		j.bodyB, j.massB = &j.B.GetFixture().Body, massOf(j.B)
+	}
+}
+
+// localAnchors returns the points of A and B, given in level space, relative to each
+func localAnchors(a, b Collider, anchorA, anchorB r2.Point) (localA, localB r2.Point) {
+	localA = a.GetFixture().Local(anchorA)
+	localB = anchorB
+	if b != nil {
+		localB = b.GetFixture().Local(anchorB)
+	}
+	return localA, localB
+}
+
+// anchors returns the level space positions of the local anchors of A and B, and
+// their offsets from each body's centre of mass
+func (j *jointBase) anchors(localA, localB r2.Point) (pA, pB, rA, rB r2.Point) {
+	pA, pB = j.bodyA.Apply(localA), j.bodyB.Apply(localB)
+	return pA, pB, pA.Sub(j.massA.centre), pB.Sub(j.massB.centre)
+}
+
+// angle returns how far B is turned relative to A
+func (j *jointBase) angle() float64 {
+	return j.bodyB.Angle - j.bodyA.Angle
+}
+
+// spin returns how fast B is turning relative to A
+func (j *jointBase) spin() float64 {
+	var spinA, spinB float64
+	if j.bodyA.Kind != Static {
+		spinA = j.bodyA.AngularVelocity
+	}
+	if j.bodyB.Kind != Static {
+		spinB = j.bodyB.AngularVelocity
+	}
+	return spinB - spinA
+}
+
+// applyPoint gives B impulse at rB, and A the opposite at rA
+func (j *jointBase) applyPoint(rA, rB, impulse r2.Point) {
+	applyImpulse(j.bodyA, j.massA, rA, impulse.Mul(-1))
+	applyImpulse(j.bodyB, j.massB, rB, impulse)
+}
+
+// applyAngular gives B an angular impulse, and A the opposite
+func (j *jointBase) applyAngular(impulse float64) {
+	j.bodyA.AngularVelocity -= j.massA.invInertia * impulse
+	j.bodyB.AngularVelocity += j.massB.invInertia * impulse
+}
+
+// angularMass returns the effective mass the bodies present to angular impulses
+func (j *jointBase) angularMass() float64 {
+	return invert(j.massA.invInertia + j.massB.invInertia)
+}
+
+// invert returns 1/k, or 0 for 0 (an immovable constraint)
+func invert(k float64) float64 {
+	if k == 0 {
+		return 0
+	}
+	return 1 / k
+}
+
+// row is a scalar constraint on the velocity of B relative to A along axis, at
+// points rA and rB from their centres of mass
+type row struct {
+	axis    r2.Point
+	rA, rB  r2.Point
+	mass    float64 // effective mass along axis
+	impulse float64 // accumulated, and kept between Steps for warm starting
+}
+
+// setRow points r along axis through rA and rB, and works out its effective mass
+func (j *jointBase) setRow(r *row, axis, rA, rB r2.Point) {
+	rnA, rnB := rA.Cross(axis), rB.Cross(axis)
+	r.axis, r.rA, r.rB = axis, rA, rB
+	r.mass = invert(j.massA.invMass + j.massB.invMass + j.massA.invInertia*rnA*rnA + j.massB.invInertia*rnB*rnB)
+}
+
+// velocity returns the speed at which B moves away from A along the row
+func (j *jointBase) velocity(r *row) float64 {
+	return pointVelocity(j.bodyB, r.rB).Sub(pointVelocity(j.bodyA, r.rA)).Dot(r.axis)
+}
+
+// push applies an impulse of size lambda along the row
+func (j *jointBase) push(r *row, lambda float64) {
+	j.applyPoint(r.rA, r.rB, r.axis.Mul(lambda))
+}
+
+// pointMatrix returns the matrix turning an impulse at rA and rB into the change
+// in relative velocity of those points (its inverse gives the impulse needed)
+func (j *jointBase) pointMatrix(rA, rB r2.Point) (k11, k12, k22 float64) {
+	m, iA, iB := j.massA.invMass+j.massB.invMass, j.massA.invInertia, j.massB.invInertia
+	k11 = m + iA*rA.Y*rA.Y + iB*rB.Y*rB.Y
+	k12 = -iA*rA.X*rA.Y - iB*rB.X*rB.Y
+	k22 = m + iA*rA.X*rA.X + iB*rB.X*rB.X
+	return k11, k12, k22
+}
+
+// solvePoint returns the impulse which changes the relative velocity of the
+// points by -v, for the matrix from pointMatrix
+func solvePoint(k11, k12, k22 float64, v r2.Point) r2.Point {
+	det := invert(k11*k22 - k12*k12)
+	return r2.Point{-(k22*v.X - k12*v.Y) * det, -(k11*v.Y - k12*v.X) * det}
+}
+
+// correction returns the velocity which corrects offset (of a constraint which
+// should be 0) over the coming Steps
+func correction(offset, dt float64) float64 {
+	return baumgarte / dt * offset
+}
+
+// solveBound returns the impulse change for a one-sided constraint whose value
+// must stay at or above 0, and is currently gap with speed velocity; accumulated
+// is its total impulse so far, which may only push.  A positive gap is allowed
+// to close within the Step.
+func solveBound(gap, velocity, mass, dt float64, accumulated *float64) float64 {
+	target := correction(gap, dt)
+	if gap > 0 {
+		target = gap / dt
+	}
+	total := math.Max(0, *accumulated-mass*(velocity+target))
+	delta := total - *accumulated
+	*accumulated = total
+	return delta
+}
+
+// clampAdd adds lambda to accumulated, clamped to ±limit, and returns the
+// change actually made
+func clampAdd(accumulated *float64, lambda, limit float64) float64 {
+	total := math.Max(-limit, math.Min(limit, *accumulated+lambda))
+	delta := total - *accumulated
+	*accumulated = total
+	return delta
+}
+
+// == Distance Joint ========
+
+// DistanceJoint keeps its anchors between MinLength and MaxLength apart: equal
+// for a rigid rod, or from 0 for a rope
+type DistanceJoint struct {
+	jointBase
+	AnchorA, AnchorB     r2.Point // relative to A and B
+	MinLength, MaxLength float64
+
+	dt           float64
+	length       float64
+	axis         row
+	lower, upper float64 // accumulated limit impulses
+}
+
+// NewDistanceJoint connects a and b (nil for the level) by a rigid rod between
+// anchorA and anchorB, given in level space
+func NewDistanceJoint(a, b Collider, anchorA, anchorB r2.Point) *DistanceJoint {
+	j := &DistanceJoint{jointBase: jointBase{A: a, B: b}}
+	j.AnchorA, j.AnchorB = localAnchors(a, b, anchorA, anchorB)
+	j.MinLength = anchorB.Sub(anchorA).Norm()
+	j.MaxLength = j.MinLength
+	return j
+}
+
+func (j *DistanceJoint) prepare(dt float64) {
+	pA, pB, rA, rB := j.anchors(j.AnchorA, j.AnchorB)
+	d := pB.Sub(pA)
+	j.dt, j.length = dt, d.Norm()
+	axis := r2.Point{1, 0}
+	if j.length > 0 {
+		axis = d.Mul(1 / j.length)
+	}
+	j.setRow(&j.axis, axis, rA, rB)
+	if j.MinLength != j.MaxLength {
+		j.axis.impulse = 0
+	} else {
+		j.lower, j.upper = 0, 0
+	}
+	j.push(&j.axis, j.axis.impulse+j.lower-j.upper)
+}
+
+func (j *DistanceJoint) impulses() []*float64 {
+	return []*float64{&j.axis.impulse, &j.lower, &j.upper}
+}
+
+func (j *DistanceJoint) solve() {
+	velocity := j.velocity(&j.axis)
+	if j.MinLength == j.MaxLength {
+		lambda := -j.axis.mass * (velocity + correction(j.length-j.MinLength, j.dt))
+		j.axis.impulse += lambda
+		j.push(&j.axis, lambda)
+		return
+	}
+	j.push(&j.axis, solveBound(j.length-j.MinLength, velocity, j.axis.mass, j.dt, &j.lower))
+	j.push(&j.axis, -solveBound(j.MaxLength-j.length, -j.velocity(&j.axis), j.axis.mass, j.dt, &j.upper))
+}
+
+// == Spring Joint ========
+
+// SpringJoint pulls its anchors toward RestLength apart with a force of Stiffness
+// per unit stretched, slowed by Damping per unit of speed
+type SpringJoint struct {
+	jointBase
+	AnchorA, AnchorB r2.Point // relative to A and B
+	RestLength       float64
+	Stiffness        float64
+	Damping          float64
+
+	axis  row
+	gamma float64 // softness: how much the spring gives under its own impulse
+	bias  float64
+}
+
+// NewSpringJoint connects a and b (nil for the level) by a spring between anchorA
+// and anchorB, given in level space, which rests at its current length
+func NewSpringJoint(a, b Collider, anchorA, anchorB r2.Point, stiffness, damping float64) *SpringJoint {
+	j := &SpringJoint{jointBase: jointBase{A: a, B: b}, Stiffness: stiffness, Damping: damping}
+	j.AnchorA, j.AnchorB = localAnchors(a, b, anchorA, anchorB)
+	j.RestLength = anchorB.Sub(anchorA).Norm()
+	return j
+}
+
+// prepare sets the spring up as a soft constraint, which is stable however stiff
+// it is.  idea: https://box2d.org/files/ErinCatto_SoftConstraints_GDC2011.pdf
+func (j *SpringJoint) prepare(dt float64) {
+	pA, pB, rA, rB := j.anchors(j.AnchorA, j.AnchorB)
+	d := pB.Sub(pA)
+	length := d.Norm()
+	axis := r2.Point{1, 0}
+	if length > 0 {
+		axis = d.Mul(1 / length)
+	}
+	j.setRow(&j.axis, axis, rA, rB)
+	j.gamma, j.bias = 0, 0
+	if soft := dt * (j.Damping + dt*j.Stiffness); soft > 0 {
+		j.gamma = 1 / soft
+		j.bias = (length - j.RestLength) * dt * j.Stiffness * j.gamma
+		j.axis.mass = invert(invert(j.axis.mass) + j.gamma)
+	} else {
+		j.axis.mass = 0
+	}
+	j.push(&j.axis, j.axis.impulse)
+}
+
+func (j *SpringJoint) impulses() []*float64 {
+	return []*float64{&j.axis.impulse}
+}
+
+func (j *SpringJoint) solve() {
+	lambda := -j.axis.mass * (j.velocity(&j.axis) + j.bias + j.gamma*j.axis.impulse)
+	j.axis.impulse += lambda
+	j.push(&j.axis, lambda)
+}
+
+// == Revolute Joint ========
+
+// RevoluteJoint pins A and B together at a point they turn about, like a hinge or
+// an axle.  Its angle (B's relative to A, from when it was made) can be limited,
+// and a motor can drive it.
+type RevoluteJoint struct {
+	jointBase
+	AnchorA, AnchorB r2.Point // relative to A and B
+	ReferenceAngle   float64  // B's angle relative to A at which the joint's angle is 0
+
+	EnableLimit            bool
+	LowerAngle, UpperAngle float64
+
+	EnableMotor    bool
+	MotorSpeed     float64 // radians per second
+	MaxMotorTorque float64
+
+	dt                  float64
+	rA, rB              r2.Point
+	k11, k12, k22       float64
+	pointBias           r2.Point
+	pointImpulse        r2.Point
+	mass                float64 // angular
+	motor, lower, upper float64 // accumulated impulses
+}
+
+// NewRevoluteJoint pins a and b (nil for the level) together at anchor, given in
+// level space
+func NewRevoluteJoint(a, b Collider, anchor r2.Point) *RevoluteJoint {
+	j := &RevoluteJoint{jointBase: jointBase{A: a, B: b}}
+	j.AnchorA, j.AnchorB = localAnchors(a, b, anchor, anchor)
+	j.ReferenceAngle = -a.GetFixture().Angle
+	if b != nil {
+		j.ReferenceAngle += b.GetFixture().Angle
+	}
+	return j
+}
+
+// Angle returns how far B has turned relative to A since the joint was made
+func (j *RevoluteJoint) Angle() float64 {
+	angle := -j.A.GetFixture().Angle
+	if j.B != nil {
+		angle += j.B.GetFixture().Angle
+	}
+	return angle - j.ReferenceAngle
+}
+
+func (j *RevoluteJoint) prepare(dt float64) {
+	pA, pB, rA, rB := j.anchors(j.AnchorA, j.AnchorB)
+	j.dt, j.rA, j.rB = dt, rA, rB
+	j.k11, j.k12, j.k22 = j.pointMatrix(rA, rB)
+	j.pointBias = pB.Sub(pA).Mul(baumgarte / dt)
+	j.mass = j.angularMass()
+	if !j.EnableMotor {
+		j.motor = 0
+	}
+	if !j.EnableLimit {
+		j.lower, j.upper = 0, 0
+	}
+	j.applyPoint(rA, rB, j.pointImpulse)
+	j.applyAngular(j.motor + j.lower - j.upper)
+}
+
+func (j *RevoluteJoint) impulses() []*float64 {
+	return []*float64{&j.pointImpulse.X, &j.pointImpulse.Y, &j.motor, &j.lower, &j.upper}
+}
+
+func (j *RevoluteJoint) solve() {
+	if j.EnableMotor && j.mass > 0 {
+		lambda := -j.mass * (j.spin() - j.MotorSpeed)
+		j.applyAngular(clampAdd(&j.motor, lambda, j.MaxMotorTorque*j.dt))
+	}
+	if j.EnableLimit && j.mass > 0 {
+		angle := j.angle() - j.ReferenceAngle
+		j.applyAngular(solveBound(angle-j.LowerAngle, j.spin(), j.mass, j.dt, &j.lower))
+		j.applyAngular(-solveBound(j.UpperAngle-angle, -j.spin(), j.mass, j.dt, &j.upper))
+	}
+	v := pointVelocity(j.bodyB, j.rB).Sub(pointVelocity(j.bodyA, j.rA)).Add(j.pointBias)
+	impulse := solvePoint(j.k11, j.k12, j.k22, v)
+	j.pointImpulse = j.pointImpulse.Add(impulse)
+	j.applyPoint(j.rA, j.rB, impulse)
+}
+
+// == Prismatic Joint ========
+
+// PrismaticJoint lets B slide along an axis fixed to A, without turning relative
+// to it, like a piston or a lift on rails.  Its translation (how far along the
+// axis B has moved since the joint was made) can be limited, and a motor can
+// drive it.
+type PrismaticJoint struct {
+	jointBase
+	AnchorA, AnchorB r2.Point // relative to A and B
+	Axis             r2.Point // unit vector relative to A
+	ReferenceAngle   float64  // B's angle relative to A, which is kept
+
+	EnableLimit  bool
+	Lower, Upper float64
+
+	EnableMotor   bool
+	MotorSpeed    float64 // units per second
+	MaxMotorForce float64
+
+	dt                  float64
+	translation         float64
+	offset              float64 // of B's anchor from the axis
+	perp, along         row
+	mass                float64 // angular
+	angleImpulse        float64
+	motor, lower, upper float64 // accumulated impulses
+}
+
+// NewPrismaticJoint lets b slide along axis (in level space) from anchor, relative
+// to a (nil for the level)
+func NewPrismaticJoint(a, b Collider, anchor, axis r2.Point) *PrismaticJoint {
+	j := &PrismaticJoint{jointBase: jointBase{A: a, B: b}}
+	j.AnchorA, j.AnchorB = localAnchors(a, b, anchor, anchor)
+	fix := a.GetFixture()
+	j.Axis = fix.Local(fix.Position.Add(axis.Normalize())).Normalize()
+	j.ReferenceAngle = -fix.Angle
+	if b != nil {
+		j.ReferenceAngle += b.GetFixture().Angle
+	}
+	return j
+}
+
+// Translation returns how far B has moved along the axis since the joint was made
+func (j *PrismaticJoint) Translation() float64 {
+	fixA := j.A.GetFixture()
+	pA, pB := fixA.Apply(j.AnchorA), j.AnchorB
+	if j.B != nil {
+		pB = j.B.GetFixture().Apply(j.AnchorB)
+	}
+	return pB.Sub(pA).Dot(j.worldAxis(fixA.Transform))
+}
+
+// worldAxis returns the Axis in level space, for A at transform t
+func (j *PrismaticJoint) worldAxis(t Transform) r2.Point {
+	return t.Apply(j.Axis).Sub(t.Position).Normalize()
+}
+
+func (j *PrismaticJoint) prepare(dt float64) {
+	pA, pB, _, rB := j.anchors(j.AnchorA, j.AnchorB)
+	axis := j.worldAxis(j.bodyA.Transform)
+	// A's side of the constraint acts at B's anchor, so that A turning sweeps the axis
+	rA := pB.Sub(j.massA.centre)
+	j.dt = dt
+	j.translation = pB.Sub(pA).Dot(axis)
+	j.offset = pB.Sub(pA).Dot(axis.Ortho())
+	j.setRow(&j.perp, axis.Ortho(), rA, rB)
+	j.setRow(&j.along, axis, rA, rB)
+	j.mass = j.angularMass()
+	if !j.EnableMotor {
+		j.motor = 0
+	}
+	if !j.EnableLimit {
+		j.lower, j.upper = 0, 0
+	}
+	j.push(&j.perp, j.perp.impulse)
+	j.push(&j.along, j.motor+j.lower-j.upper)
+	j.applyAngular(j.angleImpulse)
+}
+
+func (j *PrismaticJoint) impulses() []*float64 {
+	return []*float64{&j.perp.impulse, &j.angleImpulse, &j.motor, &j.lower, &j.upper}
+}
+
+func (j *PrismaticJoint) solve() {
+	if j.EnableMotor && j.along.mass > 0 {
+		lambda := -j.along.mass * (j.velocity(&j.along) - j.MotorSpeed)
+		j.push(&j.along, clampAdd(&j.motor, lambda, j.MaxMotorForce*j.dt))
+	}
+	if j.EnableLimit && j.along.mass > 0 {
+		j.push(&j.along, solveBound(j.translation-j.Lower, j.velocity(&j.along), j.along.mass, j.dt, &j.lower))
+		j.push(&j.along, -solveBound(j.Upper-j.translation, -j.velocity(&j.along), j.along.mass, j.dt, &j.upper))
+	}
+
+	lambda := -j.perp.mass * (j.velocity(&j.perp) + correction(j.offset, j.dt))
+	j.perp.impulse += lambda
+	j.push(&j.perp, lambda)
+
+	lambda = -j.mass * (j.spin() + correction(j.angle()-j.ReferenceAngle, j.dt))
+	j.angleImpulse += lambda
+	j.applyAngular(lambda)
+}
+
+// == Weld Joint ========
+
+// WeldJoint glues A and B together at a point, so they neither move nor turn
+// relative to each other
+type WeldJoint struct {
+	jointBase
+	AnchorA, AnchorB r2.Point // relative to A and B
+	ReferenceAngle   float64  // B's angle relative to A, which is kept
+
+	dt            float64
+	rA, rB        r2.Point
+	k11, k12, k22 float64
+	pointBias     r2.Point
+	pointImpulse  r2.Point
+	mass          float64 // angular
+	angleImpulse  float64
+}
+
+// NewWeldJoint glues a and b (nil for the level) together at anchor, given in
+// level space
+func NewWeldJoint(a, b Collider, anchor r2.Point) *WeldJoint {
+	j := &WeldJoint{jointBase: jointBase{A: a, B: b}}
+	j.AnchorA, j.AnchorB = localAnchors(a, b, anchor, anchor)
+	j.ReferenceAngle = -a.GetFixture().Angle
+	if b != nil {
+		j.ReferenceAngle += b.GetFixture().Angle
+	}
+	return j
+}
+
+func (j *WeldJoint) prepare(dt float64) {
+	pA, pB, rA, rB := j.anchors(j.AnchorA, j.AnchorB)
+	j.dt, j.rA, j.rB = dt, rA, rB
+	j.k11, j.k12, j.k22 = j.pointMatrix(rA, rB)
+	j.pointBias = pB.Sub(pA).Mul(baumgarte / dt)
+	j.mass = j.angularMass()
+	j.applyPoint(rA, rB, j.pointImpulse)
+	j.applyAngular(j.angleImpulse)
+}
+
+func (j *WeldJoint) impulses() []*float64 {
+	return []*float64{&j.pointImpulse.X, &j.pointImpulse.Y, &j.angleImpulse}
+}
+
+func (j *WeldJoint) solve() {
+	lambda := -j.mass * (j.spin() + correction(j.angle()-j.ReferenceAngle, j.dt))
+	j.angleImpulse += lambda
+	j.applyAngular(lambda)
+
+	v := pointVelocity(j.bodyB, j.rB).Sub(pointVelocity(j.bodyA, j.rA)).Add(j.pointBias)
+	impulse := solvePoint(j.k11, j.k12, j.k22, v)
+	j.pointImpulse = j.pointImpulse.Add(impulse)
+	j.applyPoint(j.rA, j.rB, impulse)
+}
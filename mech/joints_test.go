@@ -0,0 +1,188 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// newJointWorld returns a World with gravity (or none) and no colliders
+func newJointWorld(gravity float64) *World {
+	world := NewWorld(1.0/60, 32)
+	world.Gravity = r2.Point{0, gravity}
+	return world
+}
+
+// centre returns the middle of c's bounding box
+func centre(c Collider) r2.Point {
+	min, max := c.Bounds()
+	return min.Add(max).Mul(0.5)
+}
+
+func Test_DistanceJointPendulum(t *testing.T) {
+	world := newJointWorld(500)
+	// a bob hanging from (0, 0) by a 50 long rod, pulled out to the side
+	bob := newCrate(45, -5, 10)
+	world.Add(bob)
+	world.AddJoint(NewDistanceJoint(bob, nil, r2.Point{50, 0}, r2.Point{0, 0}))
+	lowest := math.Inf(-1)
+	for i := 0; i < 120; i++ {
+		world.Step()
+		lowest = math.Max(lowest, bob.Position.Y)
+		if d := centre(bob).Norm(); math.Abs(d-50) > 1 {
+			t.Fatalf("DistanceJoint (rod): expected bob to stay 50 from pivot, got %v at step %d", d, i)
+		}
+	}
+	if lowest < 40 {
+		t.Errorf("DistanceJoint (rod): expected bob to swing down under the pivot, got lowest y %v", lowest)
+	}
+}
+
+func Test_DistanceJointRope(t *testing.T) {
+	world := newJointWorld(500)
+	bob := newCrate(-5, 10, 10)
+	world.Add(bob)
+	rope := NewDistanceJoint(bob, nil, r2.Point{0, 10}, r2.Point{0, 0})
+	rope.MinLength, rope.MaxLength = 0, 40
+	world.AddJoint(rope)
+	for i := 0; i < 120; i++ {
+		world.Step()
+	}
+	// slack at first, the rope catches the bob 40 below the pivot
+	if math.Abs(bob.Position.Y-40) > 0.5 || math.Abs(bob.Position.X+5) > 0.5 {
+		t.Errorf("DistanceJoint (rope): expected bob hanging at (-5, 40), got %v", bob.Position)
+	}
+}
+
+func Test_RevoluteJointMotor(t *testing.T) {
+	world := newJointWorld(0)
+	wheel := newCrate(-10, -10, 20)
+	world.Add(wheel)
+	axle := NewRevoluteJoint(wheel, nil, r2.Point{0, 0})
+	axle.EnableMotor, axle.MotorSpeed, axle.MaxMotorTorque = true, 2, 1000
+	world.AddJoint(axle)
+	for i := 0; i < 60; i++ {
+		world.Step()
+	}
+	// the level turns at MotorSpeed relative to the wheel
+	if math.Abs(wheel.AngularVelocity+2) > 1e-6 {
+		t.Errorf("RevoluteJoint (motor): expected wheel turning at -2, got %v", wheel.AngularVelocity)
+	}
+	if math.Abs(axle.Angle()+wheel.Angle) > 1e-9 {
+		t.Errorf("RevoluteJoint (motor): expected joint angle %v, got %v", -wheel.Angle, axle.Angle())
+	}
+	if c := centre(wheel); c.Norm() > 0.5 {
+		t.Errorf("RevoluteJoint (motor): expected wheel to stay on its axle, got centre %v", c)
+	}
+}
+
+func Test_RevoluteJointLimit(t *testing.T) {
+	world := newJointWorld(500)
+	// a door hinged at its top left corner, which would swing down if it could
+	door := newCrate(0, 0, 10)
+	door.Scale = r2.Point{5, 1}
+	world.Add(door)
+	hinge := NewRevoluteJoint(door, nil, r2.Point{0, 0})
+	hinge.EnableLimit, hinge.LowerAngle, hinge.UpperAngle = true, -0.5, 0.5
+	world.AddJoint(hinge)
+	for i := 0; i < 120; i++ {
+		world.Step()
+		if hinge.Angle() < -0.55 {
+			t.Fatalf("RevoluteJoint (limit): expected angle at least -0.5, got %v at step %d", hinge.Angle(), i)
+		}
+	}
+	// the door turns clockwise, so the level turns back relative to it
+	if math.Abs(hinge.Angle()+0.5) > 0.05 {
+		t.Errorf("RevoluteJoint (limit): expected door resting at -0.5, got %v", hinge.Angle())
+	}
+	if door.GetVertexPos(0).Norm() > 0.5 {
+		t.Errorf("RevoluteJoint (limit): expected hinge corner at (0, 0), got %v", door.GetVertexPos(0))
+	}
+}
+
+func Test_PrismaticJoint(t *testing.T) {
+	world := newJointWorld(500)
+	lift := newCrate(-10, -10, 20)
+	world.Add(lift)
+	rail := NewPrismaticJoint(lift, nil, r2.Point{0, 0}, r2.Point{0, 1})
+	rail.EnableLimit, rail.Lower, rail.Upper = true, -30, 30
+	world.AddJoint(rail)
+	// pushed sideways and spun, it can still only slide (down) along the rail, which
+	// moves the level's anchor up relative to it
+	lift.Velocity = r2.Point{50, 0}
+	lift.AngularVelocity = 3
+	for i := 0; i < 120; i++ {
+		world.Step()
+	}
+	if math.Abs(rail.Translation()+30) > 0.5 || math.Abs(lift.Position.X+10) > 0.5 || math.Abs(lift.Angle) > 0.01 {
+		t.Errorf("PrismaticJoint (limit): expected lift at the bottom of the rail, got translation %v, position %v, angle %v",
+			rail.Translation(), lift.Position, lift.Angle)
+	}
+
+	// a motor can drive it back up against gravity
+	rail.EnableMotor, rail.MotorSpeed, rail.MaxMotorForce = true, 20, 10000
+	for i := 0; i < 30; i++ {
+		world.Step()
+	}
+	if math.Abs(lift.Velocity.Y+20) > 0.5 {
+		t.Errorf("PrismaticJoint (motor): expected lift rising at 20, got %v", lift.Velocity)
+	}
+}
+
+func Test_WeldJoint(t *testing.T) {
+	world, _ := newRigidWorld()
+	a, b := newCrate(0, -50, 10), newCrate(10, -50, 10)
+	b.Mass = 3
+	world.Add(a)
+	world.Add(b)
+	world.AddJoint(NewWeldJoint(a, b, r2.Point{10, -45}))
+	for i := 0; i < 180; i++ {
+		world.Step()
+	}
+	// welded off centre, they fall and land together as one lopsided body
+	if offset := b.Position.Sub(a.Position); math.Abs(offset.X-10) > 0.5 || math.Abs(offset.Y) > 0.5 || math.Abs(b.Angle-a.Angle) > 0.01 {
+		t.Errorf("WeldJoint: expected b to stay 10 right of a, got offset %v, angles %v, %v", offset, a.Angle, b.Angle)
+	}
+	if _, max := b.Bounds(); math.Abs(max.Y) > 0.5 {
+		t.Errorf("WeldJoint: expected the pair resting on the floor, got bottom at %v", max.Y)
+	}
+}
+
+func Test_SpringJoint(t *testing.T) {
+	world := newJointWorld(500)
+	weight := newCrate(-5, 0, 10)
+	world.Add(weight)
+	world.AddJoint(NewSpringJoint(weight, nil, r2.Point{0, 0}, r2.Point{0, 0}, 100, 20))
+	for i := 0; i < 600; i++ {
+		world.Step()
+	}
+	// it settles where the spring holds up its weight: stretched m g / k = 5
+	if math.Abs(weight.Position.Y-5) > 0.1 || weight.Velocity.Norm() > 0.1 {
+		t.Errorf("SpringJoint: expected weight resting at y = 5, got %v moving at %v", weight.Position, weight.Velocity)
+	}
+}
+
+func Test_JointCollideConnected(t *testing.T) {
+	world := newJointWorld(0)
+	a, b := newCrate(0, 0, 10), newCrate(5, 0, 10)
+	world.Add(a)
+	world.Add(b)
+	weld := NewWeldJoint(a, b, r2.Point{5, 5})
+	world.AddJoint(weld)
+	world.Step()
+	if a.Position != (r2.Point{0, 0}) || b.Position != (r2.Point{5, 0}) || len(world.Contacts()) != 0 {
+		t.Errorf("Joint (connected): expected overlapping jointed crates left alone, got %v, %v", a.Position, b.Position)
+	}
+
+	// removing a collider takes its joints with it, and then they push apart
+	world.Remove(b)
+	if len(world.Joints()) != 0 {
+		t.Errorf("World.Remove: expected joints of removed collider gone, got %v", world.Joints())
+	}
+	world.Add(b)
+	world.Step()
+	if b.Position.X-a.Position.X <= 5 {
+		t.Errorf("Joint (removed): expected crates pushed apart, got %v, %v", a.Position, b.Position)
+	}
+}
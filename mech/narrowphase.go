@@ -0,0 +1,476 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// The narrow phase breaks every kind of Collider down into convex polygons in
+// level space, and compares those with the separating axis theorem.
+// idea: https://www.sevenson.com.au/actionscript/sat/
+
+// convex is a convex polygon in level space (first/last vertex not repeated)
+type convex []r2.Point
+
+// convexParts returns the convex polygons making up c, in level space
+func convexParts(c Collider) []convex {
+	switch c := c.(type) {
+	case *PolyCollider:
+		return []convex{c.worldVertices()}
+	case *CompoundCollider:
+		return c.worldParts()
+	case *ChainCollider:
+		edges := c.worldEdges()
+		parts := make([]convex, len(edges))
+		for i, e := range edges {
+			parts[i] = e.segment()
+		}
+		return parts
+	}
+	return nil
+}
+
+// Outline returns the shape of c in level space, for drawing: the vertices of each
+// convex part (or each edge, for a ChainCollider)
+func Outline(c Collider) [][]r2.Point {
+	parts := convexParts(c)
+	outline := make([][]r2.Point, len(parts))
+	for i, part := range parts {
+		outline[i] = append([]r2.Point(nil), part...)
+	}
+	return outline
+}
+
+// boundsOverlap returns whether the boxes (aS, aB) and (oS, oB) intersect
+func boundsOverlap(aS, aB, oS, oB r2.Point) bool {
+	return !(aS.X > oB.X || aS.Y > oB.Y || aB.X < oS.X || aB.Y < oS.Y)
+}
+
+// boundsOf returns the corners of the bounding box of points
+func boundsOf(points []r2.Point) (min, max r2.Point) {
+	min, max = points[0], points[0]
+	for _, p := range points[1:] {
+		min = r2.Point{math.Min(min.X, p.X), math.Min(min.Y, p.Y)}
+		max = r2.Point{math.Max(max.X, p.X), math.Max(max.Y, p.Y)}
+	}
+	return min, max
+}
+
+// collides returns whether any part of a intersects (or touches) any part of b,
+// and their Filters allow them to interact
+func collides(a, b Collider) bool {
+	if !CanCollide(a, b) {
+		return false
+	}
+	aMin, aMax := a.Bounds()
+	bMin, bMax := b.Bounds()
+	if !boundsOverlap(aMin, aMax, bMin, bMax) {
+		return false
+	}
+	for _, aPart := range convexParts(a) {
+		for _, bPart := range convexParts(b) {
+			if aPart.overlaps(bPart) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// willCollide returns whether a and b are colliding after dt seconds, assuming
+// both keep moving at their current Velocity.  Neither is actually moved.
+func willCollide(a, b Collider, dt float64) bool {
+	aFix, bFix := a.GetFixture(), b.GetFixture()
+	aPos, bPos := aFix.Position, bFix.Position
+	aFix.Position = aFix.Position.Add(aFix.Velocity.Mul(dt))
+	if a != b {
+		bFix.Position = bFix.Position.Add(bFix.Velocity.Mul(dt))
+	}
+	result := collides(a, b)
+	aFix.Position, bFix.Position = aPos, bPos
+	return result
+}
+
+// penetrate returns the push-out normal and depth of a from b (see
+// convex.penetration), using the deepest overlap between any of their parts.
+// Pairs rejected by their Filters never overlap.
+func penetrate(a, b Collider) (normal r2.Point, depth float64, ok bool) {
+	if !CanCollide(a, b) {
+		return r2.Point{}, 0, false
+	}
+	aMin, aMax := a.Bounds()
+	bMin, bMax := b.Bounds()
+	if !boundsOverlap(aMin, aMax, bMin, bMax) {
+		return r2.Point{}, 0, false
+	}
+	// chains are one-sided, so are tested edge by edge
+	if aChain, isChain := a.(*ChainCollider); isChain {
+		if _, bothChains := b.(*ChainCollider); bothChains {
+			return r2.Point{}, 0, false
+		}
+		normal, depth, ok = penetrate(b, aChain)
+		return normal.Mul(-1), depth, ok
+	}
+	bChain, isChain := b.(*ChainCollider)
+	for _, aPart := range convexParts(a) {
+		if isChain {
+			for _, e := range bChain.worldEdges() {
+				n, d, overlapping := e.penetration(aPart)
+				if overlapping && (!ok || d > depth) {
+					normal, depth, ok = n, d, true
+				}
+			}
+			continue
+		}
+		for _, bPart := range convexParts(b) {
+			n, d, overlapping := aPart.penetration(bPart)
+			if overlapping && (!ok || d > depth) {
+				normal, depth, ok = n, d, true
+			}
+		}
+	}
+	return normal, depth, ok
+}
+
+// cast sweeps a along motion toward b and returns the earliest contact between
+// any of their parts (see convex.cast)
+func cast(a, b Collider, motion r2.Point) (fraction float64, normal r2.Point, hit bool) {
+	fraction = 1
+	if aChain, isChain := a.(*ChainCollider); isChain {
+		if _, bothChains := b.(*ChainCollider); bothChains {
+			return 0, r2.Point{}, false
+		}
+		fraction, normal, hit = cast(b, aChain, motion.Mul(-1))
+		return fraction, normal.Mul(-1), hit
+	}
+	bChain, isChain := b.(*ChainCollider)
+	for _, aPart := range convexParts(a) {
+		if isChain {
+			for _, e := range bChain.worldEdges() {
+				f, n, ok := e.cast(aPart, motion)
+				if ok && (!hit || f < fraction) {
+					fraction, normal, hit = f, n, true
+				}
+			}
+			continue
+		}
+		for _, bPart := range convexParts(b) {
+			f, n, ok := aPart.cast(bPart, motion)
+			if ok && (!hit || f < fraction) {
+				fraction, normal, hit = f, n, true
+			}
+		}
+	}
+	return fraction, normal, hit
+}
+
+// raycast returns where the ray from origin along unit vector dir first hits any
+// part of c (see convex.raycast)
+func raycast(c Collider, origin, dir r2.Point, maxDist float64) (dist float64, normal r2.Point, hit bool) {
+	dist = maxDist
+	if chain, isChain := c.(*ChainCollider); isChain {
+		for _, e := range chain.worldEdges() {
+			d, n, ok := e.raycast(origin, dir, dist)
+			if ok && (!hit || d < dist) {
+				dist, normal, hit = d, n, true
+			}
+		}
+		return dist, normal, hit
+	}
+	for _, part := range convexParts(c) {
+		d, n, ok := part.raycast(origin, dir, dist)
+		if ok && (!hit || d < dist) {
+			dist, normal, hit = d, n, true
+		}
+	}
+	return dist, normal, hit
+}
+
+// == Convex Polygon Tests ========
+
+// vertex returns the vertex at i (% len(p))
+func (p convex) vertex(i int) r2.Point {
+	return p[i%len(p)]
+}
+
+// axis returns the unit normal of the edge from vertex i to i+1,
+// or false if the edge has no length
+func (p convex) axis(i int) (r2.Point, bool) {
+	axis := p.vertex(i).Sub(p.vertex(i + 1)).Ortho()
+	if axis.Norm() == 0 {
+		return r2.Point{}, false
+	}
+	return axis.Normalize(), true
+}
+
+// project returns the extent of the polygon's "shadow" on the unit vector axis
+func (p convex) project(axis r2.Point) (min, max float64) {
+	min = p[0].Dot(axis)
+	max = min
+	for i := 1; i < len(p); i++ {
+		current := p[i].Dot(axis)
+		if current > max {
+			max = current
+		} else if current < min {
+			min = current
+		}
+	}
+	return min, max
+}
+
+// centroid returns the average of the polygon's vertices (which is inside it)
+func (p convex) centroid() r2.Point {
+	var sum r2.Point
+	for _, v := range p {
+		sum = sum.Add(v)
+	}
+	return sum.Mul(1 / float64(len(p)))
+}
+
+// overlaps returns whether p and other intersect or touch: if their shadows
+// overlap on every axis (see axes), there is no gap between them
+func (p convex) overlaps(other convex) bool {
+	for _, axis := range p.axes(other) {
+		pMin, pMax := p.project(axis)
+		otherMin, otherMax := other.project(axis)
+		if pMax < otherMin || otherMax < pMin {
+			return false
+		}
+	}
+	return true
+}
+
+// axes returns the axes to test p and other on: the normals of both's edges.
+// Points and segments have no edge normals across their length, so when both
+// are flat the directions along them, and from one to the other, are added.
+func (p convex) axes(other convex) []r2.Point {
+	var axes []r2.Point
+	for _, poly := range [2]convex{p, other} {
+		for i := range poly {
+			if axis, ok := poly.axis(i); ok {
+				axes = append(axes, axis)
+			}
+		}
+	}
+	if !p.flat() || !other.flat() {
+		return axes
+	}
+	for _, poly := range [2]convex{p, other} {
+		for i := range poly {
+			if axis, ok := poly.axis(i); ok {
+				axes = append(axes, axis.Ortho())
+			}
+		}
+	}
+	if between := other.centroid().Sub(p.centroid()); between.Norm() != 0 {
+		axes = append(axes, between.Normalize())
+	}
+	return axes
+}
+
+// flat returns whether the polygon has no area (a point or a segment)
+func (p convex) flat() bool {
+	var area float64
+	for i := range p {
+		area += p.vertex(i).Cross(p.vertex(i + 1))
+	}
+	return area == 0
+}
+
+// penetration finds the shortest vector along which p can be pushed out of other.
+// normal is a unit vector pointing away from other and depth is how far p must
+// move along it.  ok is false when the polygons do not overlap at all.
+func (p convex) penetration(other convex) (normal r2.Point, depth float64, ok bool) {
+	depth = math.Inf(1)
+	for _, axis := range p.axes(other) {
+		pMin, pMax := p.project(axis)
+		otherMin, otherMax := other.project(axis)
+		if pMax < otherMin || otherMax < pMin {
+			return r2.Point{}, 0, false
+		}
+		// push p toward whichever side of other its shadow is centred on, far
+		// enough to clear it (even if one shadow holds the other)
+		if back, forward := pMax-otherMin, otherMax-pMin; back < forward {
+			if back < depth {
+				depth, normal = back, axis.Mul(-1)
+			}
+		} else if forward < depth {
+			depth, normal = forward, axis
+		}
+	}
+	return normal, depth, !math.IsInf(depth, 1)
+}
+
+// cast sweeps p along motion and finds the first moment it touches other.
+// fraction is how much of motion can be travelled before contact, and normal is
+// the unit surface normal of other at that point.  Polygons which already overlap
+// at the start (beyond touching) and polygons which are being moved away from
+// are not hit.
+func (p convex) cast(other convex, motion r2.Point) (fraction float64, normal r2.Point, hit bool) {
+	if motion.Norm() == 0 {
+		return 0, r2.Point{}, false
+	}
+	enter, exit := math.Inf(-1), math.Inf(1)
+	separation := math.Inf(-1)
+	for _, poly := range [2]convex{p, other} {
+		for i := range poly {
+			axis, ok := poly.axis(i)
+			if !ok {
+				continue
+			}
+			pMin, pMax := p.project(axis)
+			otherMin, otherMax := other.project(axis)
+			separation = math.Max(separation, math.Max(otherMin-pMax, pMin-otherMax))
+
+			speed := motion.Dot(axis)
+			if math.Abs(speed) < 1e-12 {
+				// sliding parallel to this axis, merely touching along it doesn't count
+				if pMax <= otherMin+skin || otherMax <= pMin+skin {
+					return 0, r2.Point{}, false
+				}
+				continue
+			}
+			// the interval of the motion during which the shadows overlap on this axis
+			t0, t1 := (otherMin-pMax)/speed, (otherMax-pMin)/speed
+			if t0 > t1 {
+				t0, t1 = t1, t0
+			}
+			if t0 > enter {
+				enter = t0
+				if speed > 0 {
+					normal = axis.Mul(-1)
+				} else {
+					normal = axis
+				}
+			}
+			exit = math.Min(exit, t1)
+		}
+	}
+	if separation < -skin || enter > exit || enter > 1 || exit <= 0 {
+		return 0, r2.Point{}, false
+	}
+	return math.Max(enter, 0), normal, true
+}
+
+// raycast clips the ray from origin along unit vector dir against each edge of
+// p (Cyrus-Beck), returning the distance to and surface normal at the point
+// where it enters p.  Rays starting inside p don't hit it.
+func (p convex) raycast(origin, dir r2.Point, maxDist float64) (dist float64, normal r2.Point, hit bool) {
+	inside := p.centroid()
+	enter, exit := 0.0, maxDist
+	for i := range p {
+		edgeNormal, ok := p.axis(i)
+		if !ok {
+			continue
+		}
+		if edgeNormal.Dot(inside.Sub(p[i])) > 0 {
+			edgeNormal = edgeNormal.Mul(-1)
+		}
+		// distance of the origin behind this edge, and how fast the ray approaches it
+		num := edgeNormal.Dot(p[i].Sub(origin))
+		den := edgeNormal.Dot(dir)
+		if den == 0 {
+			if num < 0 {
+				return 0, r2.Point{}, false
+			}
+			continue
+		}
+		t := num / den
+		if den < 0 {
+			if t > enter {
+				enter = t
+				normal = edgeNormal
+			}
+		} else if t < exit {
+			exit = t
+		}
+		if enter > exit {
+			return 0, r2.Point{}, false
+		}
+	}
+	if normal == (r2.Point{}) {
+		return 0, r2.Point{}, false
+	}
+	return enter, normal, true
+}
+
+// faceNormal returns the unit normal of the edge from vertex i to i+1, pointing out
+// of p.  Both sides of a segment (two vertex polygon) count as faces.
+func (p convex) faceNormal(i int) r2.Point {
+	normal, _ := p.axis(i)
+	if len(p) > 2 && normal.Dot(p.centroid().Sub(p.vertex(i))) > 0 {
+		normal = normal.Mul(-1)
+	}
+	return normal
+}
+
+// face returns the index of the face of p whose normal points furthest along dir
+func (p convex) face(dir r2.Point) int {
+	best, bestDot := 0, math.Inf(-1)
+	for i := range p {
+		if d := p.faceNormal(i).Dot(dir); d > bestDot {
+			best, bestDot = i, d
+		}
+	}
+	return best
+}
+
+// contactPoints returns the points where p and other, overlapping along normal
+// (pointing away from other, as from penetration), touch.  The face most facing
+// the other polygon is the reference face, and the other polygon's face most
+// facing it is clipped to its sides; what lies behind the reference face is in
+// contact.  This gives two points for faces lying against each other, and one
+// for a corner.
+func (p convex) contactPoints(other convex, normal r2.Point) []contactPoint {
+	reference, incident := other, p
+	ref := other.face(normal)
+	flip := false
+	// prefer other's face unless p's is clearly better, so the choice doesn't flicker
+	if pFace := p.face(normal.Mul(-1)); p.faceNormal(pFace).Dot(normal.Mul(-1)) > other.faceNormal(ref).Dot(normal)+1e-3 {
+		reference, incident, ref, flip = p, other, pFace, true
+	}
+	refNormal := reference.faceNormal(ref)
+	inc := incident.face(refNormal.Mul(-1))
+
+	start, end := reference.vertex(ref), reference.vertex(ref+1)
+	tangent := end.Sub(start).Normalize()
+	points := [2]r2.Point{incident.vertex(inc), incident.vertex(inc + 1)}
+	var ok bool
+	if points, ok = clipSegment(points, tangent.Mul(-1), -start.Dot(tangent)); !ok {
+		return nil
+	}
+	if points, ok = clipSegment(points, tangent, end.Dot(tangent)); !ok {
+		return nil
+	}
+
+	var contacts []contactPoint
+	for k, point := range points {
+		separation := point.Sub(start).Dot(refNormal)
+		if separation > speculative {
+			continue
+		}
+		contacts = append(contacts, contactPoint{
+			position: point,
+			depth:    -separation,
+			feature:  featureID{ref, (inc + k) % len(incident), flip},
+		})
+	}
+	return contacts
+}
+
+// clipSegment cuts off the part of the segment points beyond the line
+// normal·x = offset (on the side normal points to).
+// ok is false if the whole segment is beyond it.
+func clipSegment(points [2]r2.Point, normal r2.Point, offset float64) ([2]r2.Point, bool) {
+	d0, d1 := normal.Dot(points[0])-offset, normal.Dot(points[1])-offset
+	switch {
+	case d0 > 0 && d1 > 0:
+		return points, false
+	case d0 > 0:
+		points[0] = points[0].Add(points[1].Sub(points[0]).Mul(d0 / (d0 - d1)))
+	case d1 > 0:
+		points[1] = points[1].Add(points[0].Sub(points[1]).Mul(d1 / (d1 - d0)))
+	}
+	return points, true
+}
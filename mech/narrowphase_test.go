@@ -0,0 +1,265 @@
+package mech
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// box returns the w by h rectangle with its top left corner at (x, y)
+func box(x, y, w, h float64) convex {
+	return convex{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+}
+
+func Test_Overlaps(t *testing.T) {
+	square := box(0, 0, 10, 10)
+	cases := []struct {
+		name     string
+		a, b     convex
+		expected bool
+	}{
+		{"apart", square, box(20, 0, 10, 10), false},
+		{"overlapping", square, box(5, 5, 10, 10), true},
+		{"touching edges", square, box(10, 0, 10, 10), true},
+		{"touching part of an edge", square, box(10, 8, 10, 10), true},
+		{"touching corners", square, box(10, 10, 10, 10), true},
+		{"barely apart", square, box(10+1e-9, 0, 10, 10), false},
+		{"diagonal gap", square, convex{{12, 9}, {20, 1}, {20, 9}}, false},
+		{"contained", square, box(2, 2, 4, 4), true},
+		{"identical", square, box(0, 0, 10, 10), true},
+		{"vertex on edge", square, convex{{10, 5}, {20, 0}, {20, 10}}, true},
+		{"collinear vertices", convex{{0, 0}, {5, 0}, {10, 0}, {10, 5}, {10, 10}, {0, 10}}, box(10, 0, 10, 10), true},
+		{"collinear vertices apart", convex{{0, 0}, {5, 0}, {10, 0}, {10, 5}, {10, 10}, {0, 10}}, box(11, 0, 10, 10), false},
+		{"repeated vertex", convex{{0, 0}, {10, 0}, {10, 0}, {10, 10}, {0, 10}}, box(10, 10, 5, 5), true},
+		{"anticlockwise", convex{{0, 10}, {10, 10}, {10, 0}, {0, 0}}, box(9, 9, 5, 5), true},
+		{"segment across", square, convex{{-5, 5}, {15, 5}}, true},
+		{"segment inside", square, convex{{2, 2}, {8, 8}}, true},
+		{"segment alongside", square, convex{{0, 11}, {10, 11}}, false},
+		{"segment along edge", square, convex{{-5, 10}, {5, 10}}, true},
+		{"point inside", square, convex{{5, 5}}, true},
+		{"point on corner", square, convex{{10, 10}}, true},
+		{"point outside", square, convex{{11, 5}}, false},
+		{"segments crossing", convex{{0, 0}, {10, 10}}, convex{{0, 10}, {10, 0}}, true},
+		{"segments in line", convex{{0, 0}, {10, 0}}, convex{{15, 0}, {20, 0}}, false},
+		{"segments end to end", convex{{0, 0}, {10, 0}}, convex{{10, 0}, {20, 0}}, true},
+		{"segments sharing a stretch", convex{{0, 0}, {10, 0}}, convex{{5, 0}, {20, 0}}, true},
+		{"parallel segments", convex{{0, 0}, {10, 0}}, convex{{0, 1}, {10, 1}}, false},
+		{"point in line with segment", convex{{0, 0}, {10, 0}}, convex{{15, 0}}, false},
+		{"point on segment", convex{{0, 0}, {10, 0}}, convex{{5, 0}}, true},
+		{"points apart", convex{{0, 0}}, convex{{1, 1}}, false},
+		{"same point", convex{{3, 4}}, convex{{3, 4}}, true},
+	}
+	for _, c := range cases {
+		if got := c.a.overlaps(c.b); got != c.expected {
+			t.Errorf("convex.overlaps (%s): expected %v, got %v", c.name, c.expected, got)
+		}
+		if got := c.b.overlaps(c.a); got != c.expected {
+			t.Errorf("convex.overlaps (%s, swapped): expected %v, got %v", c.name, c.expected, got)
+		}
+		if got := referenceOverlaps(c.a, c.b); got != c.expected {
+			t.Errorf("referenceOverlaps (%s): expected %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func Test_Penetration(t *testing.T) {
+	square := box(0, 0, 10, 10)
+	cases := []struct {
+		name   string
+		a, b   convex
+		normal r2.Point
+		depth  float64
+	}{
+		{"from the left", box(-8, 1, 10, 8), square, r2.Point{-1, 0}, 2},
+		{"from below", box(1, 9, 8, 10), square, r2.Point{0, 1}, 1},
+		{"touching", box(10, 0, 10, 10), square, r2.Point{1, 0}, 0},
+		{"contained", box(1, 2, 4, 4), square, r2.Point{-1, 0}, 5},
+		{"segment", convex{{-5, 9}, {15, 9}}, square, r2.Point{0, 1}, 1},
+		// any nudge sideways parts them
+		{"segments in line", convex{{0, 0}, {10, 0}}, convex{{8, 0}, {20, 0}}, r2.Point{0, -1}, 0},
+	}
+	for _, c := range cases {
+		normal, depth, ok := c.a.penetration(c.b)
+		if !ok || !near(normal, c.normal) || math.Abs(depth-c.depth) > 1e-9 {
+			t.Errorf("convex.penetration (%s): expected %v, %v, got %v, %v (%v)", c.name, c.normal, c.depth, normal, depth, ok)
+		}
+	}
+	if _, _, ok := square.penetration(box(20, 0, 10, 10)); ok {
+		t.Errorf("convex.penetration (apart): expected no overlap")
+	}
+}
+
+func Test_CollidesColliders(t *testing.T) {
+	floor, err := NewChainCollider([]*r2.Point{{0, 10}, {10, 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledge, err := NewChainCollider([]*r2.Point{{15, 10}, {25, 10}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		a, b     Collider
+		expected bool
+	}{
+		{"touching boxes", newBox(0, 0, 10, 10), newBox(10, 0, 10, 10), true},
+		{"box on floor", newBox(0, 0, 10, 10), floor, true},
+		{"box off the end of floor", newBox(11, 0, 10, 10), floor, false},
+		{"chains in line", floor, ledge, false},
+	}
+	for _, c := range cases {
+		if got := collides(c.a, c.b); got != c.expected {
+			t.Errorf("collides (%s): expected %v, got %v", c.name, c.expected, got)
+		}
+		if got := collides(c.b, c.a); got != c.expected {
+			t.Errorf("collides (%s, swapped): expected %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+// == Reference ========
+
+// referenceOverlaps is a slow, obvious test of whether a and b intersect or touch:
+// either has a vertex inside the other, or an edge of one meets an edge of the other
+func referenceOverlaps(a, b convex) bool {
+	for i := range a {
+		if contains(b, a[i]) {
+			return true
+		}
+		for j := range b {
+			if segmentsMeet(a.vertex(i), a.vertex(i+1), b.vertex(j), b.vertex(j+1)) {
+				return true
+			}
+		}
+	}
+	for _, v := range b {
+		if contains(a, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns whether p is strictly inside the polygon (points on its
+// outline are found by segmentsMeet)
+func contains(poly convex, p r2.Point) bool {
+	turn := 0
+	for i := range poly {
+		o := orientation(poly.vertex(i), poly.vertex(i+1), p)
+		if o == 0 {
+			if poly.vertex(i) == poly.vertex(i+1) {
+				continue
+			}
+			return false
+		}
+		if turn != 0 && o != turn {
+			return false
+		}
+		turn = o
+	}
+	return turn != 0
+}
+
+// == Fuzzing ========
+
+// randomConvex returns a convex polygon (sometimes a point or a segment, with a
+// repeated vertex or one in the middle of an edge) picked by seed.  Its vertices
+// lie in distinct sixteenths of an ellipse, so it's never nearly flat.
+func randomConvex(seed int64) convex {
+	rng := rand.New(rand.NewSource(seed))
+	centre := r2.Point{rng.Float64()*100 - 50, rng.Float64()*100 - 50}
+	rx := 1 + rng.Float64()*40
+	ry := rx * (0.25 + rng.Float64()*0.75)
+	turn := Transform{Position: centre, Angle: rng.Float64() * 2 * math.Pi}
+	slots := rng.Perm(16)[:1+rng.Intn(8)]
+	sort.Ints(slots)
+	if rng.Intn(2) == 0 {
+		sort.Sort(sort.Reverse(sort.IntSlice(slots)))
+	}
+	poly := make(convex, 0, len(slots)+1)
+	for _, slot := range slots {
+		angle := float64(slot) * math.Pi / 8
+		poly = append(poly, turn.Apply(r2.Point{rx * math.Cos(angle), ry * math.Sin(angle)}))
+	}
+	switch rng.Intn(4) {
+	case 0:
+		poly = append(poly, poly[len(poly)-1])
+	case 1:
+		if len(poly) >= 2 {
+			mid := poly[0].Add(poly[1]).Mul(0.5)
+			poly = append(poly[:1], append(convex{mid}, poly[1:]...)...)
+		}
+	}
+	return poly
+}
+
+// scaled returns the polygon scaled by s about its centroid
+func scaled(p convex, s float64) convex {
+	centre := p.centroid()
+	out := make(convex, len(p))
+	for i, v := range p {
+		out[i] = centre.Add(v.Sub(centre).Mul(s))
+	}
+	return out
+}
+
+// moved returns the polygon moved by offset
+func moved(p convex, offset r2.Point) convex {
+	out := make(convex, len(p))
+	for i, v := range p {
+		out[i] = v.Add(offset)
+	}
+	return out
+}
+
+func addSeeds(f *testing.F) {
+	for i := int64(0); i < 200; i++ {
+		f.Add(i, i*7919+1)
+	}
+}
+
+func FuzzSATSymmetric(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, seedA, seedB int64) {
+		a, b := randomConvex(seedA), randomConvex(seedB)
+		overlapping := a.overlaps(b)
+		if overlapping != b.overlaps(a) {
+			t.Fatalf("convex.overlaps: not symmetric for %v and %v", a, b)
+		}
+		normalA, depthA, okA := a.penetration(b)
+		normalB, depthB, okB := b.penetration(a)
+		if okA != okB || math.Abs(depthA-depthB) > 1e-9 {
+			t.Fatalf("convex.penetration: not symmetric for %v and %v: %v (%v), %v (%v)", a, b, depthA, okA, depthB, okB)
+		}
+		if okA && !overlapping {
+			t.Fatalf("convex.penetration: found depth %v for %v and %v, which don't overlap", depthA, a, b)
+		}
+		// pushing either out along its normal separates them
+		if okA && moved(a, normalA.Mul(depthA+1e-6)).overlaps(b) {
+			t.Fatalf("convex.penetration: pushing %v out of %v by %v along %v didn't separate them", a, b, depthA, normalA)
+		}
+		if okB && moved(b, normalB.Mul(depthB+1e-6)).overlaps(a) {
+			t.Fatalf("convex.penetration: pushing %v out of %v by %v along %v didn't separate them", b, a, depthB, normalB)
+		}
+	})
+}
+
+func FuzzSATReference(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, seedA, seedB int64) {
+		a, b := randomConvex(seedA), randomConvex(seedB)
+		overlapping := a.overlaps(b)
+		// rounding may go either way for shapes within a hair of touching, so
+		// compare against the reference for shrunken and grown copies
+		if !overlapping && referenceOverlaps(scaled(a, 1-1e-6), scaled(b, 1-1e-6)) {
+			t.Fatalf("convex.overlaps: expected %v and %v to overlap", a, b)
+		}
+		if overlapping && !referenceOverlaps(scaled(a, 1+1e-6), scaled(b, 1+1e-6)) {
+			t.Fatalf("convex.overlaps: expected %v and %v not to overlap", a, b)
+		}
+	})
+}
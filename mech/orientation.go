@@ -0,0 +1,116 @@
+package mech
+
+import "github.com/golang/geo/r2"
+
+// Orientation is one of the eight ways to flip and quarter turn a shape or image
+// onto its own axes.  As in Tiled, DiagFlip (swapping x and y) is done first, then
+// HorizFlip (mirroring left to right), then VertFlip (mirroring top to bottom).
+// The zero Orientation leaves things as they are.
+type Orientation struct {
+	HorizFlip bool
+	VertFlip  bool
+	DiagFlip  bool
+}
+
+// bits 32, 31 and 30 of a Tiled GID store how its tile is flipped
+const (
+	gidHorizFlip uint32 = 0x80000000
+	gidVertFlip  uint32 = 0x40000000
+	gidDiagFlip  uint32 = 0x20000000
+	gidFlags            = gidHorizFlip | gidVertFlip | gidDiagFlip
+)
+
+// OrientationFromGID returns the Orientation stored in the flag bits of a Tiled
+// GID, and the GID without them
+func OrientationFromGID(gid uint32) (Orientation, uint32) {
+	o := Orientation{
+		HorizFlip: gid&gidHorizFlip != 0,
+		VertFlip:  gid&gidVertFlip != 0,
+		DiagFlip:  gid&gidDiagFlip != 0,
+	}
+	return o, gid &^ gidFlags
+}
+
+// GIDFlags returns the Tiled GID flag bits for o, to be or'd onto a tile's GID
+func (o Orientation) GIDFlags() uint32 {
+	var flags uint32
+	if o.HorizFlip {
+		flags |= gidHorizFlip
+	}
+	if o.VertFlip {
+		flags |= gidVertFlip
+	}
+	if o.DiagFlip {
+		flags |= gidDiagFlip
+	}
+	return flags
+}
+
+// Rotation returns the Orientation turning things quarters quarter turns
+// clockwise (on screen); negative quarters turn anticlockwise
+func Rotation(quarters int) Orientation {
+	switch (quarters%4 + 4) % 4 {
+	case 1:
+		return Orientation{HorizFlip: true, DiagFlip: true}
+	case 2:
+		return Orientation{HorizFlip: true, VertFlip: true}
+	case 3:
+		return Orientation{VertFlip: true, DiagFlip: true}
+	}
+	return Orientation{}
+}
+
+// Matrix returns the 2x2 matrix which o multiplies points by, so that
+// v becomes (a*v.X + b*v.Y, c*v.X + d*v.Y)
+func (o Orientation) Matrix() (a, b, c, d float64) {
+	h, v := 1.0, 1.0
+	if o.HorizFlip {
+		h = -1
+	}
+	if o.VertFlip {
+		v = -1
+	}
+	if o.DiagFlip {
+		return 0, h, v, 0
+	}
+	return h, 0, 0, v
+}
+
+// orientationOf returns the Orientation with matrix (a, b, c, d), which must be
+// one of the eight Matrix returns
+func orientationOf(a, b, c, d float64) Orientation {
+	if a == 0 {
+		return Orientation{HorizFlip: b < 0, VertFlip: c < 0, DiagFlip: true}
+	}
+	return Orientation{HorizFlip: a < 0, VertFlip: d < 0}
+}
+
+// Apply returns v reoriented about the origin
+func (o Orientation) Apply(v r2.Point) r2.Point {
+	a, b, c, d := o.Matrix()
+	return r2.Point{a*v.X + b*v.Y, c*v.X + d*v.Y}
+}
+
+// Then returns the Orientation doing o and then next
+func (o Orientation) Then(next Orientation) Orientation {
+	a, b, c, d := o.Matrix()
+	na, nb, nc, nd := next.Matrix()
+	return orientationOf(na*a+nb*c, na*b+nb*d, nc*a+nd*c, nc*b+nd*d)
+}
+
+// Inverse returns the Orientation undoing o
+func (o Orientation) Inverse() Orientation {
+	// each matrix is orthogonal, so its inverse is its transpose
+	a, b, c, d := o.Matrix()
+	return orientationOf(a, c, b, d)
+}
+
+// Mirrored returns whether o turns things inside out, reversing their winding
+func (o Orientation) Mirrored() bool {
+	return o.HorizFlip != o.VertFlip != o.DiagFlip
+}
+
+// Swapped returns whether o swaps width and height
+func (o Orientation) Swapped() bool {
+	return o.DiagFlip
+}
@@ -0,0 +1,73 @@
+package mech
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// allOrientations returns the eight Orientations
+func allOrientations() []Orientation {
+	var all []Orientation
+	for i := 0; i < 8; i++ {
+		all = append(all, Orientation{HorizFlip: i&1 != 0, VertFlip: i&2 != 0, DiagFlip: i&4 != 0})
+	}
+	return all
+}
+
+func Test_OrientationApply(t *testing.T) {
+	v := r2.Point{2, 1}
+	cases := []struct {
+		name        string
+		orientation Orientation
+		want        r2.Point
+	}{
+		{"none", Orientation{}, r2.Point{2, 1}},
+		{"horizontal", Orientation{HorizFlip: true}, r2.Point{-2, 1}},
+		{"vertical", Orientation{VertFlip: true}, r2.Point{2, -1}},
+		{"diagonal", Orientation{DiagFlip: true}, r2.Point{1, 2}},
+		{"diagonal then horizontal", Orientation{HorizFlip: true, DiagFlip: true}, r2.Point{-1, 2}},
+		{"quarter turn", Rotation(1), r2.Point{-1, 2}},
+		{"half turn", Rotation(2), r2.Point{-2, -1}},
+		{"quarter turn back", Rotation(-1), r2.Point{1, -2}},
+		{"full turn", Rotation(4), r2.Point{2, 1}},
+	}
+	for _, c := range cases {
+		if got := c.orientation.Apply(v); !near(got, c.want) {
+			t.Errorf("Orientation.Apply (%s): expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func Test_OrientationCompose(t *testing.T) {
+	v := r2.Point{2, 1}
+	for _, o := range allOrientations() {
+		for _, next := range allOrientations() {
+			if got, want := o.Then(next).Apply(v), next.Apply(o.Apply(v)); !near(got, want) {
+				t.Errorf("Orientation.Then (%v then %v): expected %v, got %v", o, next, want, got)
+			}
+		}
+		if got := o.Then(o.Inverse()); got != (Orientation{}) {
+			t.Errorf("Orientation.Inverse (%v): expected it to undo the orientation, got %v left", o, got)
+		}
+		if got := o.Mirrored(); got != (o.Apply(r2.Point{1, 0}).Cross(o.Apply(r2.Point{0, 1})) < 0) {
+			t.Errorf("Orientation.Mirrored (%v): expected %v", o, !got)
+		}
+	}
+	if got := Rotation(1).Then(Rotation(1)); got != Rotation(2) {
+		t.Errorf("Orientation.Then (two quarter turns): expected %v, got %v", Rotation(2), got)
+	}
+}
+
+func Test_OrientationGID(t *testing.T) {
+	for _, o := range allOrientations() {
+		got, id := OrientationFromGID(o.GIDFlags() | 42)
+		if got != o || id != 42 {
+			t.Errorf("OrientationFromGID (%v): expected it back with tile 42, got %v with tile %d", o, got, id)
+		}
+	}
+	// Tiled's own flags for a tile turned a quarter clockwise
+	if o, id := OrientationFromGID(0xA0000007); o != Rotation(1) || id != 7 {
+		t.Errorf("OrientationFromGID (quarter turn): expected %v with tile 7, got %v with tile %d", Rotation(1), o, id)
+	}
+}
@@ -0,0 +1,261 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// Moving platforms are Kinematic colliders which a World drives along a path, and
+// whatever stands on them is carried along: rigid bodies by friction, like any
+// other contact, and the rest (and Controllers) by being moved and turned with
+// their ground each Step (see World.Ground).
+// Conveyor belts are colliders with a SurfaceSpeed, whose surfaces carry things
+// along in the same ways without the collider itself moving.
+
+// groundSlope is the steepest surface (radians from straight up) which counts as
+// ground for carrying bodies
+const groundSlope = math.Pi / 4
+
+// PathMode is what a Platform does when it reaches the end of its Path
+type PathMode int
+
+const (
+	PingPong PathMode = iota // turn around and go back the way it came
+	Loop                     // carry on from the last point back to the first
+	Once                     // stop at the end
+)
+
+// Platform moves a Kinematic collider so that its Position travels along Path
+// at Speed.  It only sets the body's Velocity, so the solver sees platforms as
+// moving bodies.  AngularVelocity is left alone, so platforms may spin as they go,
+// turning about their Position.
+type Platform struct {
+	Collider Collider
+	Path     []r2.Point // level space points which the collider's Position passes through
+	Mode     PathMode
+	Speed    float64 // units per second along the path
+	Distance float64 // how far it has travelled, including any trips back and forth
+}
+
+// NewPlatform returns a Platform moving c from the start of path, where it is placed
+func NewPlatform(c Collider, path []r2.Point, mode PathMode, speed float64) *Platform {
+	if len(path) > 0 {
+		c.GetFixture().Position = path[0]
+	}
+	return &Platform{Collider: c, Path: path, Mode: mode, Speed: speed}
+}
+
+// length returns the length of the path, including the way back for Loop
+func (p *Platform) length() float64 {
+	var length float64
+	for i := 1; i < len(p.Path); i++ {
+		length += p.Path[i].Sub(p.Path[i-1]).Norm()
+	}
+	if p.Mode == Loop && len(p.Path) > 1 {
+		length += p.Path[0].Sub(p.Path[len(p.Path)-1]).Norm()
+	}
+	return length
+}
+
+// At returns the point distance along the Path, following the Mode
+func (p *Platform) At(distance float64) r2.Point {
+	if len(p.Path) == 0 {
+		return p.Collider.GetFixture().Position
+	}
+	length := p.length()
+	if length == 0 {
+		return p.Path[0]
+	}
+	switch p.Mode {
+	case Loop:
+		distance = wrap(distance, length)
+	case PingPong:
+		distance = wrap(distance, 2*length)
+		if distance > length {
+			distance = 2*length - distance
+		}
+	}
+	distance = math.Max(0, math.Min(length, distance))
+
+	points := p.Path
+	if p.Mode == Loop {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+	for i := 1; i < len(points); i++ {
+		segment := points[i].Sub(points[i-1])
+		d := segment.Norm()
+		if distance <= d && d > 0 {
+			return points[i-1].Add(segment.Mul(distance / d))
+		}
+		distance -= d
+	}
+	return points[len(points)-1]
+}
+
+// wrap returns x modulo period, from 0 up to period (so going backwards works too)
+func wrap(x, period float64) float64 {
+	x = math.Mod(x, period)
+	if x < 0 {
+		x += period
+	}
+	return x
+}
+
+// drive sets the platform's Velocity so that it ends the coming Step dt on its
+// path, whatever its AngularVelocity does to its Position (see integrate)
+func (p *Platform) drive(dt float64) {
+	body := &p.Collider.GetFixture().Body
+	p.Distance += p.Speed * dt
+	motion := p.At(p.Distance).Sub(body.Position)
+	if body.AngularVelocity != 0 {
+		_, centre, _ := massData(p.Collider)
+		offset := centre.Sub(body.Position)
+		motion = motion.Sub(offset.Sub(rotate(offset, body.AngularVelocity*dt)))
+	}
+	body.Velocity = motion.Mul(1 / dt)
+}
+
+// carry returns where point p, resting on ground (whose surface there faces
+// normal), is taken by ground over the last Step dt: moved and turned along with
+// it if it's Kinematic or rigid, then along its surface by its SurfaceSpeed.
+// (Other bodies are only moved by being pushed, so make poor platforms.)
+func carry(ground Collider, p, normal r2.Point, dt float64) r2.Point {
+	body := &ground.GetFixture().Body
+	if moves(ground) {
+		prev := body.Transform
+		prev.Position, prev.Angle = body.PrevPosition, body.PrevAngle
+		p = body.Apply(prev.Local(p))
+	}
+	// surfaces travel clockwise around their collider (on screen)
+	return p.Add(normal.Ortho().Mul(body.SurfaceSpeed * dt))
+}
+
+// moves returns whether ground carries what stands on it along with its motion
+func moves(ground Collider) bool {
+	body := ground.GetFixture()
+	return body.Kind == Kinematic || body.rigid()
+}
+
+// ride moves c along with ground over the last Step dt.  c doesn't turn, so it
+// follows its foot: its point furthest into the ground's surface (facing normal).
+func ride(c, ground Collider, normal r2.Point, dt float64) {
+	var foot r2.Point
+	deepest := math.Inf(-1)
+	for _, part := range convexParts(c) {
+		if p := part.support(normal.Mul(-1)); -p.Dot(normal) > deepest {
+			foot, deepest = p, -p.Dot(normal)
+		}
+	}
+	body := &c.GetFixture().Body
+	body.Position = body.Position.Add(carry(ground, foot, normal, dt).Sub(foot))
+}
+
+// groundVelocity returns the velocity of ground's body at p
+func groundVelocity(ground Collider, p r2.Point) r2.Point {
+	_, centre, _ := massData(ground)
+	return pointVelocity(&ground.GetFixture().Body, p.Sub(centre))
+}
+
+// groundContact is what a body stands on, and the normal of its surface there
+type groundContact struct {
+	ground Collider
+	normal r2.Point
+}
+
+// up returns the direction away from gravity, or up the screen without gravity
+func (w *World) up() r2.Point {
+	if w.Gravity.Norm() == 0 {
+		return r2.Point{0, -1}
+	}
+	return w.Gravity.Normalize().Mul(-1)
+}
+
+// Ground returns what c stood on at the end of the last Step, and the normal of
+// its surface there.  This is only tracked for Dynamic bodies without a Mass,
+// which are carried along by their ground; rigid bodies rely on friction.
+// While a body is carried by a moving ground its Velocity is relative to the
+// ground: landing takes off the ground's speed toward it (as if it landed moving
+// along with the ground), and stepping off adds the ground's velocity back.
+func (w *World) Ground(c Collider) (ground Collider, normal r2.Point, ok bool) {
+	contact, ok := w.ground[c]
+	return contact.ground, contact.normal, ok
+}
+
+// carried returns whether c's body is carried by its ground rather than friction
+func carried(c Collider) bool {
+	fix := c.GetFixture()
+	return fix.Kind == Dynamic && !fix.rigid() && !fix.Sensor
+}
+
+// rides returns whether c is being carried along by ground's motion
+func (w *World) rides(c, ground Collider) bool {
+	contact, ok := w.ground[c]
+	return ok && contact.ground == ground && moves(ground)
+}
+
+// findGround picks out what each carried body stands on from the Step's contacts,
+// preferring the flattest ground, and switches bodies' velocities between being
+// relative to their old and new grounds
+func (w *World) findGround() {
+	previous := w.ground
+	w.ground = make(map[Collider]groundContact)
+	up := w.up()
+	minUp := math.Cos(groundSlope)
+	for _, contact := range w.contacts {
+		for _, side := range [2]struct {
+			body, ground Collider
+			normal       r2.Point
+		}{{contact.A, contact.B, contact.Normal}, {contact.B, contact.A, contact.Normal.Mul(-1)}} {
+			if !carried(side.body) || side.normal.Dot(up) < minUp {
+				continue
+			}
+			if current, ok := w.ground[side.body]; !ok || side.normal.Dot(up) > current.normal.Dot(up) {
+				w.ground[side.body] = groundContact{side.ground, side.normal}
+			}
+		}
+	}
+
+	for _, c := range w.colliders {
+		was, wasOK := previous[c]
+		is, isOK := w.ground[c]
+		if was.ground == is.ground {
+			continue
+		}
+		body := &c.GetFixture().Body
+		if wasOK && moves(was.ground) {
+			body.Velocity = body.Velocity.Add(groundVelocity(was.ground, body.Position))
+		}
+		if isOK && moves(is.ground) {
+			along := groundVelocity(is.ground, body.Position).Dot(is.normal)
+			body.Velocity = body.Velocity.Sub(is.normal.Mul(along))
+		}
+	}
+}
+
+// AddPlatform starts p moving its collider (which should be in the World too).
+// Adding a Platform twice has no effect.
+func (w *World) AddPlatform(p *Platform) {
+	for _, existing := range w.platforms {
+		if existing == p {
+			return
+		}
+	}
+	w.platforms = append(w.platforms, p)
+}
+
+// RemovePlatform stops p moving its collider, leaving it where it is
+func (w *World) RemovePlatform(p *Platform) {
+	for i, existing := range w.platforms {
+		if existing == p {
+			w.platforms = append(w.platforms[:i], w.platforms[i+1:]...)
+			p.Collider.GetFixture().Velocity = r2.Point{}
+			return
+		}
+	}
+}
+
+// Platforms returns the Platforms in the World, in the order they were added
+func (w *World) Platforms() []*Platform {
+	return w.platforms
+}
@@ -0,0 +1,133 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_PlatformAt(t *testing.T) {
+	path := []r2.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	cases := []struct {
+		mode     PathMode
+		distance float64
+		expected r2.Point
+	}{
+		{PingPong, 5, r2.Point{5, 0}},
+		{PingPong, 15, r2.Point{10, 5}},
+		{PingPong, 35, r2.Point{5, 10}},
+		{PingPong, 45, r2.Point{10, 5}},
+		{PingPong, -5, r2.Point{5, 0}},
+		{Loop, 35, r2.Point{0, 5}},
+		{Loop, 45, r2.Point{5, 0}},
+		{Loop, -5, r2.Point{0, 5}},
+		{Once, 35, r2.Point{0, 10}},
+		{Once, -5, r2.Point{0, 0}},
+	}
+	for _, c := range cases {
+		p := NewPlatform(newBox(0, 0, 10, 10), path, c.mode, 1)
+		if got := p.At(c.distance); !near(got, c.expected) {
+			t.Errorf("Platform.At (mode %v, %v): expected %v, got %v", c.mode, c.distance, c.expected, got)
+		}
+	}
+}
+
+// newPlatformWorld returns a World with gravity and a 100 by 10 Kinematic
+// platform, whose top is at y = 0 when it's at the start of path
+func newPlatformWorld(path []r2.Point, speed float64) (*World, *Platform) {
+	world := NewWorld(1.0/60, 32)
+	world.Gravity = r2.Point{0, 500}
+	platform := newBox(0, 0, 100, 10)
+	platform.Kind = Kinematic
+	world.Add(platform)
+	p := NewPlatform(platform, path, PingPong, speed)
+	world.AddPlatform(p)
+	return world, p
+}
+
+func Test_PlatformCarries(t *testing.T) {
+	// along, then up, and back
+	world, p := newPlatformWorld([]r2.Point{{0, 0}, {60, 0}, {60, -60}}, 60)
+	box := newBox(40, -10, 10, 10)
+	world.Add(box)
+	platform := p.Collider.(*PolyCollider)
+	// it's left behind a little on the first Step, before it's standing on anything
+	world.Step()
+	start := box.Position.Sub(platform.Position)
+	for i := 0; i < 180; i++ {
+		world.Step()
+		if ground, _, ok := world.Ground(box); !ok || ground != platform {
+			t.Fatalf("World.Ground: expected box standing on platform at step %d, got %v", i, ground)
+		}
+		offset := box.Position.Sub(platform.Position)
+		if offset.Sub(start).Norm() > 0.5 {
+			t.Fatalf("Platform (carry): expected box to ride at %v on platform, got %v at step %d", start, offset, i)
+		}
+	}
+	// up and most of the way back again
+	if expected := p.At(p.Distance); !near(platform.Position, expected) || !near(expected, r2.Point{59, 0}) {
+		t.Errorf("Platform (path): expected platform at %v on its path, got %v", expected, platform.Position)
+	}
+}
+
+func Test_PlatformRotation(t *testing.T) {
+	// a see-saw, tipping right about its left end
+	world, p := newPlatformWorld(nil, 0)
+	platform := p.Collider.GetFixture()
+	platform.AngularVelocity = 0.3
+	ctrl := NewController(newBox(80, -10, 10, 10))
+	terrain := []Collider{p.Collider}
+	world.Step()
+	ctrl.Move(r2.Point{0, 100}, world.TimeStep, terrain)
+	// the controller stays upright, resting its lower left corner on the platform
+	local := platform.Local(ctrl.Position.Add(r2.Point{0, 10}))
+	for i := 0; i < 60; i++ {
+		world.Step()
+		ctrl.Move(r2.Point{0, 100}, world.TimeStep, terrain)
+	}
+	expected := platform.Apply(local)
+	if got := ctrl.Position.Add(r2.Point{0, 10}); got.Sub(expected).Norm() > 1 {
+		t.Errorf("Platform (rotation): expected controller carried round to %v, got %v", expected, got)
+	}
+	if expected.Y < 20 {
+		t.Errorf("Platform (rotation): expected the platform to have tipped, got %v", expected)
+	}
+	if !near(platform.Position, r2.Point{0, 0}) {
+		t.Errorf("Platform (rotation): expected spinning platform to turn about its Position, got %v", platform.Position)
+	}
+}
+
+func Test_Conveyor(t *testing.T) {
+	world, floor := newRigidWorld()
+	floor.SurfaceSpeed = 30
+	box := newBox(-50, -10, 10, 10)
+	crate := newCrate(50, -10, 10)
+	world.Add(box)
+	world.Add(crate)
+	for i := 0; i < 120; i++ {
+		world.Step()
+	}
+	if math.Abs(box.Position.X+50-60) > 1 {
+		t.Errorf("Conveyor (carried): expected box moved 60 along, got %v", box.Position)
+	}
+	if math.Abs(crate.Velocity.X-30) > 0.5 || math.Abs(crate.Angle) > 0.01 {
+		t.Errorf("Conveyor (rigid): expected crate dragged along at 30, got %v (angle %v)", crate.Velocity, crate.Angle)
+	}
+}
+
+func Test_ControllerOnPlatform(t *testing.T) {
+	world, p := newPlatformWorld([]r2.Point{{0, 0}, {100, 0}}, 30)
+	ctrl := NewController(newBox(40, -10, 10, 10))
+	terrain := []Collider{p.Collider}
+	for i := 0; i < 60; i++ {
+		world.Step()
+		ctrl.Move(r2.Point{0, 100}, world.TimeStep, terrain)
+	}
+	if !ctrl.Grounded || ctrl.Floor != p.Collider {
+		t.Fatalf("Controller (platform): expected to be standing on the platform")
+	}
+	if math.Abs(ctrl.Position.X-70) > 0.5 || math.Abs(ctrl.Position.Y+10) > 0.5 {
+		t.Errorf("Controller (platform): expected to be carried to (70, -10), got %v", ctrl.Position)
+	}
+}
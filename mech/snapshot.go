@@ -0,0 +1,397 @@
+package mech
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/golang/geo/r2"
+)
+
+// Snapshots hold everything a World carries from one Step to the next: each
+// collider's Body and Filter layers, the joints' and contacts' accumulated
+// impulses (for warm starting), how far platforms have travelled, what bodies are
+// standing on, the sensor overlaps and the time left over from Update.
+// What isn't simulated (shapes, Owners, callbacks, the World's settings) is left
+// alone, so a snapshot can only be restored into the World it came from, or one
+// built the same way: with the same colliders, joints and platforms, added in the
+// same order.
+//
+// Together with Steps being deterministic (the same World given the same calls
+// ends up in exactly the same state), this is enough for replays and rollback.
+// Note Go may fuse floating point operations differently on other architectures,
+// so only builds for the same one are guaranteed to agree.
+
+// snapshotVersion is written first, so old snapshots are refused rather than misread
+const snapshotVersion = 3
+
+// ErrBadSnapshot is returned by Restore when a snapshot is corrupt, or doesn't
+// fit the World
+type ErrBadSnapshot struct {
+	ErrStr string
+	Offset int // byte at which the problem was found
+}
+
+func (e *ErrBadSnapshot) Error() string {
+	return fmt.Sprintf("%s : %v", e.ErrStr, e.Offset)
+}
+
+// colliderState is the part of a Fixture kept in snapshots
+type colliderState struct {
+	body           Body
+	category, mask uint32
+	group          int
+	oneWay, sensor bool
+}
+
+// Snapshot returns the World's state in a compact form, which Restore puts back.
+// The same state always gives the same bytes, so snapshots may be compared to
+// check that two runs match.
+func (w *World) Snapshot() []byte {
+	var e encoder
+	e.uint(snapshotVersion)
+	e.float(w.accumulator)
+
+	e.uint(uint64(len(w.colliders)))
+	for _, c := range w.colliders {
+		fix := c.GetFixture()
+		body := &fix.Body
+		e.point(body.Position)
+		e.float(body.Angle)
+		e.point(body.Scale)
+		e.bools(body.Orientation.HorizFlip, body.Orientation.VertFlip, body.Orientation.DiagFlip, fix.OneWay, fix.Sensor)
+		e.uint(uint64(body.Kind))
+		e.point(body.Velocity)
+		e.point(body.PrevPosition)
+		e.float(body.AngularVelocity)
+		e.float(body.PrevAngle)
+		e.float(body.Mass)
+		e.float(body.Inertia)
+		e.float(body.Restitution)
+		e.float(body.Friction)
+		e.float(body.SurfaceSpeed)
+		e.uint(uint64(fix.Category))
+		e.uint(uint64(fix.Mask))
+		e.int(int64(fix.Group))
+	}
+
+	e.uint(uint64(len(w.joints)))
+	for _, j := range w.joints {
+		impulses := j.impulses()
+		e.uint(uint64(len(impulses)))
+		for _, impulse := range impulses {
+			e.float(*impulse)
+		}
+	}
+
+	e.uint(uint64(len(w.platforms)))
+	for _, p := range w.platforms {
+		e.float(p.Distance)
+	}
+
+	// the contact cache and ground are maps, so go through them in a fixed order
+	// to keep the bytes stable
+	keys := make([]contactKey, 0, len(w.impulses))
+	for key := range w.impulses {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return w.keyLess(keys[i], keys[j]) })
+	e.uint(uint64(len(keys)))
+	for _, key := range keys {
+		e.uint(uint64(w.index[key.a]))
+		e.uint(uint64(w.index[key.b]))
+		e.uint(uint64(key.partA))
+		e.uint(uint64(key.partB))
+		e.uint(uint64(key.feature.reference))
+		e.uint(uint64(key.feature.incident))
+		e.bools(key.feature.flip)
+		e.float(w.impulses[key].normal)
+		e.float(w.impulses[key].tangent)
+	}
+
+	e.uint(uint64(len(w.ground)))
+	for _, c := range w.colliders {
+		if contact, ok := w.ground[c]; ok {
+			e.uint(uint64(w.index[c]))
+			e.uint(uint64(w.index[contact.ground]))
+			e.point(contact.normal)
+		}
+	}
+
+	e.uint(uint64(len(w.overlaps)))
+	for _, pair := range w.overlaps {
+		e.uint(uint64(w.index[pair.sensor]))
+		e.uint(uint64(w.index[pair.other]))
+	}
+
+	e.uint(uint64(len(w.contacts)))
+	for _, contact := range w.contacts {
+		e.uint(uint64(w.index[contact.A]))
+		e.uint(uint64(w.index[contact.B]))
+		e.point(contact.Normal)
+		e.float(contact.Depth)
+	}
+	return e.buf
+}
+
+// keyLess orders contact keys by the colliders' places in the World, then parts
+// and features
+func (w *World) keyLess(a, b contactKey) bool {
+	aKey := [7]int{w.index[a.a], w.index[a.b], a.partA, a.partB, a.feature.reference, a.feature.incident, boolInt(a.feature.flip)}
+	bKey := [7]int{w.index[b.a], w.index[b.b], b.partA, b.partB, b.feature.reference, b.feature.incident, boolInt(b.feature.flip)}
+	for i := range aKey {
+		if aKey[i] != bKey[i] {
+			return aKey[i] < bKey[i]
+		}
+	}
+	return false
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Restore puts the World back into the state returned by Snapshot.  If the
+// snapshot is corrupt or doesn't fit the World (see above), an *ErrBadSnapshot is
+// returned and the World is left as it was.
+// Sensor events aren't sent for the overlaps changed by restoring.
+func (w *World) Restore(snapshot []byte) error {
+	d := decoder{buf: snapshot}
+	if d.uint() != snapshotVersion {
+		return d.fail("Snapshot version unknown.")
+	}
+	accumulator := d.float()
+
+	if d.count() != len(w.colliders) {
+		return d.fail("Snapshot has a different number of colliders.")
+	}
+	states := make([]colliderState, len(w.colliders))
+	for i := range states {
+		s := &states[i]
+		s.body.Position = d.point()
+		s.body.Angle = d.float()
+		s.body.Scale = d.point()
+		flags := d.bools(5)
+		s.body.Orientation = Orientation{flags[0], flags[1], flags[2]}
+		s.oneWay, s.sensor = flags[3], flags[4]
+		s.body.Kind = BodyKind(d.uint())
+		s.body.Velocity = d.point()
+		s.body.PrevPosition = d.point()
+		s.body.AngularVelocity = d.float()
+		s.body.PrevAngle = d.float()
+		s.body.Mass = d.float()
+		s.body.Inertia = d.float()
+		s.body.Restitution = d.float()
+		s.body.Friction = d.float()
+		s.body.SurfaceSpeed = d.float()
+		s.category = uint32(d.uint())
+		s.mask = uint32(d.uint())
+		s.group = int(d.int())
+	}
+
+	if d.count() != len(w.joints) {
+		return d.fail("Snapshot has a different number of joints.")
+	}
+	jointImpulses := make([][]float64, len(w.joints))
+	for i, j := range w.joints {
+		if d.count() != len(j.impulses()) {
+			return d.fail("Snapshot has a different kind of joint.")
+		}
+		jointImpulses[i] = make([]float64, len(j.impulses()))
+		for k := range jointImpulses[i] {
+			jointImpulses[i][k] = d.float()
+		}
+	}
+
+	if d.count() != len(w.platforms) {
+		return d.fail("Snapshot has a different number of platforms.")
+	}
+	distances := make([]float64, len(w.platforms))
+	for i := range distances {
+		distances[i] = d.float()
+	}
+
+	impulses := make(map[contactKey]impulse)
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		var key contactKey
+		key.a, key.b = d.collider(w.colliders), d.collider(w.colliders)
+		key.partA, key.partB = int(d.uint()), int(d.uint())
+		key.feature.reference, key.feature.incident = int(d.uint()), int(d.uint())
+		key.feature.flip = d.bools(1)[0]
+		impulses[key] = impulse{d.float(), d.float()}
+	}
+
+	ground := make(map[Collider]groundContact)
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		body := d.collider(w.colliders)
+		ground[body] = groundContact{d.collider(w.colliders), d.point()}
+	}
+
+	var overlaps []sensorPair
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		overlaps = append(overlaps, sensorPair{d.collider(w.colliders), d.collider(w.colliders)})
+	}
+
+	var contacts []Contact
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		contacts = append(contacts, Contact{d.collider(w.colliders), d.collider(w.colliders), d.point(), d.float()})
+	}
+
+	if d.err == nil && d.pos != len(d.buf) {
+		return d.fail("Snapshot has trailing bytes.")
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	w.accumulator = accumulator
+	for i, c := range w.colliders {
+		fix, s := c.GetFixture(), states[i]
+		fix.Body = s.body
+		fix.Category, fix.Mask, fix.Group = s.category, s.mask, s.group
+		fix.OneWay, fix.Sensor = s.oneWay, s.sensor
+		w.broadPhase.Update(c)
+	}
+	for i, j := range w.joints {
+		for k, impulse := range j.impulses() {
+			*impulse = jointImpulses[i][k]
+		}
+	}
+	for i, p := range w.platforms {
+		p.Distance = distances[i]
+	}
+	w.impulses = impulses
+	w.ground = ground
+	w.overlaps = overlaps
+	w.contacts = contacts
+	return nil
+}
+
+// == Encoding ========
+
+// encoder appends values to buf.  Integers are varints, and floats are written
+// byte-reversed as varints (as encoding/gob does), so that round numbers like 0
+// and 1, which fill most of a resting body, take a byte or three.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (e *encoder) int(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutVarint(b[:], v)]...)
+}
+
+func (e *encoder) float(v float64) {
+	e.uint(bits.ReverseBytes64(math.Float64bits(v)))
+}
+
+func (e *encoder) point(p r2.Point) {
+	e.float(p.X)
+	e.float(p.Y)
+}
+
+// bools packs up to 8 flags into a byte
+func (e *encoder) bools(flags ...bool) {
+	var b byte
+	for i, flag := range flags {
+		if flag {
+			b |= 1 << uint(i)
+		}
+	}
+	e.buf = append(e.buf, b)
+}
+
+// decoder reads back what encoder wrote.  After the first problem err is set and
+// every read returns zero values, so it only needs checking at the end.
+type decoder struct {
+	buf []byte
+	pos int
+	err *ErrBadSnapshot
+}
+
+func (d *decoder) fail(errStr string) *ErrBadSnapshot {
+	if d.err == nil {
+		d.err = &ErrBadSnapshot{errStr, d.pos}
+	}
+	return d.err
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		d.fail("Snapshot ended early.")
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		d.fail("Snapshot ended early.")
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// count reads the length of a list, which can't be longer than what's left
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > uint64(len(d.buf)-d.pos) {
+		d.fail("Snapshot list too long.")
+		return 0
+	}
+	return int(n)
+}
+
+// collider reads the index of one of colls
+func (d *decoder) collider(colls []Collider) Collider {
+	i := d.uint()
+	if i >= uint64(len(colls)) {
+		d.fail("Snapshot refers to a missing collider.")
+		return nil
+	}
+	return colls[i]
+}
+
+func (d *decoder) float() float64 {
+	return math.Float64frombits(bits.ReverseBytes64(d.uint()))
+}
+
+func (d *decoder) point() r2.Point {
+	return r2.Point{d.float(), d.float()}
+}
+
+func (d *decoder) bools(n int) []bool {
+	flags := make([]bool, n)
+	if d.err != nil {
+		return flags
+	}
+	if d.pos >= len(d.buf) {
+		d.fail("Snapshot ended early.")
+		return flags
+	}
+	for i := range flags {
+		flags[i] = d.buf[d.pos]&(1<<uint(i)) != 0
+	}
+	d.pos++
+	return flags
+}
@@ -0,0 +1,143 @@
+package mech
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// scene is a busy World (a tumbling pile, a pendulum, a sensor, a kinematic pusher
+// and an elevator) along with the inputs which drive it, for comparing runs
+type scene struct {
+	world  *World
+	crates []*PolyCollider
+	pusher *PolyCollider
+}
+
+func newScene() *scene {
+	world, _ := newRigidWorld()
+	s := &scene{world: world}
+	for i := 0; i < 6; i++ {
+		crate := newCrate(-40+float64(i%3)*22, -30-float64(i/3)*25, 20)
+		crate.Angle = 0.1 * float64(i)
+		crate.Restitution = 0.2
+		s.crates = append(s.crates, crate)
+		world.Add(crate)
+	}
+	bob := newCrate(60, -80, 10)
+	world.Add(bob)
+	world.AddJoint(NewRevoluteJoint(bob, nil, r2.Point{40, -80}))
+
+	sensor := newBox(-60, -20, 40, 20)
+	sensor.Kind, sensor.Sensor = Static, true
+	world.Add(sensor)
+
+	s.pusher = newBox(-120, -15, 10, 15)
+	s.pusher.Kind = Kinematic
+	world.Add(s.pusher)
+	// not rigid, so pushed out rather than solved
+	world.Add(newBox(100, -40, 10, 10))
+
+	elevator := newBox(0, 0, 30, 5)
+	elevator.Kind = Kinematic
+	world.Add(elevator)
+	world.AddPlatform(NewPlatform(elevator, []r2.Point{{150, -5}, {150, -80}}, PingPong, 50))
+	world.Add(newBox(160, -15, 10, 10))
+	return s
+}
+
+// step plays the scene's inputs for step i, then Steps its World
+func (s *scene) step(i int) {
+	s.pusher.Velocity = r2.Point{40, 0}
+	if i%30 == 0 {
+		kicked := s.crates[(i/30)%len(s.crates)]
+		kicked.Velocity = kicked.Velocity.Add(r2.Point{float64(i%7) * 20, -150})
+		kicked.AngularVelocity += 1
+	}
+	s.world.Step()
+}
+
+// checkRunsMatch steps each scene through steps from..to, and fails if their
+// snapshots differ after any of them
+func checkRunsMatch(t *testing.T, name string, a, b *scene, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		a.step(i)
+		b.step(i)
+		if !bytes.Equal(a.world.Snapshot(), b.world.Snapshot()) {
+			t.Fatalf("%s: expected runs to match, but they split at step %d", name, i)
+		}
+	}
+}
+
+func Test_Deterministic(t *testing.T) {
+	a, b := newScene(), newScene()
+	start := a.world.Snapshot()
+	checkRunsMatch(t, "World.Step (two runs)", a, b, 0, 240)
+	if bytes.Equal(start, a.world.Snapshot()) || len(a.world.Contacts()) == 0 {
+		t.Errorf("World.Step (two runs): expected the scene to move and touch, got %d contacts", len(a.world.Contacts()))
+	}
+}
+
+func Test_SnapshotRollback(t *testing.T) {
+	reference := newScene()
+	for i := 0; i < 60; i++ {
+		reference.step(i)
+	}
+	saved := reference.world.Snapshot()
+
+	// rolled back in the same World, after it has gone on a while
+	rolled := newScene()
+	for i := 0; i < 120; i++ {
+		rolled.step(i)
+	}
+	if err := rolled.world.Restore(saved); err != nil {
+		t.Fatalf("World.Restore (rollback): expected no error, got %v", err)
+	}
+	if !bytes.Equal(rolled.world.Snapshot(), saved) {
+		t.Fatalf("World.Restore (rollback): expected snapshot to round trip")
+	}
+	checkRunsMatch(t, "World.Restore (rollback)", reference, rolled, 60, 180)
+
+	// loaded into a fresh World, whose broad phase has a different history
+	loaded := newScene()
+	if err := loaded.world.Restore(reference.world.Snapshot()); err != nil {
+		t.Fatalf("World.Restore (fresh): expected no error, got %v", err)
+	}
+	checkRunsMatch(t, "World.Restore (fresh)", reference, loaded, 180, 240)
+}
+
+func Test_SnapshotErrors(t *testing.T) {
+	s := newScene()
+	for i := 0; i < 30; i++ {
+		s.step(i)
+	}
+	saved := s.world.Snapshot()
+	s.step(30)
+	current := s.world.Snapshot()
+
+	cases := []struct {
+		name     string
+		world    *World
+		snapshot []byte
+	}{
+		{"truncated", s.world, saved[:len(saved)-3]},
+		{"trailing bytes", s.world, append(append([]byte{}, saved...), 0)},
+		{"empty", s.world, nil},
+		{"other world", NewWorld(1.0/60, 32), saved},
+	}
+	for _, c := range cases {
+		before := c.world.Snapshot()
+		err := c.world.Restore(c.snapshot)
+		if _, ok := err.(*ErrBadSnapshot); !ok {
+			t.Errorf("World.Restore (%s): expected ErrBadSnapshot, got %v", c.name, err)
+		}
+		if !bytes.Equal(before, c.world.Snapshot()) {
+			t.Errorf("World.Restore (%s): expected World left as it was", c.name)
+		}
+	}
+	if !bytes.Equal(current, s.world.Snapshot()) {
+		t.Errorf("World.Restore: expected failed restores to leave the scene alone")
+	}
+}
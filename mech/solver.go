@@ -0,0 +1,345 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// The solver resolves contacts involving rigid bodies (see Body) with sequential
+// impulses: each contact point is repeatedly given whatever impulse stops its bodies
+// moving into each other (and sliding, up to the friction limit), until they
+// settle.  Impulses from the previous Step are applied up front ("warm starting"),
+// so stacks don't have to be rebuilt from scratch every Step.
+// idea: https://box2d.org/files/ErinCatto_SequentialImpulses_GDC2006.pdf
+
+const (
+	baumgarte = 0.2  // fraction of penetration corrected per Step
+	slop      = 0.05 // penetration allowed to remain, so resting contacts persist
+	// how far apart points may be and still count as contacts, so that they are
+	// caught before they hit (and keep their impulses while rocking)
+	speculative = 1.0
+)
+
+// featureID identifies a contact point by the parts of the polygons which made it,
+// so it can be matched up with the same point on the next Step
+type featureID struct {
+	reference int  // face of the reference polygon
+	incident  int  // vertex of the incident polygon at the point's end of the incident face (clipped or not)
+	flip      bool // whether the reference polygon belongs to A
+}
+
+// contactKey identifies a contact point across Steps, for warm starting
+type contactKey struct {
+	a, b         Collider
+	partA, partB int
+	feature      featureID
+}
+
+// contactPoint is a single point of contact in a manifold
+type contactPoint struct {
+	position       r2.Point
+	depth          float64
+	feature        featureID
+	rA, rB         r2.Point // from each body's centre of mass to the point
+	normalMass     float64
+	tangentMass    float64
+	bias           float64 // separating velocity to aim for
+	normalImpulse  float64 // accumulated over the Step
+	tangentImpulse float64
+}
+
+// manifold is the contact between one part of A and one part of B.
+// normal points away from B (toward A), as in Contact.
+type manifold struct {
+	a, b         Collider
+	partA, partB int
+	normal       r2.Point
+	points       []contactPoint
+
+	bodyA, bodyB *Body
+	massA, massB solverMass
+	friction     float64
+	restitution  float64
+	surfaceSpeed float64 // how fast the surfaces slide past each other, see Body.SurfaceSpeed
+}
+
+// solverMass is how a body responds to impulses during a Step
+type solverMass struct {
+	invMass, invInertia float64
+	centre              r2.Point // centre of mass in level space
+}
+
+// impulse is the accumulated impulse at a contact point, kept for warm starting
+type impulse struct {
+	normal, tangent float64
+}
+
+// collide returns the manifolds between every overlapping pair of parts of a and b
+func collide(a, b Collider) []*manifold {
+	if !CanCollide(a, b) {
+		return nil
+	}
+	aMin, aMax := a.Bounds()
+	bMin, bMax := b.Bounds()
+	if !boundsOverlap(aMin, aMax, bMin, bMax) {
+		return nil
+	}
+	// chains are one-sided, so are always tested from the other collider's side
+	if _, isChain := a.(*ChainCollider); isChain {
+		if _, bothChains := b.(*ChainCollider); bothChains {
+			return nil
+		}
+		manifolds := collide(b, a)
+		for _, m := range manifolds {
+			m.a, m.b = a, b
+			m.partA, m.partB = m.partB, m.partA
+			m.normal = m.normal.Mul(-1)
+		}
+		return manifolds
+	}
+
+	var edges []edge
+	if chain, isChain := b.(*ChainCollider); isChain {
+		edges = chain.worldEdges()
+	}
+	bParts := convexParts(b)
+	var manifolds []*manifold
+	for i, aPart := range convexParts(a) {
+		for j, bPart := range bParts {
+			var normal r2.Point
+			var ok bool
+			if edges != nil {
+				normal, _, ok = edges[j].penetration(aPart)
+			} else {
+				normal, _, ok = aPart.penetration(bPart)
+			}
+			if !ok {
+				continue
+			}
+			points := aPart.contactPoints(bPart, normal)
+			if len(points) == 0 {
+				continue
+			}
+			manifolds = append(manifolds, &manifold{a: a, b: b, partA: i, partB: j, normal: normal, points: points})
+		}
+	}
+	return manifolds
+}
+
+// massData returns the area, centre of mass (in level space) and moment of inertia
+// per unit mass of c, treating it as uniformly dense.  Shapes without area (chains)
+// have their centre at their Position and no inertia.
+func massData(c Collider) (area float64, centre r2.Point, inertia float64) {
+	origin := c.GetFixture().Position
+	var moment float64
+	for _, part := range convexParts(c) {
+		// sum the triangles fanning out from origin to each edge
+		var partArea, partMoment float64
+		var partCentre r2.Point
+		for i := range part {
+			e1, e2 := part.vertex(i).Sub(origin), part.vertex(i+1).Sub(origin)
+			cross := e1.Cross(e2)
+			partArea += cross / 2
+			partCentre = partCentre.Add(e1.Add(e2).Mul(cross / 6))
+			partMoment += cross / 12 * (e1.Dot(e1) + e1.Dot(e2) + e2.Dot(e2))
+		}
+		// parts may wind either way
+		if partArea < 0 {
+			partArea, partCentre, partMoment = -partArea, partCentre.Mul(-1), -partMoment
+		}
+		area += partArea
+		centre = centre.Add(partCentre)
+		moment += partMoment
+	}
+	if area == 0 {
+		return 0, origin, 0
+	}
+	centre = centre.Mul(1 / area)
+	// moment is about origin, move it to the centre of mass
+	inertia = (moment - area*centre.Dot(centre)) / area
+	return area, centre.Add(origin), inertia
+}
+
+// massOf returns how c's body responds to impulses.  Bodies which aren't Dynamic
+// are immovable, and Dynamic bodies without a Mass count as a unit mass which
+// never turns.
+func massOf(c Collider) solverMass {
+	body := &c.GetFixture().Body
+	_, centre, inertia := massData(c)
+	switch {
+	case body.Kind != Dynamic:
+		return solverMass{0, 0, centre}
+	case body.Mass <= 0:
+		return solverMass{1, 0, centre}
+	}
+	if body.Inertia != 0 {
+		inertia = body.Inertia
+	} else {
+		inertia *= body.Mass
+	}
+	invInertia := 0.0
+	if inertia > 0 && !math.IsInf(inertia, 1) {
+		invInertia = 1 / inertia
+	}
+	return solverMass{1 / body.Mass, invInertia, centre}
+}
+
+// pointVelocity returns the velocity of the point r from the body's centre of mass
+func pointVelocity(body *Body, r r2.Point) r2.Point {
+	if body.Kind == Static {
+		return r2.Point{}
+	}
+	return body.Velocity.Add(r.Ortho().Mul(body.AngularVelocity))
+}
+
+// applyImpulse changes body's velocities as if it were hit with impulse at r
+// from its centre of mass
+func applyImpulse(body *Body, mass solverMass, r, impulse r2.Point) {
+	body.Velocity = body.Velocity.Add(impulse.Mul(mass.invMass))
+	body.AngularVelocity += mass.invInertia * r.Cross(impulse)
+}
+
+// solve finds the contacts involving rigid bodies and changes the bodies' velocities
+// so that, once moved, they no longer push into each other or pull their joints apart
+func (w *World) solve(dt float64) {
+	var manifolds []*manifold
+	masses := make(map[Collider]solverMass)
+	mass := func(c Collider) solverMass {
+		if _, ok := masses[c]; !ok {
+			masses[c] = massOf(c)
+		}
+		return masses[c]
+	}
+	w.eachPair(func(a, b Collider) {
+		aFix, bFix := a.GetFixture(), b.GetFixture()
+		if aFix.Sensor || bFix.Sensor || !(aFix.rigid() || bFix.rigid()) || w.connected[[2]Collider{a, b}] {
+			return
+		}
+		for _, m := range collide(a, b) {
+			m.bodyA, m.bodyB = &aFix.Body, &bFix.Body
+			m.massA, m.massB = mass(a), mass(b)
+			m.friction = math.Sqrt(aFix.Friction * bFix.Friction)
+			m.restitution = math.Max(aFix.Restitution, bFix.Restitution)
+			m.surfaceSpeed = aFix.SurfaceSpeed + bFix.SurfaceSpeed
+			manifolds = append(manifolds, m)
+		}
+	})
+
+	// contacts approaching slower than gravity accelerates them in a couple of Steps
+	// are resting, not bouncing
+	bounceSpeed := 2 * w.Gravity.Norm() * dt
+	for _, m := range manifolds {
+		m.prepare(dt, bounceSpeed, w.impulses)
+	}
+	for _, j := range w.joints {
+		j.base().bind(mass)
+		j.prepare(dt)
+	}
+	for i := 0; i < w.Iterations; i++ {
+		for _, j := range w.joints {
+			j.solve()
+		}
+		for _, m := range manifolds {
+			m.solve()
+		}
+	}
+
+	w.impulses = make(map[contactKey]impulse)
+	for _, m := range manifolds {
+		for _, p := range m.points {
+			w.impulses[m.key(p)] = impulse{p.normalImpulse, p.tangentImpulse}
+		}
+	}
+}
+
+// key returns the contactKey of p in m
+func (m *manifold) key(p contactPoint) contactKey {
+	return contactKey{m.a, m.b, m.partA, m.partB, p.feature}
+}
+
+// prepare works out each point's effective masses and target separating velocity,
+// and applies the impulses it had at the end of the previous Step
+func (m *manifold) prepare(dt, bounceSpeed float64, previous map[contactKey]impulse) {
+	tangent := m.normal.Ortho()
+	for i := range m.points {
+		p := &m.points[i]
+		p.rA, p.rB = p.position.Sub(m.massA.centre), p.position.Sub(m.massB.centre)
+		p.normalMass = 1 / m.effectiveMass(p, m.normal)
+		p.tangentMass = 1 / m.effectiveMass(p, tangent)
+
+		if p.depth < 0 {
+			// not quite touching yet, so allowed to close the gap this Step
+			p.bias = p.depth / dt
+		} else {
+			p.bias = baumgarte / dt * math.Max(0, p.depth-slop)
+		}
+		approach := m.relativeVelocity(p).Dot(m.normal)
+		if approach < -bounceSpeed {
+			p.bias = math.Max(p.bias, -m.restitution*approach)
+		}
+
+		if last, ok := previous[m.key(*p)]; ok {
+			p.normalImpulse, p.tangentImpulse = last.normal, last.tangent
+			m.apply(p, m.normal.Mul(p.normalImpulse).Add(tangent.Mul(p.tangentImpulse)))
+		}
+	}
+}
+
+// effectiveMass returns the inverse of the mass the bodies present to an impulse
+// along dir at p
+func (m *manifold) effectiveMass(p *contactPoint, dir r2.Point) float64 {
+	rnA, rnB := p.rA.Cross(dir), p.rB.Cross(dir)
+	return m.massA.invMass + m.massB.invMass + m.massA.invInertia*rnA*rnA + m.massB.invInertia*rnB*rnB
+}
+
+// relativeVelocity returns the velocity of A relative to B at p
+func (m *manifold) relativeVelocity(p *contactPoint) r2.Point {
+	return pointVelocity(m.bodyA, p.rA).Sub(pointVelocity(m.bodyB, p.rB))
+}
+
+// apply gives A impulse at p, and B the opposite
+func (m *manifold) apply(p *contactPoint, impulse r2.Point) {
+	applyImpulse(m.bodyA, m.massA, p.rA, impulse)
+	applyImpulse(m.bodyB, m.massB, p.rB, impulse.Mul(-1))
+}
+
+// solve runs one iteration over the manifold's points: the normal impulses, which
+// may only push apart, then friction (limited by the normal impulses), which
+// drags the bodies toward moving with each other's surfaces.
+// The accumulated impulses are clamped rather than each iteration's, so that
+// iterations may undo each other's overshoot.
+func (m *manifold) solve() {
+	for i := range m.points {
+		p := &m.points[i]
+		total := p.normalImpulse + p.normalMass*(p.bias-m.relativeVelocity(p).Dot(m.normal))
+		total = math.Max(0, total)
+		m.apply(p, m.normal.Mul(total-p.normalImpulse))
+		p.normalImpulse = total
+	}
+	tangent := m.normal.Ortho()
+	for i := range m.points {
+		p := &m.points[i]
+		limit := m.friction * p.normalImpulse
+		// each surface moves clockwise around its own collider, so both turn the
+		// same way relative to the normal
+		slip := m.relativeVelocity(p).Dot(tangent) - m.surfaceSpeed
+		total := p.tangentImpulse - p.tangentMass*slip
+		total = math.Max(-limit, math.Min(limit, total))
+		m.apply(p, tangent.Mul(total-p.tangentImpulse))
+		p.tangentImpulse = total
+	}
+}
+
+// integrate moves c's body along its velocities for dt seconds, turning it about
+// its centre of mass
+func integrate(c Collider, dt float64) {
+	body := &c.GetFixture().Body
+	if body.AngularVelocity != 0 {
+		_, centre, _ := massData(c)
+		offset := centre.Sub(body.Position)
+		body.Angle += body.AngularVelocity * dt
+		body.Position = body.Position.Add(offset.Sub(rotate(offset, body.AngularVelocity*dt)))
+	}
+	body.Position = body.Position.Add(body.Velocity.Mul(dt))
+}
@@ -0,0 +1,134 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// newRigidWorld returns a World with gravity and a Static floor whose top is at y = 0
+func newRigidWorld() (*World, *PolyCollider) {
+	world := NewWorld(1.0/60, 32)
+	world.Gravity = r2.Point{0, 500}
+	floor := newBox(-200, 0, 400, 20)
+	floor.Kind = Static
+	floor.Friction = 0.5
+	world.Add(floor)
+	return world, floor
+}
+
+// newCrate returns a rigid size by size box with its top left corner at (x, y)
+func newCrate(x, y, size float64) *PolyCollider {
+	crate := newBox(x, y, size, size)
+	crate.Mass = 1
+	crate.Friction = 0.5
+	return crate
+}
+
+func Test_SolverStack(t *testing.T) {
+	world, _ := newRigidWorld()
+	var crates []*PolyCollider
+	for i := 0; i < 3; i++ {
+		crate := newCrate(0, -20*float64(i+1)-float64(i), 20)
+		crates = append(crates, crate)
+		world.Add(crate)
+	}
+	for i := 0; i < 240; i++ {
+		world.Step()
+	}
+	for i, crate := range crates {
+		want := -20 * float64(i+1)
+		if math.Abs(crate.Position.Y-want) > 0.5 || math.Abs(crate.Position.X) > 0.5 {
+			t.Errorf("Solver (stack): expected crate %d resting at (0, %v), got %v", i, want, crate.Position)
+		}
+		if math.Abs(crate.Angle) > 1e-3 || crate.Velocity.Norm() > 1 {
+			t.Errorf("Solver (stack): expected crate %d still, got angle %v, velocity %v", i, crate.Angle, crate.Velocity)
+		}
+	}
+}
+
+func Test_SolverTopples(t *testing.T) {
+	world, _ := newRigidWorld()
+	crate := newCrate(0, -40, 20)
+	crate.Angle = 0.4
+	world.Add(crate)
+	for i := 0; i < 300; i++ {
+		world.Step()
+	}
+	// it should land on a corner and fall flat onto one of its sides
+	flat := math.Remainder(crate.Angle, math.Pi/2)
+	if math.Abs(flat) > 0.01 {
+		t.Errorf("Solver (topple): expected crate to lie flat, got angle %v", crate.Angle)
+	}
+	if _, max := crate.Bounds(); math.Abs(max.Y) > 0.5 {
+		t.Errorf("Solver (topple): expected crate resting on the floor, got bottom at %v", max.Y)
+	}
+}
+
+func Test_SolverRestitution(t *testing.T) {
+	for _, restitution := range []float64{0, 0.8} {
+		world, _ := newRigidWorld()
+		crate := newCrate(0, -120, 20)
+		crate.Restitution = restitution
+		world.Add(crate)
+		landed, highest := false, math.Inf(1)
+		for i := 0; i < 120; i++ {
+			world.Step()
+			if crate.Velocity.Y < 0 {
+				landed = true
+			}
+			if landed {
+				highest = math.Min(highest, crate.Position.Y)
+			}
+		}
+		bounce := -20 - highest
+		if restitution == 0 && bounce > 1 {
+			t.Errorf("Solver (no restitution): expected no bounce, got %v", bounce)
+		}
+		// falling 100 units, it should come back up about 0.8² of the way
+		if restitution > 0 && (bounce < 50 || bounce > 80) {
+			t.Errorf("Solver (restitution %v): expected bounce of about 64, got %v", restitution, bounce)
+		}
+	}
+}
+
+func Test_SolverFriction(t *testing.T) {
+	for _, friction := range []float64{0, 0.5} {
+		world, floor := newRigidWorld()
+		floor.Friction = friction
+		crate := newCrate(0, -20, 20)
+		crate.Friction = friction
+		crate.Velocity = r2.Point{100, 0}
+		world.Add(crate)
+		for i := 0; i < 60; i++ {
+			world.Step()
+		}
+		if friction == 0 && math.Abs(crate.Velocity.X-100) > 1 {
+			t.Errorf("Solver (frictionless): expected crate to keep sliding at 100, got %v", crate.Velocity)
+		}
+		if friction > 0 && math.Abs(crate.Velocity.X) > 1 {
+			t.Errorf("Solver (friction): expected crate to stop, got %v", crate.Velocity)
+		}
+		if math.Abs(crate.Angle) > 1e-3 {
+			t.Errorf("Solver (friction %v): expected crate not to turn, got %v", friction, crate.Angle)
+		}
+	}
+}
+
+func Test_SolverKinematic(t *testing.T) {
+	world, floor := newRigidWorld()
+	world.Remove(floor)
+	platform := newBox(-50, 0, 100, 10)
+	platform.Kind = Kinematic
+	platform.Velocity = r2.Point{0, -30}
+	crate := newCrate(0, -20, 20)
+	world.Add(platform)
+	world.Add(crate)
+	for i := 0; i < 120; i++ {
+		world.Step()
+	}
+	if _, max := crate.Bounds(); math.Abs(max.Y-platform.Position.Y) > 0.5 {
+		t.Errorf("Solver (kinematic): expected crate carried on platform top %v, got bottom at %v", platform.Position.Y, max.Y)
+	}
+}
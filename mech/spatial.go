@@ -0,0 +1,286 @@
+package mech
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r2"
+)
+
+// SpatialHash is a uniform grid broad phase.  Each Collider is listed in every
+// cell its bounding box covers, so queries only need to look at nearby colliders.
+// Results are returned in the order colliders were inserted, however they have
+// moved since, so they only depend on what is in the SpatialHash and where.
+type SpatialHash struct {
+	CellSize float64
+	cells    map[cell][]Collider
+	covered  map[Collider][2]cell // the cell range each collider was inserted into
+	order    map[Collider]int     // when each collider was first inserted
+	next     int
+	extent   [2]cell // first and last cells of a range covering every collider (maybe more)
+}
+
+type cell struct {
+	X, Y int
+}
+
+// NewSpatialHash returns an empty SpatialHash with square cells of side cellSize.
+// A good cellSize is somewhat larger than a typical moving collider.
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	return &SpatialHash{
+		CellSize: cellSize,
+		cells:    make(map[cell][]Collider),
+		covered:  make(map[Collider][2]cell),
+		order:    make(map[Collider]int),
+	}
+}
+
+// cellRange returns the first and last cells covered by the box (min, max)
+func (h *SpatialHash) cellRange(min, max r2.Point) (cell, cell) {
+	return cell{int(math.Floor(min.X / h.CellSize)), int(math.Floor(min.Y / h.CellSize))},
+		cell{int(math.Floor(max.X / h.CellSize)), int(math.Floor(max.Y / h.CellSize))}
+}
+
+// Insert adds c to every cell covered by its current bounding box
+func (h *SpatialHash) Insert(c Collider) {
+	order, ok := h.order[c]
+	if ok {
+		h.Remove(c)
+	} else {
+		order = h.next
+		h.next++
+	}
+	first, last := h.cellRange(c.Bounds())
+	if len(h.cells) == 0 {
+		h.extent = [2]cell{first, last}
+	} else {
+		h.extent = [2]cell{
+			{minInt(h.extent[0].X, first.X), minInt(h.extent[0].Y, first.Y)},
+			{maxInt(h.extent[1].X, last.X), maxInt(h.extent[1].Y, last.Y)},
+		}
+	}
+	h.covered[c] = [2]cell{first, last}
+	h.order[c] = order
+	for x := first.X; x <= last.X; x++ {
+		for y := first.Y; y <= last.Y; y++ {
+			h.cells[cell{x, y}] = append(h.cells[cell{x, y}], c)
+		}
+	}
+}
+
+// Remove takes c out of the SpatialHash
+func (h *SpatialHash) Remove(c Collider) {
+	covered, ok := h.covered[c]
+	if !ok {
+		return
+	}
+	delete(h.covered, c)
+	delete(h.order, c)
+	for x := covered[0].X; x <= covered[1].X; x++ {
+		for y := covered[0].Y; y <= covered[1].Y; y++ {
+			colls := h.cells[cell{x, y}]
+			for i := range colls {
+				if colls[i] == c {
+					colls = append(colls[:i], colls[i+1:]...)
+					break
+				}
+			}
+			if len(colls) == 0 {
+				delete(h.cells, cell{x, y})
+			} else {
+				h.cells[cell{x, y}] = colls
+			}
+		}
+	}
+}
+
+// Update moves c to the cells covered by its current bounding box, if they changed
+func (h *SpatialHash) Update(c Collider) {
+	first, last := h.cellRange(c.Bounds())
+	if covered, ok := h.covered[c]; ok && covered == [2]cell{first, last} {
+		return
+	}
+	h.Insert(c)
+}
+
+// Cells returns the corners of every cell holding a Collider, row by row
+func (h *SpatialHash) Cells() [][2]r2.Point {
+	keys := make([]cell, 0, len(h.cells))
+	for key := range h.cells {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Y < keys[j].Y || keys[i].Y == keys[j].Y && keys[i].X < keys[j].X
+	})
+	corners := make([][2]r2.Point, len(keys))
+	for i, key := range keys {
+		min := r2.Point{float64(key.X) * h.CellSize, float64(key.Y) * h.CellSize}
+		corners[i] = [2]r2.Point{min, min.Add(r2.Point{h.CellSize, h.CellSize})}
+	}
+	return corners
+}
+
+// Clear removes every Collider from the SpatialHash
+func (h *SpatialHash) Clear() {
+	h.cells = make(map[cell][]Collider)
+	h.covered = make(map[Collider][2]cell)
+	h.order = make(map[Collider]int)
+}
+
+// Query returns every Collider matching filter whose bounding box overlaps the box (min, max)
+func (h *SpatialHash) Query(min, max r2.Point, filter Filter) []Collider {
+	var found []Collider
+	seen := make(map[Collider]bool)
+	first, last := h.cellRange(min, max)
+	for x := first.X; x <= last.X; x++ {
+		for y := first.Y; y <= last.Y; y++ {
+			for _, c := range h.cells[cell{x, y}] {
+				if seen[c] {
+					continue
+				}
+				seen[c] = true
+				cMin, cMax := c.Bounds()
+				if boundsOverlap(min, max, cMin, cMax) && filter.matches(c) {
+					found = append(found, c)
+				}
+			}
+		}
+	}
+	// cells list colliders in the order they last moved into them
+	sort.Slice(found, func(i, j int) bool { return h.order[found[i]] < h.order[found[j]] })
+	return found
+}
+
+// RaycastHit describes where a ray first met a Collider
+type RaycastHit struct {
+	Collider Collider
+	Point    r2.Point
+	Normal   r2.Point // surface normal of Collider at Point
+	Distance float64  // distance from the ray's origin to Point
+}
+
+// Raycast returns the first Collider matching filter hit by the ray from origin
+// along the unit vector dir, within maxDist (which may be infinite).  It walks the
+// cells the ray crosses in order, stopping at the first cell past a hit, so its
+// cost goes with the ray's length over the occupied cells, and never exceeds
+// checking every Collider.
+func (h *SpatialHash) Raycast(origin, dir r2.Point, maxDist float64, filter Filter) (RaycastHit, bool) {
+	best := RaycastHit{Distance: maxDist}
+	found := false
+	check := func(c Collider) {
+		if !filter.matches(c) {
+			return
+		}
+		dist, normal, ok := raycast(c, origin, dir, best.Distance)
+		// on a tie, the earlier inserted collider wins, as in Query order
+		if !ok || found && (dist > best.Distance || dist == best.Distance && h.order[c] > h.order[best.Collider]) {
+			return
+		}
+		best = RaycastHit{c, origin.Add(dir.Mul(dist)), normal, dist}
+		found = true
+	}
+
+	if len(h.cells) == 0 {
+		return best, false
+	}
+	// only the part of the ray over the occupied cells can hit anything
+	lo := r2.Point{float64(h.extent[0].X) * h.CellSize, float64(h.extent[0].Y) * h.CellSize}
+	hi := r2.Point{float64(h.extent[1].X+1) * h.CellSize, float64(h.extent[1].Y+1) * h.CellSize}
+	enter, exit, ok := clipRay(origin, dir, maxDist, lo, hi)
+	if !ok {
+		return best, false
+	}
+	// a ray crossing more cells than are occupied is quicker checked against everything
+	if crossed := (exit - enter) * (math.Abs(dir.X) + math.Abs(dir.Y)) / h.CellSize; crossed > float64(len(h.cells)) {
+		for c := range h.order {
+			check(c)
+		}
+		return best, found
+	}
+
+	// Amanatides and Woo's grid traversal: step into whichever neighbouring cell
+	// the ray reaches first, tracking how far along the ray each next boundary is
+	entry := origin.Add(dir.Mul(enter))
+	start, _ := h.cellRange(entry, entry)
+	current := cell{
+		clampInt(start.X, h.extent[0].X, h.extent[1].X),
+		clampInt(start.Y, h.extent[0].Y, h.extent[1].Y),
+	}
+	stepX, nextX, deltaX := h.traversal(current.X, origin.X, dir.X)
+	stepY, nextY, deltaY := h.traversal(current.Y, origin.Y, dir.Y)
+	seen := make(map[Collider]bool)
+	for {
+		for _, c := range h.cells[current] {
+			if !seen[c] {
+				seen[c] = true
+				check(c)
+			}
+		}
+		// a hit is found in the cell it's in, so nothing further on can be nearer
+		next := math.Min(nextX, nextY)
+		if next >= exit || found && best.Distance <= next {
+			break
+		}
+		if nextX < nextY {
+			current.X += stepX
+			nextX += deltaX
+		} else {
+			current.Y += stepY
+			nextY += deltaY
+		}
+	}
+	return best, found
+}
+
+// traversal returns, for one axis of a ray starting at o going d per unit of
+// distance from inside cell index i: which way it steps between cells, how far it
+// goes before its first step, and how far between steps
+func (h *SpatialHash) traversal(i int, o, d float64) (step int, next, delta float64) {
+	switch {
+	case d > 0:
+		return 1, (float64(i+1)*h.CellSize - o) / d, h.CellSize / d
+	case d < 0:
+		return -1, (float64(i)*h.CellSize - o) / d, -h.CellSize / d
+	}
+	return 0, math.Inf(1), math.Inf(1)
+}
+
+// clipRay returns the distances along the ray from origin along dir at which it
+// enters and leaves the box (lo, hi), within [0, maxDist], or false if it misses
+func clipRay(origin, dir r2.Point, maxDist float64, lo, hi r2.Point) (float64, float64, bool) {
+	enter, exit := 0.0, maxDist
+	for _, axis := range [][4]float64{{origin.X, dir.X, lo.X, hi.X}, {origin.Y, dir.Y, lo.Y, hi.Y}} {
+		o, d, min, max := axis[0], axis[1], axis[2], axis[3]
+		if d == 0 {
+			if o < min || o > max {
+				return 0, 0, false
+			}
+			continue
+		}
+		t1, t2 := (min-o)/d, (max-o)/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		enter, exit = math.Max(enter, t1), math.Min(exit, t2)
+	}
+	return enter, exit, enter <= exit
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// clampInt returns i limited to [min, max]
+func clampInt(i, min, max int) int {
+	return maxInt(min, minInt(i, max))
+}
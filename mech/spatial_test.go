@@ -0,0 +1,61 @@
+package mech
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_SpatialHashRaycast(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	hash := NewSpatialHash(16)
+	var boxes []Collider
+	for i := 0; i < 40; i++ {
+		box := newBox(rng.Float64()*200-100, rng.Float64()*200-100, 1+rng.Float64()*30, 1+rng.Float64()*30)
+		hash.Insert(box)
+		boxes = append(boxes, box)
+	}
+
+	// every collider, nearest hit first, as the grid walk should find
+	for i := 0; i < 200; i++ {
+		origin := r2.Point{rng.Float64()*300 - 150, rng.Float64()*300 - 150}
+		angle := rng.Float64() * 2 * math.Pi
+		dir := r2.Point{math.Cos(angle), math.Sin(angle)}
+		if i%10 == 0 {
+			dir = r2.Point{0, math.Copysign(1, dir.Y)} // straight along an axis
+		}
+		maxDist := rng.Float64() * 300
+		want, wantOK := RaycastHit{Distance: maxDist}, false
+		for _, c := range boxes {
+			if dist, normal, ok := raycast(c, origin, dir, want.Distance); ok && (!wantOK || dist < want.Distance) {
+				want, wantOK = RaycastHit{c, origin.Add(dir.Mul(dist)), normal, dist}, true
+			}
+		}
+		got, ok := hash.Raycast(origin, dir, maxDist, QueryAll)
+		if ok != wantOK || ok && math.Abs(got.Distance-want.Distance) > 1e-9 {
+			t.Errorf("SpatialHash.Raycast (from %v along %v): expected %v at %v, got %v at %v", origin, dir, wantOK, want.Distance, ok, got.Distance)
+		}
+	}
+
+	// an endless ray, and a long one across a huge empty stretch, end promptly
+	far := newBox(1e9, 1e9, 10, 10)
+	hash.Insert(far)
+	done := make(chan bool)
+	go func() {
+		_, endless := hash.Raycast(r2.Point{-1000, 500}, r2.Point{1, 0}, math.Inf(1), QueryAll)
+		diag := r2.Point{1, 1}.Normalize()
+		hit, long := hash.Raycast(r2.Point{200, 200}, diag, 2e9, QueryAll)
+		done <- !endless && long && hit.Collider == Collider(far)
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("SpatialHash.Raycast (long rays): expected a miss, then the far box hit")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SpatialHash.Raycast (long rays): expected it to finish")
+	}
+}
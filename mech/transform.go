@@ -0,0 +1,79 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// Transform places a collider's shape in the level.  Shapes are reoriented
+// (flipped about their origin), then scaled, then turned about their origin, then
+// moved to Position.
+type Transform struct {
+	Position    r2.Point
+	Angle       float64  // radians clockwise (on screen) about Position
+	Scale       r2.Point // along each axis before turning; components of 0 count as 1
+	Orientation Orientation
+}
+
+// Apply returns the level space position of v, given relative to the Transform
+func (t Transform) Apply(v r2.Point) r2.Point {
+	scale := t.factors()
+	v = t.Orientation.Apply(v)
+	return rotate(r2.Point{v.X * scale.X, v.Y * scale.Y}, t.Angle).Add(t.Position)
+}
+
+// Local returns the position relative to the Transform of p, given in level
+// space; it undoes Apply
+func (t Transform) Local(p r2.Point) r2.Point {
+	scale := t.factors()
+	v := rotate(p.Sub(t.Position), -t.Angle)
+	return t.Orientation.Inverse().Apply(r2.Point{v.X / scale.X, v.Y / scale.Y})
+}
+
+// Mirrored returns whether the Transform turns shapes inside out (flips them an
+// odd number of times, counting negative Scale), which reverses their winding
+func (t Transform) Mirrored() bool {
+	scale := t.factors()
+	return t.Orientation.Mirrored() != ((scale.X < 0) != (scale.Y < 0))
+}
+
+// factors returns the multiplier of each axis
+func (t Transform) factors() r2.Point {
+	scale := t.Scale
+	if scale.X == 0 {
+		scale.X = 1
+	}
+	if scale.Y == 0 {
+		scale.Y = 1
+	}
+	return scale
+}
+
+// rotate returns v turned by angle radians (clockwise on screen)
+func rotate(v r2.Point, angle float64) r2.Point {
+	if angle == 0 {
+		return v
+	}
+	sin, cos := math.Sincos(angle)
+	return r2.Point{v.X*cos - v.Y*sin, v.X*sin + v.Y*cos}
+}
+
+// transformCache remembers which Transform a collider's level space shape and
+// bounding box were last worked out for, so they are only redone when it changes
+type transformCache struct {
+	at       Transform
+	valid    bool
+	min, max r2.Point
+}
+
+// stale returns whether the cache needs redoing for t
+func (c *transformCache) stale(t Transform) bool {
+	return !c.valid || c.at != t
+}
+
+// update records that the cache now holds the shape for t, bounded by points
+func (c *transformCache) update(t Transform, points []r2.Point) {
+	c.at, c.valid = t, true
+	c.min, c.max = boundsOf(points)
+}
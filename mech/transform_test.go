@@ -0,0 +1,112 @@
+package mech
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+// near returns whether a and b are within 1e-9 of each other
+func near(a, b r2.Point) bool {
+	return a.Sub(b).Norm() < 1e-9
+}
+
+func Test_TransformApply(t *testing.T) {
+	cases := []struct {
+		name      string
+		transform Transform
+		want      r2.Point
+	}{
+		{"identity", Transform{}, r2.Point{2, 1}},
+		{"moved", Transform{Position: r2.Point{10, 20}}, r2.Point{12, 21}},
+		{"quarter turn", Transform{Angle: math.Pi / 2}, r2.Point{-1, 2}},
+		{"scaled", Transform{Scale: r2.Point{3, 0}}, r2.Point{6, 1}},
+		{"flipped x", Transform{Orientation: Orientation{HorizFlip: true}}, r2.Point{-2, 1}},
+		{"flipped y", Transform{Orientation: Orientation{VertFlip: true}}, r2.Point{2, -1}},
+		{"flipped diagonally", Transform{Orientation: Orientation{DiagFlip: true}}, r2.Point{1, 2}},
+		{"reoriented then scaled", Transform{Scale: r2.Point{3, 1}, Orientation: Rotation(1)}, r2.Point{-3, 2}},
+		{"everything", Transform{r2.Point{10, 20}, math.Pi / 2, r2.Point{2, 2}, Orientation{HorizFlip: true}}, r2.Point{8, 16}},
+	}
+	for _, c := range cases {
+		if got := c.transform.Apply(r2.Point{2, 1}); !near(got, c.want) {
+			t.Errorf("Transform.Apply (%s): expected %v, got %v", c.name, c.want, got)
+		}
+		if got := c.transform.Local(c.want); !near(got, r2.Point{2, 1}) {
+			t.Errorf("Transform.Local (%s): expected (2, 1), got %v", c.name, got)
+		}
+	}
+	if (Transform{Orientation: Orientation{HorizFlip: true, VertFlip: true}}).Mirrored() {
+		t.Errorf("Transform.Mirrored (flipped twice): expected false, got true")
+	}
+	if !(Transform{Scale: r2.Point{-1, 1}}).Mirrored() {
+		t.Errorf("Transform.Mirrored (negative scale): expected true, got false")
+	}
+	if (Transform{Scale: r2.Point{-1, 1}, Orientation: Orientation{DiagFlip: true}}).Mirrored() {
+		t.Errorf("Transform.Mirrored (negative scale, flipped diagonally): expected false, got true")
+	}
+}
+
+func Test_TransformBounds(t *testing.T) {
+	box := newBox(0, 0, 10, 20)
+	if min, max := box.Bounds(); !near(min, r2.Point{0, 0}) || !near(max, r2.Point{10, 20}) {
+		t.Errorf("Bounds (untransformed): expected (0, 0) to (10, 20), got %v to %v", min, max)
+	}
+	// changing the Transform must redo the cached vertices
+	box.Angle = math.Pi / 2
+	if min, max := box.Bounds(); !near(min, r2.Point{-20, 0}) || !near(max, r2.Point{0, 10}) {
+		t.Errorf("Bounds (quarter turn): expected (-20, 0) to (0, 10), got %v to %v", min, max)
+	}
+	box.Angle = 0
+	box.Scale = r2.Point{2, 0.5}
+	box.Orientation.VertFlip = true
+	if min, max := box.Bounds(); !near(min, r2.Point{0, -10}) || !near(max, r2.Point{20, 0}) {
+		t.Errorf("Bounds (scaled, flipped): expected (0, -10) to (20, 0), got %v to %v", min, max)
+	}
+	if v := box.GetVertexPos(2); !near(v, r2.Point{20, -10}) {
+		t.Errorf("GetVertexPos (scaled, flipped): expected (20, -10), got %v", v)
+	}
+}
+
+func Test_TransformCollides(t *testing.T) {
+	// a long thin bar, which only reaches the box once turned upright
+	bar := newBox(0, 0, 40, 2)
+	box := newBox(-5, -40, 10, 10)
+	if bar.Collides(box) {
+		t.Errorf("Collides (lying bar): expected no collision, got one")
+	}
+	bar.Angle = -math.Pi / 2
+	if !bar.Collides(box) {
+		t.Errorf("Collides (upright bar): expected collision, got none")
+	}
+	// flipped, the bar reaches left from its Position instead of right
+	bar.Angle = 0
+	bar.Orientation.HorizFlip = true
+	bar.Position = r2.Point{0, -35}
+	if !bar.Collides(box) {
+		t.Errorf("Collides (bar flipped into box): expected collision, got none")
+	}
+	bar.Position = r2.Point{-10, -35}
+	if bar.Collides(box) {
+		t.Errorf("Collides (bar flipped away from box): expected no collision, got one")
+	}
+}
+
+func Test_TransformMirroredChain(t *testing.T) {
+	// solid below y = 0 ...
+	floor, err := NewChainCollider([]*r2.Point{{-100, 0}, {100, 0}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, hit := raycast(floor, r2.Point{0, -10}, r2.Point{0, 1}, 100); !hit {
+		t.Errorf("ChainCollider (from above): expected hit, got none")
+	}
+	// ... and above it once mirrored, as a ceiling
+	floor.Orientation.VertFlip = true
+	if _, _, hit := raycast(floor, r2.Point{0, -10}, r2.Point{0, 1}, 100); hit {
+		t.Errorf("ChainCollider (flipped, from above): expected no hit, got one")
+	}
+	if _, normal, hit := raycast(floor, r2.Point{0, 10}, r2.Point{0, -1}, 100); !hit || !near(normal, r2.Point{0, 1}) {
+		t.Errorf("ChainCollider (flipped, from below): expected hit facing down, got %v, %v", hit, normal)
+	}
+}
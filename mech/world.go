@@ -0,0 +1,441 @@
+package mech
+
+import (
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// World owns a set of colliders and advances their bodies on a fixed timestep.
+// Call Update once per frame with the frame's duration; the World runs however
+// many Steps fit, and Alpha tells the renderer how far it is between the last two.
+// Steps are deterministic, and Snapshot and Restore save and load the World's
+// state, for replays and rollback.
+type World struct {
+	Gravity  r2.Point // acceleration of Dynamic bodies, in units per second²
+	Damping  float64  // fraction of Dynamic bodies' velocity lost per second
+	TimeStep float64  // length of a single Step, in seconds
+	MaxSteps int      // most Steps per Update (0 for no limit), so slow frames can't snowball
+	// Iterations is how many passes the solver makes over rigid body contacts each
+	// Step; more settle stacks better
+	Iterations int
+
+	// sensor callbacks, called at the end of each Step (see SensorEvent)
+	OnEnter func(SensorEvent)
+	OnStay  func(SensorEvent)
+	OnExit  func(SensorEvent)
+	// QueueEvents keeps SensorEvents until they're taken with DrainEvents
+	QueueEvents bool
+
+	colliders   []Collider
+	index       map[Collider]int
+	broadPhase  *SpatialHash
+	accumulator float64
+	contacts    []Contact
+	overlaps    []sensorPair // sensor pairs overlapping at the end of the last Step
+	events      []SensorEvent
+	impulses    map[contactKey]impulse // from the last Step, for warm starting
+	joints      []Joint
+	connected   map[[2]Collider]bool // pairs of colliders whose joints keep them from colliding
+	platforms   []*Platform
+	ground      map[Collider]groundContact // what carried bodies stood on at the end of the last Step
+}
+
+// Contact is an overlap found between two colliders during a World step.
+// Normal points away from B (toward A), and Depth is how far they overlapped
+// before being pushed apart.
+type Contact struct {
+	A, B   Collider
+	Normal r2.Point
+	Depth  float64
+}
+
+// NewWorld returns an empty World which steps timeStep seconds at a time.
+// cellSize is used for the broad phase grid (see NewSpatialHash).
+func NewWorld(timeStep, cellSize float64) *World {
+	return &World{
+		TimeStep:   timeStep,
+		MaxSteps:   8,
+		Iterations: 8,
+		index:      make(map[Collider]int),
+		broadPhase: NewSpatialHash(cellSize),
+	}
+}
+
+// Add puts c into the World.  Adding a collider twice has no effect.
+func (w *World) Add(c Collider) {
+	if _, ok := w.index[c]; ok {
+		return
+	}
+	body := &c.GetFixture().Body
+	body.PrevPosition = body.Position
+	w.index[c] = len(w.colliders)
+	w.colliders = append(w.colliders, c)
+	w.broadPhase.Insert(c)
+}
+
+// Remove takes c out of the World
+func (w *World) Remove(c Collider) {
+	i, ok := w.index[c]
+	if !ok {
+		return
+	}
+	w.colliders = append(w.colliders[:i], w.colliders[i+1:]...)
+	delete(w.index, c)
+	for j := i; j < len(w.colliders); j++ {
+		w.index[w.colliders[j]] = j
+	}
+	w.broadPhase.Remove(c)
+
+	// and so are its joints
+	kept := w.joints[:0]
+	for _, j := range w.joints {
+		if a, b := j.Colliders(); a != c && b != c {
+			kept = append(kept, j)
+		}
+	}
+	w.joints = kept
+
+	// and so are its platforms
+	keptPlatforms := w.platforms[:0]
+	for _, p := range w.platforms {
+		if p.Collider != c {
+			keptPlatforms = append(keptPlatforms, p)
+		}
+	}
+	w.platforms = keptPlatforms
+
+	// and its contacts
+	for key := range w.impulses {
+		if key.a == c || key.b == c {
+			delete(w.impulses, key)
+		}
+	}
+	keptContacts := w.contacts[:0]
+	for _, contact := range w.contacts {
+		if contact.A != c && contact.B != c {
+			keptContacts = append(keptContacts, contact)
+		}
+	}
+	w.contacts = keptContacts
+	for body, contact := range w.ground {
+		if body == c || contact.ground == c {
+			delete(w.ground, body)
+		}
+	}
+
+	// whatever the collider was overlapping, it isn't anymore
+	keptOverlaps := w.overlaps[:0]
+	for _, pair := range w.overlaps {
+		if pair.sensor == c || pair.other == c {
+			w.emit(Exit, pair)
+		} else {
+			keptOverlaps = append(keptOverlaps, pair)
+		}
+	}
+	w.overlaps = keptOverlaps
+}
+
+// AddJoint connects the colliders of j (which should be in the World too).
+// Adding a joint twice has no effect.
+func (w *World) AddJoint(j Joint) {
+	for _, existing := range w.joints {
+		if existing == j {
+			return
+		}
+	}
+	w.joints = append(w.joints, j)
+}
+
+// RemoveJoint disconnects the colliders of j
+func (w *World) RemoveJoint(j Joint) {
+	for i, existing := range w.joints {
+		if existing == j {
+			w.joints = append(w.joints[:i], w.joints[i+1:]...)
+			return
+		}
+	}
+}
+
+// Joints returns the joints in the World, in the order they were added
+func (w *World) Joints() []Joint {
+	return w.joints
+}
+
+// Colliders returns the colliders in the World, in the order they were added
+func (w *World) Colliders() []Collider {
+	return w.colliders
+}
+
+// Contacts returns the contacts found during the most recent Step
+func (w *World) Contacts() []Contact {
+	return w.contacts
+}
+
+// BroadPhaseCells returns the corners of every broad phase cell holding a collider
+// (see SpatialHash.Cells)
+func (w *World) BroadPhaseCells() [][2]r2.Point {
+	return w.broadPhase.Cells()
+}
+
+// Query returns the colliders matching filter whose bounding boxes overlap the box (min, max)
+func (w *World) Query(min, max r2.Point, filter Filter) []Collider {
+	return w.broadPhase.Query(min, max, filter)
+}
+
+// Raycast returns the first collider matching filter hit by the ray from origin
+// along the unit vector dir, within maxDist
+func (w *World) Raycast(origin, dir r2.Point, maxDist float64, filter Filter) (RaycastHit, bool) {
+	return w.broadPhase.Raycast(origin, dir, maxDist, filter)
+}
+
+// Update advances the World by dt seconds worth of fixed Steps, carrying any
+// remainder over to the next Update.  It returns the number of Steps taken.
+func (w *World) Update(dt float64) int {
+	w.accumulator += dt
+	steps := 0
+	for w.accumulator >= w.TimeStep {
+		if w.MaxSteps > 0 && steps >= w.MaxSteps {
+			// too far behind to catch up, drop the backlog rather than stall
+			w.accumulator = math.Mod(w.accumulator, w.TimeStep)
+			break
+		}
+		w.Step()
+		w.accumulator -= w.TimeStep
+		steps++
+	}
+	return steps
+}
+
+// Alpha returns how far (0 to 1) the time left over from the last Update is into
+// the next Step, for interpolating between bodies' PrevPosition and Position
+func (w *World) Alpha() float64 {
+	return w.accumulator / w.TimeStep
+}
+
+// Step advances the World by exactly one TimeStep: velocities are integrated,
+// platforms are driven, joints and contacts involving rigid bodies are solved, and
+// bodies are moved (and carried by their ground, see Ground).  Then any overlapping
+// pairs are found (broad phase) and the rest are pushed apart (narrow phase).
+func (w *World) Step() {
+	dt := w.TimeStep
+	w.connected = make(map[[2]Collider]bool)
+	for _, j := range w.joints {
+		if a, b := j.Colliders(); !j.base().CollideConnected && b != nil {
+			w.connected[[2]Collider{a, b}] = true
+			w.connected[[2]Collider{b, a}] = true
+		}
+	}
+	for _, c := range w.colliders {
+		body := &c.GetFixture().Body
+		body.PrevPosition, body.PrevAngle = body.Position, body.Angle
+		if body.Kind == Dynamic {
+			damping := 1 / (1 + dt*w.Damping)
+			body.Velocity = body.Velocity.Add(w.Gravity.Mul(dt)).Mul(damping)
+			body.AngularVelocity *= damping
+		}
+		w.broadPhase.Update(c)
+	}
+	for _, p := range w.platforms {
+		p.drive(dt)
+	}
+	w.solve(dt)
+	for _, c := range w.colliders {
+		if c.GetFixture().Kind != Static {
+			integrate(c, dt)
+		}
+		w.broadPhase.Update(c)
+	}
+	// once everything has moved, bodies follow whatever they were standing on
+	for _, c := range w.colliders {
+		if contact, ok := w.ground[c]; ok && carried(c) {
+			ride(c, contact.ground, contact.normal, dt)
+			w.broadPhase.Update(c)
+		}
+	}
+
+	w.contacts = w.contacts[:0]
+	var overlaps []sensorPair
+	w.eachPair(func(a, b Collider) {
+		aFix, bFix := a.GetFixture(), b.GetFixture()
+		isSensor := aFix.Sensor || bFix.Sensor
+		if isSensor && aFix.Kind == Static && bFix.Kind == Static {
+			return
+		}
+		if !isSensor && (aFix.Kind != Dynamic && bFix.Kind != Dynamic || w.connected[[2]Collider{a, b}]) {
+			return
+		}
+		normal, depth, ok := penetrate(a, b)
+		if !ok || depth <= skin {
+			return
+		}
+		if isSensor {
+			if aFix.Sensor {
+				overlaps = append(overlaps, sensorPair{a, b})
+			} else {
+				overlaps = append(overlaps, sensorPair{b, a})
+			}
+			return
+		}
+		contact := Contact{a, b, normal, depth}
+		w.contacts = append(w.contacts, contact)
+		// rigid bodies were already dealt with by the solver
+		if !aFix.rigid() && !bFix.rigid() {
+			resolveContact(contact, w.rides(a, b) || w.rides(b, a))
+		}
+	})
+	w.findGround()
+	w.updateOverlaps(overlaps)
+}
+
+// eachPair calls fn for every pair of colliders whose bounding boxes overlap,
+// in the order they were added to the World
+func (w *World) eachPair(fn func(a, b Collider)) {
+	for i, a := range w.colliders {
+		aMin, aMax := a.Bounds()
+		for _, b := range w.broadPhase.Query(aMin, aMax, QueryAll) {
+			if w.index[b] > i {
+				fn(a, b)
+			}
+		}
+	}
+}
+
+// resolveContact pushes the Dynamic bodies of a contact apart and removes the
+// part of their velocities which would move them back into each other.
+// riding is set when a Dynamic body is carried by the other (see World.Ground),
+// so its Velocity is already relative to it.
+func resolveContact(contact Contact, riding bool) {
+	a, b := &contact.A.GetFixture().Body, &contact.B.GetFixture().Body
+	n := contact.Normal
+	switch {
+	case a.Kind == Dynamic && b.Kind == Dynamic:
+		a.Position = a.Position.Add(n.Mul(contact.Depth / 2))
+		b.Position = b.Position.Sub(n.Mul(contact.Depth / 2))
+		if closing := a.Velocity.Sub(b.Velocity).Dot(n); closing < 0 {
+			a.Velocity = a.Velocity.Sub(n.Mul(closing / 2))
+			b.Velocity = b.Velocity.Add(n.Mul(closing / 2))
+		}
+	case a.Kind == Dynamic:
+		a.Position = a.Position.Add(n.Mul(contact.Depth))
+		frame := b.Velocity
+		if riding {
+			frame = r2.Point{}
+		}
+		a.Velocity = clipInto(a.Velocity.Sub(frame), n).Add(frame)
+	case b.Kind == Dynamic:
+		b.Position = b.Position.Sub(n.Mul(contact.Depth))
+		frame := a.Velocity
+		if riding {
+			frame = r2.Point{}
+		}
+		b.Velocity = clipInto(b.Velocity.Sub(frame), n.Mul(-1)).Add(frame)
+	}
+}
+
+// EventKind is the kind of change in a sensor overlap
+type EventKind int
+
+const (
+	Enter EventKind = iota // started overlapping during the Step
+	Stay                   // still overlapping since the previous Step
+	Exit                   // stopped overlapping (or one of the colliders was removed)
+)
+
+// SensorEvent reports an overlap involving a Sensor collider.
+// When both colliders are sensors, Sensor is the one added to the World first.
+type SensorEvent struct {
+	Kind        EventKind
+	Sensor      Collider
+	Other       Collider
+	SensorOwner interface{}
+	OtherOwner  interface{}
+}
+
+// sensorPair is a Sensor collider and another collider it overlaps
+type sensorPair struct {
+	sensor, other Collider
+}
+
+// updateOverlaps compares the sensor pairs found this Step with those from the last
+// one, and sends out the resulting events in a stable order: Enter and Stay events
+// in the order pairs were found, then Exit events in the order they were found before.
+// Callbacks may Remove colliders: Remove sends an Exit for each pair of theirs that
+// has entered, and their events still to come are dropped.
+func (w *World) updateOverlaps(overlaps []sensorPair) {
+	previous := make(map[sensorPair]bool, len(w.overlaps))
+	for _, pair := range w.overlaps {
+		previous[pair] = true
+	}
+	current := make(map[sensorPair]bool, len(overlaps))
+	for _, pair := range overlaps {
+		current[pair] = true
+	}
+	// while events go out, w.overlaps holds the pairs which have entered and not
+	// exited, for Remove: those staying, and those entering once their Enter is sent
+	var exits []sensorPair
+	entered := make([]sensorPair, 0, len(overlaps))
+	for _, pair := range w.overlaps {
+		if current[pair] {
+			entered = append(entered, pair)
+		} else {
+			exits = append(exits, pair)
+		}
+	}
+	w.overlaps = entered
+	for _, pair := range overlaps {
+		if !w.contains(pair.sensor) || !w.contains(pair.other) {
+			continue
+		}
+		if previous[pair] {
+			w.emit(Stay, pair)
+		} else {
+			w.overlaps = append(w.overlaps, pair)
+			w.emit(Enter, pair)
+		}
+	}
+	kept := overlaps[:0]
+	for _, pair := range overlaps {
+		if w.contains(pair.sensor) && w.contains(pair.other) {
+			kept = append(kept, pair)
+		}
+	}
+	w.overlaps = kept
+	for _, pair := range exits {
+		w.emit(Exit, pair)
+	}
+}
+
+// contains returns whether c is in the World
+func (w *World) contains(c Collider) bool {
+	_, ok := w.index[c]
+	return ok
+}
+
+// emit passes an event for pair to the matching callback and the queue
+func (w *World) emit(kind EventKind, pair sensorPair) {
+	event := SensorEvent{kind, pair.sensor, pair.other, pair.sensor.GetFixture().Owner, pair.other.GetFixture().Owner}
+	if w.QueueEvents {
+		w.events = append(w.events, event)
+	}
+	var callback func(SensorEvent)
+	switch kind {
+	case Enter:
+		callback = w.OnEnter
+	case Stay:
+		callback = w.OnStay
+	case Exit:
+		callback = w.OnExit
+	}
+	if callback != nil {
+		callback(event)
+	}
+}
+
+// DrainEvents returns the SensorEvents queued since the last call and empties the
+// queue.  Events are only queued while QueueEvents is set.
+func (w *World) DrainEvents() []SensorEvent {
+	events := w.events
+	w.events = nil
+	return events
+}
@@ -0,0 +1,200 @@
+package mech
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+)
+
+func Test_WorldFixedStep(t *testing.T) {
+	world := NewWorld(1.0/60, 32)
+	box := newBox(0, 0, 10, 10)
+	box.Velocity = r2.Point{60, 0}
+	world.Add(box)
+
+	// 2.5 steps worth of time: two steps, half a step left over
+	if steps := world.Update(2.5 / 60); steps != 2 {
+		t.Errorf("World.Update: expected 2 steps, got %d", steps)
+	}
+	if math.Abs(world.Alpha()-0.5) > 1e-9 {
+		t.Errorf("World.Alpha: expected 0.5, got %v", world.Alpha())
+	}
+	if math.Abs(box.Position.X-2) > 1e-9 {
+		t.Errorf("World.Update: expected box at x = 2, got %v", box.Position)
+	}
+	if pos := box.Interpolate(world.Alpha()); math.Abs(pos.X-1.5) > 1e-9 {
+		t.Errorf("Body.Interpolate: expected x = 1.5, got %v", pos)
+	}
+}
+
+func Test_WorldSettles(t *testing.T) {
+	world := NewWorld(1.0/60, 32)
+	world.Gravity = r2.Point{0, 500}
+	floor := newBox(-100, 20, 200, 10)
+	floor.Kind = Static
+	box := newBox(0, 0, 10, 10)
+	world.Add(floor)
+	world.Add(box)
+
+	for i := 0; i < 120; i++ {
+		world.Step()
+	}
+	if math.Abs(box.Position.Y-10) > 0.5 {
+		t.Errorf("World (settle): expected box resting at y = 10, got %v", box.Position)
+	}
+	if floor.Position != (r2.Point{-100, 20}) {
+		t.Errorf("World (settle): static floor moved to %v", floor.Position)
+	}
+	if len(world.Contacts()) != 1 || world.Contacts()[0].A != Collider(floor) {
+		t.Errorf("World (settle): expected a single floor contact, got %v", world.Contacts())
+	}
+}
+
+func Test_WorldRaycast(t *testing.T) {
+	world := NewWorld(1.0/60, 32)
+	wall := newBox(50, -50, 10, 100)
+	world.Add(wall)
+	hit, ok := world.Raycast(r2.Point{0, 0}, r2.Point{1, 0}, 100, QueryAll)
+	if !ok || hit.Collider != Collider(wall) || math.Abs(hit.Distance-50) > 1e-9 || hit.Normal != (r2.Point{-1, 0}) {
+		t.Errorf("World.Raycast: expected wall hit at 50 facing -x, got %v, %v", ok, hit)
+	}
+	if _, ok := world.Raycast(r2.Point{0, 0}, r2.Point{1, 0}, 40, QueryAll); ok {
+		t.Errorf("World.Raycast: expected no hit within 40")
+	}
+}
+
+func Test_WorldFilters(t *testing.T) {
+	const (
+		layerPlayer = 1 << iota
+		layerEnemy
+		layerBullet
+	)
+	enemy := newBox(0, 0, 10, 10)
+	enemy.Category, enemy.Mask = layerEnemy, layerPlayer|layerBullet
+	bullet := newBox(5, 5, 2, 2)
+	bullet.Category, bullet.Mask = layerBullet, layerPlayer
+	player := newBox(5, 5, 10, 10)
+	player.Category = layerPlayer
+
+	if bullet.Collides(enemy) || enemy.Collides(bullet) {
+		t.Errorf("Filter: enemy bullets should ignore enemies")
+	}
+	if !bullet.Collides(player) {
+		t.Errorf("Filter: enemy bullets should hit the player")
+	}
+	enemy.Group, player.Group = 2, 2
+	enemy.Mask = 0
+	if !enemy.Collides(player) {
+		t.Errorf("Filter: shared positive group should always collide")
+	}
+	player.ShouldCollide = func(self, other Collider) bool { return other != Collider(enemy) }
+	if enemy.Collides(player) {
+		t.Errorf("Filter: ShouldCollide should veto the pair")
+	}
+
+	world := NewWorld(1.0/60, 32)
+	world.Add(enemy)
+	world.Add(player)
+	ray := Filter{Category: layerBullet, Mask: layerEnemy}
+	if hit, ok := world.Raycast(r2.Point{-10, 12}, r2.Point{1, 0}, 100, ray); ok {
+		t.Errorf("World.Raycast (filtered): expected to miss the player, hit %v", hit)
+	}
+	if found := world.Query(r2.Point{0, 0}, r2.Point{20, 20}, Filter{Category: layerBullet, Mask: layerPlayer}); len(found) != 1 || found[0] != Collider(player) {
+		t.Errorf("World.Query (filtered): expected only the player, got %v", found)
+	}
+}
+
+func Test_WorldSensorEvents(t *testing.T) {
+	world := NewWorld(1.0/60, 32)
+	world.QueueEvents = true
+	door := newBox(20, 0, 10, 10)
+	door.Sensor = true
+	door.Kind = Static
+	door.Owner = "door"
+	player := newBox(0, 0, 10, 10)
+	player.Kind = Kinematic
+	player.Owner = "player"
+	player.Velocity = r2.Point{300, 0}
+	world.Add(door)
+	world.Add(player)
+
+	exits := 0
+	world.OnExit = func(e SensorEvent) { exits++ }
+	var kinds []EventKind
+	for i := 0; i < 6; i++ {
+		world.Step()
+		for _, e := range world.DrainEvents() {
+			if e.Sensor != Collider(door) || e.SensorOwner != "door" || e.OtherOwner != "player" {
+				t.Errorf("SensorEvent: unexpected event %v", e)
+			}
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	// the player overlaps the door during steps 3 to 5, and only touches it after step 6
+	expected := []EventKind{Enter, Stay, Stay, Exit}
+	if len(kinds) != len(expected) {
+		t.Fatalf("SensorEvent: expected %v, got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Errorf("SensorEvent: expected %v, got %v", expected, kinds)
+		}
+	}
+	if exits != 1 {
+		t.Errorf("World.OnExit: expected 1 call, got %d", exits)
+	}
+	if player.Position.X != 30 {
+		t.Errorf("SensorEvent: sensor shouldn't push the player, got %v", player.Position)
+	}
+}
+
+func Test_WorldSensorRemove(t *testing.T) {
+	cases := []struct {
+		name     string
+		removeOn EventKind
+		player   []EventKind
+		// the door finds the bystander after the player, so it hasn't entered yet
+		// when the player's Enter goes out
+		bystander []EventKind
+	}{
+		{"on enter", Enter, []EventKind{Enter, Exit}, nil},
+		{"on stay", Stay, []EventKind{Enter, Stay, Exit}, []EventKind{Enter, Exit}},
+	}
+	for _, c := range cases {
+		world := NewWorld(1.0/60, 32)
+		world.QueueEvents = true
+		door := newBox(0, 0, 10, 10)
+		door.Sensor = true
+		door.Kind = Static
+		player := newBox(5, 0, 10, 10)
+		player.Kind = Kinematic
+		bystander := newBox(0, 5, 10, 10)
+		bystander.Kind = Kinematic
+		world.Add(door)
+		world.Add(player)
+		world.Add(bystander)
+
+		remove := func(e SensorEvent) {
+			if e.Kind == c.removeOn && e.Other == Collider(player) {
+				world.Remove(player)
+				world.Remove(bystander)
+			}
+		}
+		world.OnEnter, world.OnStay = remove, remove
+		kinds := make(map[Collider][]EventKind)
+		for i := 0; i < 4; i++ {
+			world.Step()
+			for _, e := range world.DrainEvents() {
+				kinds[e.Other] = append(kinds[e.Other], e.Kind)
+			}
+		}
+		if got := kinds[player]; fmt.Sprint(got) != fmt.Sprint(c.player) {
+			t.Errorf("World.Remove (%s): expected the player to get %v, got %v", c.name, c.player, got)
+		}
+		if got := kinds[bystander]; fmt.Sprint(got) != fmt.Sprint(c.bystander) {
+			t.Errorf("World.Remove (%s): expected the bystander to get %v, got %v", c.name, c.bystander, got)
+		}
+	}
+}
@@ -1,8 +1,25 @@
 package engine
 
-// Orientation
-type Orientation struct {
-	HorizFlip bool
-	VertFlip  bool
-	DiagFlip  bool
+import (
+	"github.com/hajimehoshi/ebiten"
+
+	"github.com/jwlarocque/engine/mech"
+	"github.com/jwlarocque/engine/r2extra"
+)
+
+// Orientation is how a tile, sprite or collider is flipped and quarter turned
+// (see mech.Orientation)
+type Orientation = mech.Orientation
+
+// Rotation returns the Orientation turning things quarters quarter turns
+// clockwise (see mech.Rotation)
+func Rotation(quarters int) Orientation {
+	return mech.Rotation(quarters)
+}
+
+// OrientedGeoM returns the matrix drawing img reoriented by o in place (see
+// r2extra.OrientedGeoM)
+func OrientedGeoM(o Orientation, img *ebiten.Image) ebiten.GeoM {
+	size := img.Bounds().Size()
+	return r2extra.OrientedGeoM(o, float64(size.X), float64(size.Y))
 }
@@ -7,19 +7,28 @@ import (
 
 	"github.com/golang/geo/r2"
 	"github.com/hajimehoshi/ebiten"
+
+	"github.com/jwlarocque/engine/mech"
 )
 
 // == Extra Matrix Transforms ========
 
-// RotatedQuarter returns matrix rotated 90 degrees counter-clockwise
-func RotatedQuarter(matrix ebiten.GeoM) ebiten.GeoM {
+// OrientedGeoM returns the matrix drawing a w by h image reoriented by o in place,
+// i.e. into the box from (0, 0) to (w, h), or to (h, w) if o swaps them
+func OrientedGeoM(o mech.Orientation, w, h float64) ebiten.GeoM {
 	ret := ebiten.GeoM{}
-	ret.SetElement(0, 0, -matrix.Element(1, 0))
-	ret.SetElement(0, 1, -matrix.Element(1, 1))
-	ret.SetElement(0, 2, -matrix.Element(1, 2))
-	ret.SetElement(1, 0, matrix.Element(0, 0))
-	ret.SetElement(1, 1, matrix.Element(0, 1))
-	ret.SetElement(1, 2, matrix.Element(0, 2))
+	ret.Translate(-w/2, -h/2)
+	a, b, c, d := o.Matrix()
+	flip := ebiten.GeoM{}
+	flip.SetElement(0, 0, a)
+	flip.SetElement(0, 1, b)
+	flip.SetElement(1, 0, c)
+	flip.SetElement(1, 1, d)
+	ret.Concat(flip)
+	if o.Swapped() {
+		w, h = h, w
+	}
+	ret.Translate(w/2, h/2)
 	return ret
 }
 
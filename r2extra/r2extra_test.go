@@ -0,0 +1,35 @@
+package r2extra
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+func Test_OrientedGeoM(t *testing.T) {
+	// where the top left and top right corners of a 4 by 2 image end up
+	cases := []struct {
+		name        string
+		orientation mech.Orientation
+		left, right r2.Point
+	}{
+		{"none", mech.Orientation{}, r2.Point{0, 0}, r2.Point{4, 0}},
+		{"horizontal", mech.Orientation{HorizFlip: true}, r2.Point{4, 0}, r2.Point{0, 0}},
+		{"vertical", mech.Orientation{VertFlip: true}, r2.Point{0, 2}, r2.Point{4, 2}},
+		{"diagonal", mech.Orientation{DiagFlip: true}, r2.Point{0, 0}, r2.Point{0, 4}},
+		{"quarter turn", mech.Rotation(1), r2.Point{2, 0}, r2.Point{2, 4}},
+	}
+	for _, c := range cases {
+		g := OrientedGeoM(c.orientation, 4, 2)
+		x, y := g.Apply(0, 0)
+		if left := (r2.Point{x, y}); !ApproxEqual(left, c.left) {
+			t.Errorf("OrientedGeoM (%s): expected the top left corner at %v, got %v", c.name, c.left, left)
+		}
+		x, y = g.Apply(4, 0)
+		if right := (r2.Point{x, y}); !ApproxEqual(right, c.right) {
+			t.Errorf("OrientedGeoM (%s): expected the top right corner at %v, got %v", c.name, c.right, right)
+		}
+	}
+}
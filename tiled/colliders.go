@@ -0,0 +1,211 @@
+package tiled
+
+import (
+	"log"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+// ColliderMode selects how TerrainColliders covers the solid tiles of a Map
+type ColliderMode int
+
+const (
+	// TileColliders makes one square PolyCollider per solid tile
+	TileColliders ColliderMode = iota
+	// MergedColliders greedily merges solid tiles into as few rectangular
+	// PolyColliders as it can
+	MergedColliders
+	// OutlineColliders traces the outline of each connected group of solid tiles
+	// (and of any holes in it) into a looping ChainCollider, so there are no seams
+	// between tiles at all
+	OutlineColliders
+)
+
+// isSolid returns whether the tile at (x, y) gets a collider.
+// Currently every non-empty tile is solid; tiles outside the map are not.
+func (m *Map) isSolid(x, y int) bool {
+	_, _, ok := m.GetTile(x, y)
+	return ok
+}
+
+// TerrainColliders returns Static colliders covering the solid tiles of the Map,
+// built according to mode
+func (m *Map) TerrainColliders(mode ColliderMode) []mech.Collider {
+	switch mode {
+	case MergedColliders:
+		return m.mergedColliders()
+	case OutlineColliders:
+		return m.outlineColliders()
+	default:
+		return m.tileColliders()
+	}
+}
+
+// rectCollider returns a Static PolyCollider covering w by h tiles from tile (x, y)
+func (m *Map) rectCollider(x, y, w, h int) *mech.PolyCollider {
+	width, height := float64(w*m.Tileset.tileWidth), float64(h*m.Tileset.tileHeight)
+	coll, err := mech.NewPolyCollider([]*r2.Point{{0.0, 0.0}, {width, 0.0}, {width, height}, {0.0, height}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	coll.Position = getTilePos(m, y*m.width+x)
+	coll.Kind = mech.Static
+	return coll
+}
+
+func (m *Map) tileColliders() []mech.Collider {
+	var colliders []mech.Collider
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if m.isSolid(x, y) {
+				colliders = append(colliders, m.rectCollider(x, y, 1, 1))
+			}
+		}
+	}
+	return colliders
+}
+
+// mergedColliders covers the solid tiles with rectangles: starting from each
+// uncovered solid tile (row by row), a rectangle is grown as far right as it can,
+// then as far down as the whole row below allows
+func (m *Map) mergedColliders() []mech.Collider {
+	var colliders []mech.Collider
+	covered := make([]bool, m.width*m.height)
+	free := func(x, y int) bool {
+		return m.isSolid(x, y) && !covered[y*m.width+x]
+	}
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if !free(x, y) {
+				continue
+			}
+			w := 1
+			for free(x+w, y) {
+				w++
+			}
+			h := 1
+		grow:
+			for y+h < m.height {
+				for i := 0; i < w; i++ {
+					if !free(x+i, y+h) {
+						break grow
+					}
+				}
+				h++
+			}
+			for j := 0; j < h; j++ {
+				for i := 0; i < w; i++ {
+					covered[(y+j)*m.width+x+i] = true
+				}
+			}
+			colliders = append(colliders, m.rectCollider(x, y, w, h))
+		}
+	}
+	return colliders
+}
+
+// corner is a tile corner, in tiles
+type corner struct {
+	X, Y int
+}
+
+// tileEdge is one side of a tile, from one corner to the next
+type tileEdge struct {
+	from, to corner
+}
+
+// outlineColliders traces the boundary between solid and empty tiles into loops.
+// Every tile side with solid on one side and empty on the other becomes a unit edge,
+// directed so the solid tile is on its right (as mech.ChainCollider expects).
+// The edges are then joined end to end into loops, and runs of edges along
+// the same line are merged.
+func (m *Map) outlineColliders() []mech.Collider {
+	var edges []tileEdge
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if !m.isSolid(x, y) {
+				continue
+			}
+			if !m.isSolid(x, y-1) {
+				edges = append(edges, tileEdge{corner{x, y}, corner{x + 1, y}})
+			}
+			if !m.isSolid(x+1, y) {
+				edges = append(edges, tileEdge{corner{x + 1, y}, corner{x + 1, y + 1}})
+			}
+			if !m.isSolid(x, y+1) {
+				edges = append(edges, tileEdge{corner{x + 1, y + 1}, corner{x, y + 1}})
+			}
+			if !m.isSolid(x-1, y) {
+				edges = append(edges, tileEdge{corner{x, y + 1}, corner{x, y}})
+			}
+		}
+	}
+
+	outgoing := make(map[corner][]int)
+	for i, e := range edges {
+		outgoing[e.from] = append(outgoing[e.from], i)
+	}
+	used := make([]bool, len(edges))
+
+	var colliders []mech.Collider
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var loop []corner
+		for i := start; !used[i]; {
+			used[i] = true
+			loop = append(loop, edges[i].from)
+			i = nextEdge(edges, i, outgoing[edges[i].to])
+		}
+		loop = dropStraightCorners(loop)
+		if len(loop) < 3 {
+			continue
+		}
+
+		vertices := make([]*r2.Point, len(loop))
+		for i, c := range loop {
+			vertices[i] = &r2.Point{float64(c.X * m.Tileset.tileWidth), float64(c.Y * m.Tileset.tileHeight)}
+		}
+		chain, err := mech.NewChainCollider(vertices, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		chain.Kind = mech.Static
+		colliders = append(colliders, chain)
+	}
+	return colliders
+}
+
+// nextEdge picks which of the candidate edges continues the loop after edges[i].
+// Where two solid tiles only touch diagonally there are two candidates; always
+// turning right (toward the solid side) keeps them in separate loops.
+func nextEdge(edges []tileEdge, i int, candidates []int) int {
+	in := corner{edges[i].to.X - edges[i].from.X, edges[i].to.Y - edges[i].from.Y}
+	best, bestTurn := candidates[0], 0
+	for n, j := range candidates {
+		out := corner{edges[j].to.X - edges[j].from.X, edges[j].to.Y - edges[j].from.Y}
+		// with y down, a positive cross product is a right turn
+		turn := in.X*out.Y - in.Y*out.X
+		if n == 0 || turn > bestTurn {
+			best, bestTurn = j, turn
+		}
+	}
+	return best
+}
+
+// dropStraightCorners removes the corners of a loop which lie on a straight line
+// between their neighbours
+func dropStraightCorners(loop []corner) []corner {
+	var kept []corner
+	for i, c := range loop {
+		prev := loop[(i+len(loop)-1)%len(loop)]
+		next := loop[(i+1)%len(loop)]
+		if (c.X-prev.X)*(next.Y-c.Y)-(c.Y-prev.Y)*(next.X-c.X) != 0 {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,62 @@
+package tiled
+
+import (
+	"testing"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+// newTestMap returns a Map of 16x16 tiles from rows of '#' (solid) and '.' (empty)
+func newTestMap(rows ...string) *Map {
+	var gids []uint32
+	for _, row := range rows {
+		for _, c := range row {
+			var gid uint32
+			if c == '#' {
+				gid = 1
+			}
+			gids = append(gids, gid)
+		}
+	}
+	m, err := NewMapFromGIDs(len(rows[0]), len(rows), 16, 16, gids)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func Test_TerrainColliders(t *testing.T) {
+	m := newTestMap(
+		"###.",
+		"###.",
+		"...#",
+	)
+	if n := len(m.TerrainColliders(TileColliders)); n != 7 {
+		t.Errorf("TerrainColliders (tiles): expected 7 colliders, got %v", n)
+	}
+	if n := len(m.TerrainColliders(MergedColliders)); n != 2 {
+		t.Errorf("TerrainColliders (merged): expected 2 colliders, got %v", n)
+	}
+	outlines := m.TerrainColliders(OutlineColliders)
+	if len(outlines) != 2 {
+		t.Fatalf("TerrainColliders (outline): expected 2 loops, got %v", len(outlines))
+	}
+	block := outlines[0].(*mech.ChainCollider)
+	if len(block.Vertices) != 4 {
+		t.Errorf("TerrainColliders (outline): expected a 4 corner loop, got %v", block.Vertices)
+	}
+	if min, max := block.Bounds(); min.X != 0 || min.Y != 0 || max.X != 48 || max.Y != 32 {
+		t.Errorf("TerrainColliders (outline): expected bounds (0, 0) to (48, 32), got %v to %v", min, max)
+	}
+}
+
+func Test_TerrainCollidersHole(t *testing.T) {
+	m := newTestMap(
+		"###",
+		"#.#",
+		"###",
+	)
+	if n := len(m.TerrainColliders(OutlineColliders)); n != 2 {
+		t.Errorf("TerrainColliders (hole): expected outer and inner loops, got %v", n)
+	}
+}
@@ -0,0 +1,249 @@
+package tiled
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/png" // tileset images
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// == Decoding in the Background ========
+
+// DecodedMap is a map file, its tileset and the tileset's image, read and decoded
+// but not yet on the GPU (see DecodeMap)
+type DecodedMap struct {
+	m            *Map
+	tilesetImage image.Image
+}
+
+// how much of DecodeMap's progress each step makes up; decoding the tileset image
+// takes the rest
+const (
+	mapFileProgress     = 0.05
+	tilesetFileProgress = 0.1
+)
+
+// DecodeMap reads and decodes a TMX or JSON map file (whatever its extension),
+// its tileset and the tileset's image.  It makes no ebiten calls, so it can run on
+// any goroutine, leaving Upload to do the GPU work on the main thread.  It stops
+// early with ctx's error if ctx is done, and reports how far it has got (from 0
+// to 1) to progress, if set.
+func DecodeMap(ctx context.Context, filePath string, progress func(float64)) (*DecodedMap, error) {
+	report := func(p float64) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+	report(0)
+	newMap, tilesetPath, err := decodeMapFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	report(mapFileProgress)
+
+	// the decoder reads as it goes, so progress through the file is progress decoding
+	tileset, img, err := decodeTileset(ctx, tilesetPath, func(p float64) {
+		report(mapFileProgress + tilesetFileProgress + p*(1-mapFileProgress-tilesetFileProgress))
+	})
+	if err != nil {
+		return nil, err
+	}
+	newMap.Tileset = tileset
+	report(1)
+	return &DecodedMap{newMap, img}, nil
+}
+
+// LoadMap loads a TMX or JSON map file (whatever its extension), getting its
+// tileset from tilesets (LoadTileset, to load it from its file), and renders it
+func LoadMap(filePath string, tilesets TilesetSource) (*Map, error) {
+	newMap, tilesetPath, err := decodeMapFile(context.Background(), filePath)
+	if err != nil {
+		return nil, err
+	}
+	if newMap.Tileset, err = tilesets(tilesetPath); err != nil {
+		return nil, err
+	}
+	if err := newMap.render(); err != nil {
+		return nil, err
+	}
+	return newMap, nil
+}
+
+// LoadTileset loads a TSX or JSON tileset file (whatever its extension) and its
+// image, returning any problem as an error
+func LoadTileset(filePath string) (*Tileset, error) {
+	tileset, img, err := decodeTileset(context.Background(), filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tileset.tilesImage, err = ebiten.NewImageFromImage(img, ebiten.FilterDefault); err != nil {
+		return nil, err
+	}
+	return tileset, nil
+}
+
+// decodeMapFile reads and converts a map file, without its tileset or Image, and
+// returns the path of its tileset (relative to the working directory, like filePath)
+func decodeMapFile(ctx context.Context, filePath string) (*Map, string, error) {
+	data, err := readFile(ctx, filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	var newMap *Map
+	var tilesetPath string
+	switch fileFormat(data) {
+	case '<':
+		var raw mapXML
+		if err := xml.Unmarshal(data, &raw); err != nil {
+			return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
+		}
+		newMap, tilesetPath, err = mapFromXML(raw, filePath)
+	case '{':
+		var raw mapJSON
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
+		}
+		newMap, tilesetPath, err = mapFromJSON(raw, filePath)
+	default:
+		return nil, "", fmt.Errorf("map at %s is neither TMX nor JSON", filePath)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return newMap, resolvePath(filePath, tilesetPath), nil
+}
+
+// decodeTileset reads and converts a tileset file and decodes its image, which it
+// returns separately.  progress, if set, is told how far through the image file
+// decoding is.
+func decodeTileset(ctx context.Context, filePath string, progress func(float64)) (*Tileset, image.Image, error) {
+	data, err := readFile(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	tileset := &Tileset{}
+	switch fileFormat(data) {
+	case '<':
+		var raw tilesetXML
+		if err := xml.Unmarshal(data, &raw); err != nil {
+			return nil, nil, fmt.Errorf("TSX XML at %s: %v", filePath, err)
+		}
+		if err := tilesetFromXML(tileset, raw, filePath); err != nil {
+			return nil, nil, err
+		}
+	case '{':
+		var raw tilesetJSON
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, nil, fmt.Errorf("tileset at %s: %v", filePath, err)
+		}
+		if err := tilesetFromJSON(tileset, raw, filePath); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("tileset at %s is neither TSX nor JSON", filePath)
+	}
+
+	tileset.imagePath = resolvePath(filePath, tileset.imagePath)
+	f, err := os.Open(tileset.imagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	r, err := newProgressReader(ctx, f, progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("tileset image %s: %v", tileset.imagePath, err)
+	}
+	return tileset, img, nil
+}
+
+// resolvePath returns the path of a file which the file at from refers to by path:
+// as Tiled writes them, relative to from's directory (unless absolute)
+func resolvePath(from, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// fileFormat returns '<' for an XML (TMX or TSX) file and '{' for a JSON one,
+// going by the first thing in it, or 0 for anything else
+func fileFormat(data []byte) byte {
+	data = bytes.TrimLeft(data, "\ufeff \t\r\n")
+	if len(data) == 0 || (data[0] != '<' && data[0] != '{') {
+		return 0
+	}
+	return data[0]
+}
+
+// Upload puts the map's images on the GPU and returns the Map.  Call it on the
+// main thread.
+func (d *DecodedMap) Upload() (*Map, error) {
+	var err error
+	if d.m.Tileset.tilesImage, err = ebiten.NewImageFromImage(d.tilesetImage, ebiten.FilterDefault); err != nil {
+		return nil, err
+	}
+	if err := d.m.render(); err != nil {
+		d.m.Tileset.Dispose()
+		return nil, err
+	}
+	return d.m, nil
+}
+
+// readFile reads the file at filePath, stopping early if ctx is done
+func readFile(ctx context.Context, filePath string) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := newProgressReader(ctx, f, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// progressReader reads a file, failing with ctx's error once ctx is done, and
+// reports the fraction of the file read so far to progress (if set)
+type progressReader struct {
+	ctx      context.Context
+	f        *os.File
+	read     int64
+	size     int64
+	progress func(float64)
+}
+
+func newProgressReader(ctx context.Context, f *os.File, progress func(float64)) (*progressReader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &progressReader{ctx: ctx, f: f, size: info.Size(), progress: progress}, nil
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.f.Read(p)
+	r.read += int64(n)
+	if r.progress != nil && r.size > 0 && r.read <= r.size {
+		r.progress(float64(r.read) / float64(r.size))
+	}
+	return n, err
+}
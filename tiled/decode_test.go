@@ -0,0 +1,140 @@
+package tiled
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestMap writes a 3x2 tile map using a 2x2 tileset of 8x8 tiles to dir, laid
+// out like a game's data: the map in levels/, and the tileset and its image in
+// tiles/, each referring to the next relative to itself as Tiled writes them.  It
+// returns the map's path.
+func writeTestMap(t *testing.T, dir string) string {
+	for _, sub := range []string{"levels", "tiles"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, "tiles", "tiles.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 16, 16))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	files := map[string]string{
+		"tiles/tiles.tsx": `<tileset tilewidth="8" tileheight="8" tilecount="4" columns="2">
+	<image source="tiles.png"/>
+</tileset>`,
+		"levels/level.tmx": `<map width="3" height="2">
+	<tileset firstgid="1" source="../tiles/tiles.tsx"/>
+	<layer><data encoding="csv">
+1,2,3,
+4,2147483649,1
+</data></layer>
+	<objectgroup name="spawns"><object id="1" name="player" x="4" y="4"/></objectgroup>
+</map>`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "levels", "level.tmx")
+}
+
+func Test_DecodeMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestMap(t, dir)
+
+	var reports []float64
+	decoded, err := DecodeMap(context.Background(), path, func(p float64) { reports = append(reports, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := decoded.m
+	if m.width != 3 || m.height != 2 || len(m.tileData) != 6 || m.tileData[4] != 0x80000001 {
+		t.Errorf("DecodeMap: expected 3x2 tiles with the fifth flipped, got %dx%d %v", m.width, m.height, m.tileData)
+	}
+	if m.Tileset.GetImagePath() != filepath.Join(dir, "tiles", "tiles.png") {
+		t.Errorf("DecodeMap (tileset image): expected it found next to the tileset, got %s", m.Tileset.GetImagePath())
+	}
+	if m.Tileset.numCols != 2 || m.Tileset.tileWidth != 8 || decoded.tilesetImage.Bounds().Dx() != 16 {
+		t.Errorf("DecodeMap (tileset): expected 2 columns of 8 pixel tiles in a 16 pixel image, got %d of %d in %v",
+			m.Tileset.numCols, m.Tileset.tileWidth, decoded.tilesetImage.Bounds())
+	}
+	if len(m.ObjectGroups) != 1 || m.ObjectGroups[0].Objects[0].Name != "player" {
+		t.Errorf("DecodeMap (objects): expected the player spawn, got %v", m.ObjectGroups)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] < reports[i-1] {
+			t.Errorf("DecodeMap (progress): expected progress never to go back, got %v", reports)
+			break
+		}
+	}
+	if len(reports) < 3 || reports[0] != 0 || reports[len(reports)-1] != 1 {
+		t.Errorf("DecodeMap (progress): expected progress from 0 to 1, got %v", reports)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DecodeMap(ctx, path, nil); err != context.Canceled {
+		t.Errorf("DecodeMap (cancelled): expected context.Canceled, got %v", err)
+	}
+	if _, err := DecodeMap(context.Background(), filepath.Join(dir, "missing.tmx"), nil); err == nil {
+		t.Errorf("DecodeMap (missing): expected an error")
+	}
+	// the format goes by what's in the file, not its name
+	renamed := filepath.Join(dir, "levels", "level.map")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeMap(context.Background(), renamed, nil); err != nil {
+		t.Errorf("DecodeMap (TMX without .tmx): expected it decoded, got %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("width=3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeMap(context.Background(), path, nil); err == nil {
+		t.Errorf("DecodeMap (neither TMX nor JSON): expected an error")
+	}
+	os.Rename(renamed, path)
+	os.Remove(filepath.Join(dir, "tiles", "tiles.png"))
+	if _, err := DecodeMap(context.Background(), path, nil); err == nil {
+		t.Errorf("DecodeMap (missing tileset image): expected an error")
+	}
+}
+
+func Test_LoadMapTilesetError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestMap(t, dir)
+
+	failure := fmt.Errorf("no tileset")
+	var tilesetPath string
+	m, err := LoadMap(path, func(path string) (*Tileset, error) {
+		tilesetPath = path
+		return nil, failure
+	})
+	if m != nil || err != failure {
+		t.Errorf("LoadMap (tileset error): expected the tileset's error, got %v (%v)", m, err)
+	}
+	if expected := filepath.Join(dir, "tiles", "tiles.tsx"); tilesetPath != expected {
+		t.Errorf("LoadMap (tileset path): expected %s, relative to the map, got %s", expected, tilesetPath)
+	}
+}
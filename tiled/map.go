@@ -1,12 +1,8 @@
 package tiled
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
 	"strconv"
 	"strings"
 
@@ -20,70 +16,114 @@ import (
 
 // Tiled JSON Format: https://doc.mapeditor.org/en/stable/reference/json-map-format/
 // Tiled TMX Format: https://doc.mapeditor.org/en/stable/reference/tmx-map-format/
-//
-// TODO: move colliders to level.go (in package engine)
-// TODO: reduce use of log.Fatal
 
 // Map represents the data about a level which can be found in a Tiled file
 // TODO: maybe Map can be just ebiten.Image
 // (discard tileset etc. after running the constructor)
 type Map struct {
-	Image            *ebiten.Image
-	Tileset          *Tileset
-	terrainColliders []*mech.PolyCollider
-	tileData         []uint32
-	width            int // map width in tiles
-	height           int // map height in tiles
+	Image        *ebiten.Image
+	Tileset      *Tileset
+	ObjectGroups []ObjectGroup
+	tileData     []uint32
+	width        int // map width in tiles
+	height       int // map height in tiles
 }
 
-func getTilePos(m *Map, tileNum int) r2.Point {
-	return r2.Point{float64((tileNum % m.width) * m.Tileset.tileWidth), float64((tileNum / m.width) * m.Tileset.tileHeight)}
+// Dispose frees the Map's rendered Image
+func (m *Map) Dispose() {
+	if m.Image != nil {
+		m.Image.Dispose()
+	}
 }
 
-// TODO: clean this up
-// helper used by both JSON and TMX Map constructors
-func getTileImageAndOpts(newMap *Map, tileNum int) (*ebiten.Image, *ebiten.DrawImageOptions) {
-	tileID := newMap.tileData[tileNum]
-	opts := &ebiten.DrawImageOptions{}
+// NewMapFromGIDs returns a Map made in code rather than loaded: width by height
+// tiles of tileWidth by tileHeight pixels, given by Tiled GIDs row by row (0 for
+// empty).  It has no tile images or rendered Image, so it suits generated levels'
+// colliders, or tests.
+func NewMapFromGIDs(width, height, tileWidth, tileHeight int, gids []uint32) (*Map, error) {
+	if err := checkTiles(width, height, len(gids)); err != nil {
+		return nil, err
+	}
+	return &Map{
+		Tileset:  &Tileset{tileWidth: tileWidth, tileHeight: tileHeight},
+		tileData: append([]uint32(nil), gids...),
+		width:    width,
+		height:   height,
+	}, nil
+}
 
-	// bits 32, 31, and 30 store whether tiles are flipped
-	localID := (tileID & 0x1FFFFFFF) - 1 // TODO: use firstID/firstgid instead of hardcoding 1
-	flipHoriz := (tileID & 0x80000000) > 0
-	flipVert := (tileID & 0x40000000) > 0
-	flipDiag := (tileID & 0x20000000) > 0
+// checkTiles returns an error unless width and height are positive and n tiles
+// fill exactly a map of that size
+func checkTiles(width, height, n int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("a map can't be %dx%d tiles", width, height)
+	}
+	if n != width*height {
+		return fmt.Errorf("%d tiles don't fill a %dx%d map", n, width, height)
+	}
+	return nil
+}
+
+// TilesetSource provides the tilesets a Map uses by their file path (found relative
+// to the map file, as Tiled writes it), e.g. to share them between maps (see LoadMap)
+type TilesetSource func(filePath string) (*Tileset, error)
 
-	// apply tile flips/rotatoin
-	opts.GeoM.Translate(-float64(newMap.Tileset.tileWidth)/2, -float64(newMap.Tileset.tileHeight)/2)
-	if flipDiag {
-		opts.GeoM = r2extra.RotatedQuarter(opts.GeoM)
+// render draws the Map's tiles onto a new Image
+func (m *Map) render() error {
+	var err error
+	m.Image, err = ebiten.NewImage(m.width*m.Tileset.tileWidth, m.height*m.Tileset.tileHeight, ebiten.FilterDefault)
+	if err != nil {
+		return err
 	}
-	if flipHoriz {
-		opts.GeoM.Scale(-1, 1)
+	for i := 0; i < len(m.tileData); i++ {
+		if img, opts := getTileImageAndOpts(m, i); img != nil {
+			m.Image.DrawImage(img, opts)
+		}
 	}
-	if flipVert {
-		opts.GeoM.Scale(1, -1)
+	return nil
+}
+
+// GetSize returns the Map's width and height in tiles
+func (m *Map) GetSize() (int, int) {
+	return m.width, m.height
+}
+
+// GetTileSize returns the width and height of the Map's tiles in pixels
+func (m *Map) GetTileSize() (int, int) {
+	if m.Tileset == nil {
+		return 0, 0
 	}
-	// translate to position relative to rest of map
-	tilePos := getTilePos(newMap, tileNum)
-	opts.GeoM.Translate(tilePos.X, tilePos.Y)
-	opts.GeoM.Translate(float64(newMap.Tileset.tileWidth)/2, float64(newMap.Tileset.tileHeight)/2)
+	return m.Tileset.tileWidth, m.Tileset.tileHeight
+}
 
-	img := newMap.Tileset.GetTileImage(int(localID))
-	return img, opts
+func getTilePos(m *Map, tileNum int) r2.Point {
+	return r2.Point{float64((tileNum % m.width) * m.Tileset.tileWidth), float64((tileNum / m.width) * m.Tileset.tileHeight)}
 }
 
-func (m *Map) addCollidersFromTiledata() {
-	for i := 0; i < len(m.tileData); i++ {
-		localID := (m.tileData[i] & 0x1FFFFFFF) - 1
-		if localID > 0 {
-			coll, err := mech.NewPolyCollider([]*r2.Point{{0.0, 0.0}, {float64(m.Tileset.tileWidth), 0.0}, {float64(m.Tileset.tileWidth), float64(m.Tileset.tileHeight)}, {0.0, float64(m.Tileset.tileHeight)}})
-			if err != nil {
-				log.Fatal(err)
-			}
-			coll.Position = getTilePos(m, i)
-			m.terrainColliders = append(m.terrainColliders, coll)
-		}
+// GetTile returns the Tileset's ID of the tile at (x, y) and how it's oriented.
+// ok is false for empty tiles and tiles outside the map.
+func (m *Map) GetTile(x, y int) (id int, o mech.Orientation, ok bool) {
+	if x < 0 || y < 0 || x >= m.width || y >= m.height {
+		return 0, o, false
+	}
+	o, gid := mech.OrientationFromGID(m.tileData[y*m.width+x])
+	if gid == 0 {
+		return 0, o, false
+	}
+	return int(gid) - 1, o, true // TODO: use firstID/firstgid instead of hardcoding 1
+}
+
+// helper used by both JSON and TMX Map constructors; the image is nil for empty tiles
+func getTileImageAndOpts(newMap *Map, tileNum int) (*ebiten.Image, *ebiten.DrawImageOptions) {
+	id, o, ok := newMap.GetTile(tileNum%newMap.width, tileNum/newMap.width)
+	if !ok {
+		return nil, nil
 	}
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM = r2extra.OrientedGeoM(o, float64(newMap.Tileset.tileWidth), float64(newMap.Tileset.tileHeight))
+	tilePos := getTilePos(newMap, tileNum)
+	opts.GeoM.Translate(tilePos.X, tilePos.Y)
+	return newMap.Tileset.GetTileImage(id), opts
 }
 
 // == JSON ========
@@ -101,62 +141,61 @@ type mapTilesetJSON struct {
 }
 
 type mapLayerJSON struct {
-	Data []uint32 // TODO: dunno if unmarshaling straight to uint32 slice will work
+	Type    string       `json:"type"` // "tilelayer" or "objectgroup" (or others, ignored)
+	Name    string       `json:"name"`
+	Data    []uint32     // TODO: dunno if unmarshaling straight to uint32 slice will work
+	Objects []objectJSON `json:"objects"`
 }
 
-// newJSONFromFile parses the given .json file into a mapJSON
-func newMapJSONFromFile(filePath string) mapJSON {
-	jsonFile, err := os.Open(filePath)
-	if err != nil {
-		log.Fatal(err)
+// firstTileLayer returns the first tile layer of the map, if it has one
+func (m mapJSON) firstTileLayer() (mapLayerJSON, bool) {
+	for _, layer := range m.Layers {
+		if layer.Type == "tilelayer" || layer.Type == "" && layer.Data != nil {
+			return layer, true
+		}
 	}
-
-	bytes, _ := ioutil.ReadAll(jsonFile)
-	var mapRaw mapJSON
-	json.Unmarshal(bytes, &mapRaw)
-
-	defer jsonFile.Close()
-	return mapRaw
+	return mapLayerJSON{}, false
 }
 
-// NewMapFromJSON returns a Map given a .json map file
-func NewMapFromJSON(filePath string) *Map {
-	var err error
-	newMap := Map{}
-	json := newMapJSONFromFile(filePath)
+// NewMapFromJSON returns a Map given a .json map file (see LoadMap)
+func NewMapFromJSON(filePath string) (*Map, error) {
+	return LoadMap(filePath, LoadTileset)
+}
 
-	newMap.width = json.Width
-	newMap.height = json.Height
+// NewMapFromJSONWith returns a Map given a .json map file, getting its tileset from
+// tilesets (see LoadMap)
+func NewMapFromJSONWith(filePath string, tilesets TilesetSource) (*Map, error) {
+	return LoadMap(filePath, tilesets)
+}
 
+// mapFromJSON converts a parsed .json map file, without its tileset or Image, and
+// returns the path of its tileset
+func mapFromJSON(json mapJSON, filePath string) (*Map, string, error) {
+	newMap := Map{width: json.Width, height: json.Height}
 	if len(json.MapTilesets) < 1 {
-		log.Fatal(fmt.Sprintf("map at %s had no tilesets", filePath))
+		return nil, "", fmt.Errorf("map at %s had no tilesets", filePath)
 	}
-	newMap.Tileset = NewTilesetFromJSON(json.MapTilesets[0].FilePath)
-
-	if len(json.Layers) < 1 {
-		log.Fatal(fmt.Sprintf("map at %s had no layers (data)", filePath))
-	}
-	newMap.tileData = json.Layers[0].Data
-
-	newMap.Image, err = ebiten.NewImage(newMap.width*newMap.Tileset.tileWidth, newMap.height*newMap.Tileset.tileHeight, ebiten.FilterDefault)
-	if err != nil {
-		log.Fatal(err)
+	tileLayer, ok := json.firstTileLayer()
+	if !ok {
+		return nil, "", fmt.Errorf("map at %s had no layers (data)", filePath)
 	}
-	for i := 0; i < len(newMap.tileData); i++ {
-		newMap.Image.DrawImage(getTileImageAndOpts(&newMap, i))
+	if err := checkTiles(json.Width, json.Height, len(tileLayer.Data)); err != nil {
+		return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
 	}
-
-	return &newMap
+	newMap.tileData = tileLayer.Data
+	newMap.ObjectGroups = objectGroupsFromJSON(json.Layers)
+	return &newMap, json.MapTilesets[0].FilePath, nil
 }
 
 // == XML (TMX) ========
 
 type mapXML struct {
-	XMLName     xml.Name        `xml:"map"`
-	MapTilesets []mapTilesetXML `xml:"tileset"`
-	Layers      []mapLayerXML   `xml:"layer"`
-	Width       string          `xml:"width,attr"`  // map width in tiles
-	Height      string          `xml:"height,attr"` // map height in tiles
+	XMLName      xml.Name         `xml:"map"`
+	MapTilesets  []mapTilesetXML  `xml:"tileset"`
+	Layers       []mapLayerXML    `xml:"layer"`
+	ObjectGroups []objectGroupXML `xml:"objectgroup"`
+	Width        string           `xml:"width,attr"`  // map width in tiles
+	Height       string           `xml:"height,attr"` // map height in tiles
 }
 
 type mapTilesetXML struct {
@@ -170,69 +209,58 @@ type mapLayerXML struct {
 	Data    string   `xml:"data"`
 }
 
-// NewTMXFromFile parses the given .tmx file into a mapXML
-func newMapTMXFromFile(filePath string) mapXML {
-	tmxFile, err := os.Open(filePath)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	bytes, _ := ioutil.ReadAll(tmxFile)
-	var mapRaw mapXML
-	xml.Unmarshal(bytes, &mapRaw)
-
-	defer tmxFile.Close()
-	return mapRaw
-}
-
 // parseIntCSV converts the data string in a TMX layer into []uint32
-func parseIntCSV(csv string) []uint32 {
+func parseIntCSV(csv string) ([]uint32, error) {
 	strs := strings.Split(csv, ",")
 	ints := make([]uint32, len(strs))
 	var fatInt uint64 // ParseUint returns uint64, cast later
 	var err error
 	for i := range ints {
-		fatInt, err = strconv.ParseUint(strs[i], 10, 32)
+		fatInt, err = strconv.ParseUint(strings.TrimSpace(strs[i]), 10, 32)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		ints[i] = uint32(fatInt)
 	}
-	return ints
+	return ints, nil
+}
+
+// NewMapFromTMX returns a Map given a .tmx map file (see LoadMap)
+func NewMapFromTMX(filePath string) (*Map, error) {
+	return LoadMap(filePath, LoadTileset)
+}
+
+// NewMapFromTMXWith returns a Map given a .tmx map file, getting its tileset from
+// tilesets (see LoadMap)
+func NewMapFromTMXWith(filePath string, tilesets TilesetSource) (*Map, error) {
+	return LoadMap(filePath, tilesets)
 }
 
-// NewMapFromTMX returns a Map given a .tmx map file
-func NewMapFromTMX(filePath string) *Map {
+// mapFromXML converts a parsed .tmx map file, without its tileset or Image, and
+// returns the path of its tileset
+func mapFromXML(tmx mapXML, filePath string) (*Map, string, error) {
 	var err error
 	newMap := Map{}
-	tmx := newMapTMXFromFile(filePath)
-
-	newMap.width, err = strconv.Atoi(tmx.Width)
-	if err != nil {
-		log.Fatal(err)
+	if newMap.width, err = strconv.Atoi(tmx.Width); err != nil {
+		return nil, "", fmt.Errorf("map at %s: width: %v", filePath, err)
 	}
-	newMap.height, err = strconv.Atoi(tmx.Height)
-	if err != nil {
-		log.Fatal(err)
+	if newMap.height, err = strconv.Atoi(tmx.Height); err != nil {
+		return nil, "", fmt.Errorf("map at %s: height: %v", filePath, err)
 	}
-
 	if len(tmx.MapTilesets) < 1 {
-		log.Fatal(fmt.Sprintf("map at %s had no tilesets", filePath))
+		return nil, "", fmt.Errorf("map at %s had no tilesets", filePath)
 	}
-	newMap.Tileset = NewTilesetFromTSX(tmx.MapTilesets[0].FilePath)
-
 	if len(tmx.Layers) < 1 {
-		log.Fatal(fmt.Sprintf("map at %s had no layers (data)", filePath))
+		return nil, "", fmt.Errorf("map at %s had no layers (data)", filePath)
 	}
-	newMap.tileData = parseIntCSV(strings.Replace(tmx.Layers[0].Data, "\n", "", -1))
-
-	newMap.Image, err = ebiten.NewImage(newMap.width*newMap.Tileset.tileWidth, newMap.height*newMap.Tileset.tileHeight, ebiten.FilterDefault)
-	if err != nil {
-		log.Fatal(err)
+	if newMap.tileData, err = parseIntCSV(strings.Replace(tmx.Layers[0].Data, "\n", "", -1)); err != nil {
+		return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
 	}
-	for i := 0; i < len(newMap.tileData); i++ {
-		newMap.Image.DrawImage(getTileImageAndOpts(&newMap, i))
+	if err := checkTiles(newMap.width, newMap.height, len(newMap.tileData)); err != nil {
+		return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
 	}
-
-	return &newMap
+	if newMap.ObjectGroups, err = objectGroupsFromXML(tmx.ObjectGroups); err != nil {
+		return nil, "", fmt.Errorf("map at %s: %v", filePath, err)
+	}
+	return &newMap, tmx.MapTilesets[0].FilePath, nil
 }
@@ -0,0 +1,62 @@
+package tiled
+
+import (
+	"testing"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+func Test_MapGetTile(t *testing.T) {
+	m := newTestMap("#.", "##")
+	m.tileData[2] = 5 | mech.Rotation(1).GIDFlags()
+	m.tileData[1] = mech.Orientation{VertFlip: true}.GIDFlags() // flipped, but still empty
+	cases := []struct {
+		name   string
+		x, y   int
+		id     int
+		o      mech.Orientation
+		wantOK bool
+	}{
+		{"plain", 0, 0, 0, mech.Orientation{}, true},
+		{"empty", 1, 0, 0, mech.Orientation{}, false},
+		{"turned", 0, 1, 4, mech.Rotation(1), true},
+		{"outside", 2, 0, 0, mech.Orientation{}, false},
+	}
+	for _, c := range cases {
+		id, o, ok := m.GetTile(c.x, c.y)
+		if ok != c.wantOK || (ok && (id != c.id || o != c.o)) {
+			t.Errorf("Map.GetTile (%s): expected %d, %v, %v, got %d, %v, %v", c.name, c.id, c.o, c.wantOK, id, o, ok)
+		}
+	}
+}
+
+func Test_MapMalformed(t *testing.T) {
+	tileset := []mapTilesetXML{{FilePath: "tiles.tsx"}}
+	tmxCases := []struct {
+		name string
+		tmx  mapXML
+	}{
+		{"short data", mapXML{MapTilesets: tileset, Layers: []mapLayerXML{{Data: "1,2,3"}}, Width: "2", Height: "2"}},
+		{"long data", mapXML{MapTilesets: tileset, Layers: []mapLayerXML{{Data: "1,2,3,4,5"}}, Width: "2", Height: "2"}},
+		{"no width", mapXML{MapTilesets: tileset, Layers: []mapLayerXML{{Data: "1"}}, Width: "0", Height: "1"}},
+	}
+	for _, c := range tmxCases {
+		if m, _, err := mapFromXML(c.tmx, "level.tmx"); err == nil {
+			t.Errorf("mapFromXML (%s): expected an error, got %v", c.name, m)
+		}
+	}
+
+	layer := func(data ...uint32) []mapLayerJSON { return []mapLayerJSON{{Type: "tilelayer", Data: data}} }
+	jsonCases := []struct {
+		name string
+		json mapJSON
+	}{
+		{"short data", mapJSON{MapTilesets: []mapTilesetJSON{{}}, Layers: layer(1, 2, 3), Width: 2, Height: 2}},
+		{"no width", mapJSON{MapTilesets: []mapTilesetJSON{{}}, Layers: layer(), Height: 2}},
+	}
+	for _, c := range jsonCases {
+		if m, _, err := mapFromJSON(c.json, "level.json"); err == nil {
+			t.Errorf("mapFromJSON (%s): expected an error, got %v", c.name, m)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package tiled
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+// Tiled objects: https://doc.mapeditor.org/en/stable/manual/objects/
+
+// ObjectGroup is an object layer of a Map
+type ObjectGroup struct {
+	Name    string
+	Objects []Object
+}
+
+// Object is a shape placed on an object layer.  Coordinates are in pixels, as
+// for the Map's tiles.
+type Object struct {
+	ID       int
+	Name     string
+	Type     string   // "class" in newer versions of Tiled
+	Position r2.Point // top left corner, or what Polyline and Polygon are relative to
+	Width    float64
+	Height   float64
+	Rotation float64    // degrees clockwise about Position
+	Polyline []r2.Point // relative to Position, before Rotation
+	Polygon  []r2.Point
+	// Properties holds custom properties as text (object references as their ID)
+	Properties map[string]string
+}
+
+// Points returns the points of a polyline or polygon Object in level space
+func (o *Object) Points() []r2.Point {
+	points := o.Polyline
+	if points == nil {
+		points = o.Polygon
+	}
+	transform := mech.Transform{Position: o.Position, Angle: o.Rotation * math.Pi / 180}
+	placed := make([]r2.Point, len(points))
+	for i, p := range points {
+		placed[i] = transform.Apply(p)
+	}
+	return placed
+}
+
+// Outline returns the corners of a polygon or rectangle Object in level space,
+// going round it, or nil for any other Object.  Ellipses are outlined by their
+// bounding rectangle.
+func (o *Object) Outline() []r2.Point {
+	switch {
+	case o.Polygon != nil:
+		return o.Points()
+	case o.Polyline != nil || o.Width <= 0 || o.Height <= 0:
+		return nil
+	}
+	transform := mech.Transform{Position: o.Position, Angle: o.Rotation * math.Pi / 180}
+	return []r2.Point{
+		transform.Apply(r2.Point{0, 0}),
+		transform.Apply(r2.Point{o.Width, 0}),
+		transform.Apply(r2.Point{o.Width, o.Height}),
+		transform.Apply(r2.Point{0, o.Height}),
+	}
+}
+
+// floatProperty returns the named property of o as a number, or def if it isn't set
+func (o *Object) floatProperty(name string, def float64) (float64, error) {
+	value, ok := o.Properties[name]
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("object %d property %s: %v", o.ID, name, err)
+	}
+	return f, nil
+}
+
+// Object returns the object with the given ID, or nil if there isn't one
+func (m *Map) Object(id int) *Object {
+	for i := range m.ObjectGroups {
+		for j := range m.ObjectGroups[i].Objects {
+			if obj := &m.ObjectGroups[i].Objects[j]; obj.ID == id {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// pathModes are the values of a platform's "mode" property
+var pathModes = map[string]mech.PathMode{
+	"pingpong": mech.PingPong,
+	"loop":     mech.Loop,
+	"once":     mech.Once,
+}
+
+// Platforms returns a mech.Platform for each rectangle object of type "platform":
+// a Kinematic box whose centre follows the polyline or polygon object named by its
+// "path" property (an object reference), at "speed" pixels per second.
+// Its "mode" may be "pingpong" (the default for polylines), "loop" (the default
+// for polygons) or "once", and "surfaceSpeed" makes it a conveyor belt.
+// A platform without a path stays where it is, which suits conveyor belts.
+func (m *Map) Platforms() ([]*mech.Platform, error) {
+	var platforms []*mech.Platform
+	for _, group := range m.ObjectGroups {
+		for i := range group.Objects {
+			obj := &group.Objects[i]
+			if obj.Type != "platform" {
+				continue
+			}
+			platform, err := m.platform(obj)
+			if err != nil {
+				return nil, err
+			}
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms, nil
+}
+
+// platform builds the Platform for obj (see Platforms)
+func (m *Map) platform(obj *Object) (*mech.Platform, error) {
+	w, h := obj.Width/2, obj.Height/2
+	coll, err := mech.NewPolyCollider([]*r2.Point{{-w, -h}, {w, -h}, {w, h}, {-w, h}})
+	if err != nil {
+		return nil, fmt.Errorf("platform %d: %v", obj.ID, err)
+	}
+	coll.Kind = mech.Kinematic
+	coll.Angle = obj.Rotation * math.Pi / 180
+	coll.Position = mech.Transform{Position: obj.Position, Angle: coll.Angle}.Apply(r2.Point{w, h})
+	if coll.SurfaceSpeed, err = obj.floatProperty("surfaceSpeed", 0); err != nil {
+		return nil, err
+	}
+	speed, err := obj.floatProperty("speed", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, ok := obj.Properties["path"]
+	if !ok {
+		return mech.NewPlatform(coll, nil, mech.PingPong, speed), nil
+	}
+	id, err := strconv.Atoi(ref)
+	pathObj := m.Object(id)
+	if err != nil || pathObj == nil || (pathObj.Polyline == nil && pathObj.Polygon == nil) {
+		return nil, fmt.Errorf("platform %d: path %q isn't a polyline or polygon object", obj.ID, ref)
+	}
+	mode := mech.PingPong
+	if pathObj.Polygon != nil {
+		mode = mech.Loop
+	}
+	if name, ok := obj.Properties["mode"]; ok {
+		if mode, ok = pathModes[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("platform %d: unknown mode %q", obj.ID, name)
+		}
+	}
+	return mech.NewPlatform(coll, pathObj.Points(), mode, speed), nil
+}
+
+// == JSON ========
+
+type objectJSON struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	Polyline   []pointJSON    `json:"polyline"`
+	Polygon    []pointJSON    `json:"polygon"`
+	Properties []propertyJSON `json:"properties"`
+}
+
+type pointJSON struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type propertyJSON struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// objectGroupsFromJSON converts the object layers among layers
+func objectGroupsFromJSON(layers []mapLayerJSON) []ObjectGroup {
+	var groups []ObjectGroup
+	for _, layer := range layers {
+		if layer.Type != "objectgroup" {
+			continue
+		}
+		group := ObjectGroup{Name: layer.Name}
+		for _, raw := range layer.Objects {
+			group.Objects = append(group.Objects, objectFromJSON(raw))
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func objectFromJSON(raw objectJSON) Object {
+	obj := Object{
+		ID:         raw.ID,
+		Name:       raw.Name,
+		Type:       raw.Type,
+		Position:   r2.Point{raw.X, raw.Y},
+		Width:      raw.Width,
+		Height:     raw.Height,
+		Rotation:   raw.Rotation,
+		Polyline:   pointsFromJSON(raw.Polyline),
+		Polygon:    pointsFromJSON(raw.Polygon),
+		Properties: propertiesFromJSON(raw.Properties),
+	}
+	if obj.Type == "" {
+		obj.Type = raw.Class
+	}
+	return obj
+}
+
+// propertiesFromJSON converts custom properties to text
+func propertiesFromJSON(raw []propertyJSON) map[string]string {
+	properties := make(map[string]string)
+	for _, prop := range raw {
+		properties[prop.Name] = fmt.Sprint(prop.Value)
+	}
+	return properties
+}
+
+func pointsFromJSON(raw []pointJSON) []r2.Point {
+	if raw == nil {
+		return nil
+	}
+	points := make([]r2.Point, len(raw))
+	for i, p := range raw {
+		points[i] = r2.Point{p.X, p.Y}
+	}
+	return points
+}
+
+// == XML (TMX) ========
+
+type objectGroupXML struct {
+	Name    string      `xml:"name,attr"`
+	Objects []objectXML `xml:"object"`
+}
+
+type objectXML struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	Polyline   *pointsXML    `xml:"polyline"`
+	Polygon    *pointsXML    `xml:"polygon"`
+	Properties []propertyXML `xml:"properties>property"`
+}
+
+type pointsXML struct {
+	Points string `xml:"points,attr"` // e.g. "0,0 32,0 32,16"
+}
+
+type propertyXML struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"` // multiline strings are kept here instead
+}
+
+// objectGroupsFromXML converts TMX object layers
+func objectGroupsFromXML(layers []objectGroupXML) ([]ObjectGroup, error) {
+	var groups []ObjectGroup
+	for _, layer := range layers {
+		group := ObjectGroup{Name: layer.Name}
+		for _, raw := range layer.Objects {
+			obj, err := objectFromXML(raw)
+			if err != nil {
+				return nil, err
+			}
+			group.Objects = append(group.Objects, obj)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func objectFromXML(raw objectXML) (Object, error) {
+	obj := Object{
+		ID:         raw.ID,
+		Name:       raw.Name,
+		Type:       raw.Type,
+		Position:   r2.Point{raw.X, raw.Y},
+		Width:      raw.Width,
+		Height:     raw.Height,
+		Rotation:   raw.Rotation,
+		Properties: propertiesFromXML(raw.Properties),
+	}
+	if obj.Type == "" {
+		obj.Type = raw.Class
+	}
+	var err error
+	if obj.Polyline, err = pointsFromXML(raw.Polyline); err != nil {
+		return obj, fmt.Errorf("object %d polyline: %v", raw.ID, err)
+	}
+	if obj.Polygon, err = pointsFromXML(raw.Polygon); err != nil {
+		return obj, fmt.Errorf("object %d polygon: %v", raw.ID, err)
+	}
+	return obj, nil
+}
+
+// propertiesFromXML converts custom properties to text
+func propertiesFromXML(raw []propertyXML) map[string]string {
+	properties := make(map[string]string)
+	for _, prop := range raw {
+		if prop.Value == "" {
+			prop.Value = prop.Text
+		}
+		properties[prop.Name] = prop.Value
+	}
+	return properties
+}
+
+// pointsFromXML parses the points of a TMX polyline or polygon
+func pointsFromXML(raw *pointsXML) ([]r2.Point, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var points []r2.Point
+	for _, pair := range strings.Fields(raw.Points) {
+		coords := strings.Split(pair, ",")
+		if len(coords) != 2 {
+			return nil, fmt.Errorf("bad point %q", pair)
+		}
+		x, err := strconv.ParseFloat(coords[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(coords[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, r2.Point{x, y})
+	}
+	return points, nil
+}
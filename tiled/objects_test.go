@@ -0,0 +1,101 @@
+package tiled
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"github.com/jwlarocque/engine/mech"
+)
+
+const objectsJSON = `{"layers": [
+	{"type": "objectgroup", "name": "platforms", "objects": [
+		{"id": 1, "type": "platform", "x": 32, "y": 64, "width": 48, "height": 16,
+		 "properties": [{"name": "path", "type": "object", "value": 2}, {"name": "speed", "type": "float", "value": 40}]},
+		{"id": 2, "name": "route", "x": 56, "y": 72, "polyline": [{"x": 0, "y": 0}, {"x": 0, "y": -64}]},
+		{"id": 3, "class": "platform", "x": 0, "y": 128, "width": 64, "height": 16, "rotation": 90,
+		 "properties": [{"name": "surfaceSpeed", "type": "float", "value": -20}]}
+	]},
+	{"type": "tilelayer", "data": [0, 1]}
+]}`
+
+const objectsTMX = `<map width="2" height="1">
+	<layer><data>0,1</data></layer>
+	<objectgroup name="platforms">
+		<object id="1" type="platform" x="32" y="64" width="48" height="16">
+			<properties>
+				<property name="path" type="object" value="2"/>
+				<property name="speed" type="float" value="40"/>
+			</properties>
+		</object>
+		<object id="2" name="route" x="56" y="72"><polyline points="0,0 0,-64"/></object>
+		<object id="3" class="platform" x="0" y="128" width="64" height="16" rotation="90">
+			<properties><property name="surfaceSpeed" type="float" value="-20"/></properties>
+		</object>
+	</objectgroup>
+</map>`
+
+func Test_ObjectGroups(t *testing.T) {
+	var rawJSON mapJSON
+	if err := json.Unmarshal([]byte(objectsJSON), &rawJSON); err != nil {
+		t.Fatal(err)
+	}
+	var rawXML mapXML
+	if err := xml.Unmarshal([]byte(objectsTMX), &rawXML); err != nil {
+		t.Fatal(err)
+	}
+	fromXML, err := objectGroupsFromXML(rawXML.ObjectGroups)
+	if err != nil {
+		t.Fatalf("objectGroupsFromXML: expected no error, got %v", err)
+	}
+	if layer, ok := rawJSON.firstTileLayer(); !ok || len(layer.Data) != 2 {
+		t.Errorf("firstTileLayer: expected the tile layer after the object layer, got %v", layer)
+	}
+
+	for format, groups := range map[string][]ObjectGroup{"JSON": objectGroupsFromJSON(rawJSON.Layers), "TMX": fromXML} {
+		if len(groups) != 1 || len(groups[0].Objects) != 3 || groups[0].Name != "platforms" {
+			t.Fatalf("ObjectGroups (%s): expected one layer of 3 objects, got %v", format, groups)
+		}
+		m := &Map{ObjectGroups: groups}
+		route := m.Object(2)
+		if route == nil || route.Name != "route" {
+			t.Fatalf("Map.Object (%s): expected the route, got %v", format, route)
+		}
+		if points := route.Points(); len(points) != 2 || points[1] != (r2.Point{56, 8}) {
+			t.Errorf("Object.Points (%s): expected route from (56, 72) to (56, 8), got %v", format, points)
+		}
+
+		platforms, err := m.Platforms()
+		if err != nil || len(platforms) != 2 {
+			t.Fatalf("Map.Platforms (%s): expected 2 platforms, got %v (%v)", format, len(platforms), err)
+		}
+		lift := platforms[0]
+		if lift.Speed != 40 || lift.Mode != mech.PingPong || len(lift.Path) != 2 || lift.Collider.GetFixture().Position != (r2.Point{56, 72}) {
+			t.Errorf("Map.Platforms (%s): expected lift centred on the start of its route, got %+v", format, lift)
+		}
+		belt := platforms[1].Collider.GetFixture()
+		if belt.SurfaceSpeed != -20 || belt.Kind != mech.Kinematic || math.Abs(belt.Angle-math.Pi/2) > 1e-12 {
+			t.Errorf("Map.Platforms (%s): expected a standing conveyor belt, got %+v", format, belt.Body)
+		}
+		// turned about its top left corner, it hangs down and to the left of it
+		if min, max := platforms[1].Collider.Bounds(); math.Abs(min.X+16) > 1e-9 || math.Abs(max.Y-192) > 1e-9 {
+			t.Errorf("Map.Platforms (%s): expected belt from x = -16 down to y = 192, got %v to %v", format, min, max)
+		}
+	}
+}
+
+func Test_PlatformsBadPath(t *testing.T) {
+	m := &Map{ObjectGroups: []ObjectGroup{{Objects: []Object{
+		{ID: 1, Type: "platform", Width: 16, Height: 16, Properties: map[string]string{"path": "7"}},
+	}}}}
+	if _, err := m.Platforms(); err == nil {
+		t.Errorf("Map.Platforms (missing path): expected an error")
+	}
+	m.ObjectGroups[0].Objects[0].Properties = map[string]string{"speed": "fast"}
+	if _, err := m.Platforms(); err == nil {
+		t.Errorf("Map.Platforms (bad speed): expected an error")
+	}
+}
@@ -1,28 +1,39 @@
 package tiled
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"image"
-	"io/ioutil"
-	"log"
-	"os"
 	"strconv"
+	"time"
 
 	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
-)
 
-// TODO: construct file paths relative to current file instead of main.go
+	"github.com/jwlarocque/engine/atlas"
+)
 
 // Tileset provides tile images, usually to a Map
 type Tileset struct {
 	tilesImage *ebiten.Image
+	imagePath  string
 	tileWidth  int
 	tileHeight int
 	numTiles   int
 	numCols    int
+	// Animations holds the frames of each animated tile, by local tile ID
+	Animations map[int][]TileFrame
+	// Collisions holds the objects drawn on tiles in Tiled's collision editor,
+	// relative to the tile's top left corner, by local tile ID
+	Collisions map[int][]Object
+	// Properties holds tiles' custom properties as text, by local tile ID
+	Properties map[int]map[string]string
+}
+
+// TileFrame is one frame of an animated tile: another tile of the same
+// Tileset, shown for Duration
+type TileFrame struct {
+	TileID   int // local tile ID
+	Duration time.Duration
 }
 
 // GetTileImage takes a tile ID and returns the corresponding ebiten.Image
@@ -32,9 +43,49 @@ type Tileset struct {
 //       the conversion.
 // TODO: consider returning render opts? (would probably require global ID)
 func (ts Tileset) GetTileImage(localTileID int) *ebiten.Image {
-	subX := (localTileID % ts.numCols) * ts.tileWidth
-	subY := (localTileID / ts.numCols) * ts.tileHeight
-	return ts.tilesImage.SubImage(image.Rect(subX, subY, subX+ts.tileWidth, subY+ts.tileWidth)).(*ebiten.Image)
+	origin := ts.tilesImage.Bounds().Min // not 0, 0 for an atlas sub-image
+	subX := origin.X + (localTileID%ts.numCols)*ts.tileWidth
+	subY := origin.Y + (localTileID/ts.numCols)*ts.tileHeight
+	return ts.tilesImage.SubImage(image.Rect(subX, subY, subX+ts.tileWidth, subY+ts.tileHeight)).(*ebiten.Image)
+}
+
+// Dispose frees the tileset's image
+func (ts *Tileset) Dispose() {
+	if ts.tilesImage != nil {
+		ts.tilesImage.Dispose()
+	}
+}
+
+// GetImagePath returns the path of the tileset's image file
+func (ts *Tileset) GetImagePath() string {
+	return ts.imagePath
+}
+
+// UseAtlas swaps the tileset's own image for the one packed in a under name
+// (usually its image path, see atlas.Builder.AddFile), and frees its own
+func (ts *Tileset) UseAtlas(a *atlas.Atlas, name string) error {
+	img := a.Image(name)
+	if img == nil {
+		return fmt.Errorf("atlas has no image %q", name)
+	}
+	if img.Bounds().Size() != ts.tilesImage.Bounds().Size() {
+		return fmt.Errorf("atlas image %q is %v, but the tileset image is %v", name, img.Bounds().Size(), ts.tilesImage.Bounds().Size())
+	}
+	ts.tilesImage.Dispose()
+	ts.tilesImage = img
+	return nil
+}
+
+// checkGrid returns an error unless the tileset's tiles have a positive size and
+// it has at least one column of them, so tiles can be cut from its image
+func (ts *Tileset) checkGrid() error {
+	if ts.tileWidth <= 0 || ts.tileHeight <= 0 {
+		return fmt.Errorf("tiles can't be %dx%d pixels", ts.tileWidth, ts.tileHeight)
+	}
+	if ts.numCols <= 0 {
+		return fmt.Errorf("a tileset can't have %d columns", ts.numCols)
+	}
+	return nil
 }
 
 // == JSON ========
@@ -42,50 +93,83 @@ func (ts Tileset) GetTileImage(localTileID int) *ebiten.Image {
 type tilesetJSON struct {
 	Name       string
 	Image      string
-	TileHeight int `json:"tileheight"`
-	TileWidth  int `json:"tilewidth"`
-	NumTiles   int `json:"tilecount"`
-	NumCols    int `json:"columns"`
+	TileHeight int        `json:"tileheight"`
+	TileWidth  int        `json:"tilewidth"`
+	NumTiles   int        `json:"tilecount"`
+	NumCols    int        `json:"columns"`
+	Tiles      []tileJSON `json:"tiles"`
 }
 
-// newTilesetJSONFromFile unmarshals the given .json tileset file into a tilesetJSON
-func newTilesetJSONFromFile(filePath string) tilesetJSON {
-	jsonFile, err := os.Open(filePath)
-	if err != nil {
-		log.Println("err loading json tileset file")
-		log.Fatal(err)
-	}
-	bytes, _ := ioutil.ReadAll(jsonFile)
-
-	var tileset tilesetJSON
-	json.Unmarshal(bytes, &tileset)
+type tileJSON struct {
+	ID          int             `json:"id"`
+	Animation   []tileFrameJSON `json:"animation"`
+	ObjectGroup *struct {
+		Objects []objectJSON `json:"objects"`
+	} `json:"objectgroup"`
+	Properties []propertyJSON `json:"properties"`
+}
 
-	defer jsonFile.Close()
-	return tileset
+type tileFrameJSON struct {
+	TileID   int `json:"tileid"`
+	Duration int `json:"duration"` // milliseconds
 }
 
-// NewTilesetFromJSON returns a Tileset from the given Tiled .json tileset file
-func NewTilesetFromJSON(filePath string) *Tileset {
-	tileset := Tileset{}
+// animationsFromJSON collects the animated tiles among tiles
+func animationsFromJSON(tiles []tileJSON) map[int][]TileFrame {
+	animations := make(map[int][]TileFrame)
+	for _, tile := range tiles {
+		for _, frame := range tile.Animation {
+			animations[tile.ID] = append(animations[tile.ID], TileFrame{frame.TileID, time.Duration(frame.Duration) * time.Millisecond})
+		}
+	}
+	return animations
+}
 
-	json := newTilesetJSONFromFile(filePath)
+// collisionsFromJSON collects the collision objects of tiles
+func collisionsFromJSON(tiles []tileJSON) map[int][]Object {
+	collisions := make(map[int][]Object)
+	for _, tile := range tiles {
+		if tile.ObjectGroup == nil {
+			continue
+		}
+		for _, raw := range tile.ObjectGroup.Objects {
+			collisions[tile.ID] = append(collisions[tile.ID], objectFromJSON(raw))
+		}
+	}
+	return collisions
+}
 
-	var err error
-	tileset.tilesImage, _, err = ebitenutil.NewImageFromFile(json.Image, ebiten.FilterDefault)
-	if err != nil {
-		log.Println(filePath)
-		log.Println(json.Name)
-		log.Println(json.Image)
-		log.Println("err loading tileset into ebiten image")
-		log.Fatal(err)
+// tilePropertiesFromJSON collects the custom properties of tiles
+func tilePropertiesFromJSON(tiles []tileJSON) map[int]map[string]string {
+	properties := make(map[int]map[string]string)
+	for _, tile := range tiles {
+		if len(tile.Properties) > 0 {
+			properties[tile.ID] = propertiesFromJSON(tile.Properties)
+		}
 	}
+	return properties
+}
+
+// NewTilesetFromJSON returns a Tileset from the given Tiled .json tileset file (see
+// LoadTileset)
+func NewTilesetFromJSON(filePath string) (*Tileset, error) {
+	return LoadTileset(filePath)
+}
 
+// tilesetFromJSON fills in tileset (all but its image) from a parsed .json tileset file
+func tilesetFromJSON(tileset *Tileset, json tilesetJSON, filePath string) error {
+	tileset.imagePath = json.Image
 	tileset.tileHeight = json.TileHeight
 	tileset.tileWidth = json.TileWidth
 	tileset.numTiles = json.NumTiles
 	tileset.numCols = json.NumCols
-
-	return &tileset
+	tileset.Animations = animationsFromJSON(json.Tiles)
+	tileset.Collisions = collisionsFromJSON(json.Tiles)
+	tileset.Properties = tilePropertiesFromJSON(json.Tiles)
+	if err := tileset.checkGrid(); err != nil {
+		return fmt.Errorf("tileset at %s: %v", filePath, err)
+	}
+	return nil
 }
 
 // == TSX ========
@@ -96,61 +180,96 @@ type tilesetXML struct {
 	TileHeight string     `xml:"tileheight,attr"`
 	NumTiles   string     `xml:"tilecount,attr"`
 	NumCols    string     `xml:"columns,attr"`
+	Tiles      []tileXML  `xml:"tile"`
 }
 
-type imageXML struct {
-	XMLName  xml.Name `xml:"image"`
-	FilePath string   `xml:"source,attr"`
+type tileXML struct {
+	ID         int            `xml:"id,attr"`
+	Frames     []tileFrameXML `xml:"animation>frame"`
+	Objects    []objectXML    `xml:"objectgroup>object"`
+	Properties []propertyXML  `xml:"properties>property"`
+}
+
+type tileFrameXML struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"` // milliseconds
 }
 
-// newTSXFromFile unmarshals the given .tsx file into a tilsetXML
-func newTSXFromFile(filePath string) tilesetXML {
-	tsxFile, err := os.Open(filePath)
-	if err != nil {
-		log.Fatal(err)
+// animationsFromXML collects the animated tiles among tiles
+func animationsFromXML(tiles []tileXML) map[int][]TileFrame {
+	animations := make(map[int][]TileFrame)
+	for _, tile := range tiles {
+		for _, frame := range tile.Frames {
+			animations[tile.ID] = append(animations[tile.ID], TileFrame{frame.TileID, time.Duration(frame.Duration) * time.Millisecond})
+		}
 	}
-	bytes, _ := ioutil.ReadAll(tsxFile)
+	return animations
+}
 
-	var tileset tilesetXML
-	xml.Unmarshal(bytes, &tileset)
+// collisionsFromXML collects the collision objects of tiles
+func collisionsFromXML(tiles []tileXML) (map[int][]Object, error) {
+	collisions := make(map[int][]Object)
+	for _, tile := range tiles {
+		for _, raw := range tile.Objects {
+			obj, err := objectFromXML(raw)
+			if err != nil {
+				return nil, fmt.Errorf("tile %d collision: %v", tile.ID, err)
+			}
+			collisions[tile.ID] = append(collisions[tile.ID], obj)
+		}
+	}
+	return collisions, nil
+}
 
-	defer tsxFile.Close()
-	return tileset
+// tilePropertiesFromXML collects the custom properties of tiles
+func tilePropertiesFromXML(tiles []tileXML) map[int]map[string]string {
+	properties := make(map[int]map[string]string)
+	for _, tile := range tiles {
+		if len(tile.Properties) > 0 {
+			properties[tile.ID] = propertiesFromXML(tile.Properties)
+		}
+	}
+	return properties
 }
 
-// NewTilesetFromTSX creates a tileset from a .tsx file
-func NewTilesetFromTSX(filePath string) *Tileset {
-	tileset := Tileset{}
+type imageXML struct {
+	XMLName  xml.Name `xml:"image"`
+	FilePath string   `xml:"source,attr"`
+}
 
-	tsx := newTSXFromFile(filePath)
+// NewTilesetFromTSX creates a tileset from a .tsx file (see LoadTileset)
+func NewTilesetFromTSX(filePath string) (*Tileset, error) {
+	return LoadTileset(filePath)
+}
 
+// tilesetFromXML fills in tileset (all but its image) from a parsed .tsx file
+func tilesetFromXML(tileset *Tileset, tsx tilesetXML, filePath string) error {
 	if len(tsx.Images) < 1 {
-		log.Fatal(fmt.Sprintf("TSX XML at %s had no image", filePath))
+		return fmt.Errorf("TSX XML at %s had no image", filePath)
 	}
-
-	// TODO: reduce repeated code
+	tileset.imagePath = tsx.Images[0].FilePath
 	var err error
-	tileset.tilesImage, _, err = ebitenutil.NewImageFromFile(tsx.Images[0].FilePath, ebiten.FilterDefault)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	tileset.tileWidth, err = strconv.Atoi(tsx.TileWidth)
-	if err != nil {
-		log.Fatal(err)
-	}
-	tileset.tileHeight, err = strconv.Atoi(tsx.TileHeight)
-	if err != nil {
-		log.Fatal(err)
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   *int
+	}{
+		{"tilewidth", tsx.TileWidth, &tileset.tileWidth},
+		{"tileheight", tsx.TileHeight, &tileset.tileHeight},
+		{"tilecount", tsx.NumTiles, &tileset.numTiles},
+		{"columns", tsx.NumCols, &tileset.numCols},
+	} {
+		if *field.dst, err = strconv.Atoi(field.value); err != nil {
+			return fmt.Errorf("TSX XML at %s: %s: %v", filePath, field.name, err)
+		}
 	}
-	tileset.numTiles, err = strconv.Atoi(tsx.NumTiles)
-	if err != nil {
-		log.Fatal(err)
+	if err := tileset.checkGrid(); err != nil {
+		return fmt.Errorf("TSX XML at %s: %v", filePath, err)
 	}
-	tileset.numCols, err = strconv.Atoi(tsx.NumCols)
-	if err != nil {
-		log.Fatal(err)
+	tileset.Animations = animationsFromXML(tsx.Tiles)
+	if tileset.Collisions, err = collisionsFromXML(tsx.Tiles); err != nil {
+		return fmt.Errorf("TSX XML at %s: %v", filePath, err)
 	}
-
-	return &tileset
+	tileset.Properties = tilePropertiesFromXML(tsx.Tiles)
+	return nil
 }
@@ -0,0 +1,138 @@
+package tiled
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r2"
+)
+
+const animatedJSON = `{"name": "water", "tilewidth": 16, "tileheight": 16, "tiles": [
+	{"id": 2, "animation": [{"tileid": 2, "duration": 100}, {"tileid": 3, "duration": 150}]},
+	{"id": 5}
+]}`
+
+const animatedTSX = `<tileset tilewidth="16" tileheight="16">
+	<tile id="2">
+		<animation>
+			<frame tileid="2" duration="100"/>
+			<frame tileid="3" duration="150"/>
+		</animation>
+	</tile>
+	<tile id="5"/>
+</tileset>`
+
+func Test_TileAnimations(t *testing.T) {
+	var rawJSON tilesetJSON
+	if err := json.Unmarshal([]byte(animatedJSON), &rawJSON); err != nil {
+		t.Fatal(err)
+	}
+	var rawXML tilesetXML
+	if err := xml.Unmarshal([]byte(animatedTSX), &rawXML); err != nil {
+		t.Fatal(err)
+	}
+	for format, animations := range map[string]map[int][]TileFrame{"JSON": animationsFromJSON(rawJSON.Tiles), "TSX": animationsFromXML(rawXML.Tiles)} {
+		if len(animations) != 1 {
+			t.Errorf("Tileset.Animations (%s): expected one animated tile, got %v", format, animations)
+		}
+		water := animations[2]
+		if len(water) != 2 || water[1] != (TileFrame{3, 150 * time.Millisecond}) {
+			t.Errorf("Tileset.Animations (%s): expected tiles 2 and 3, got %v", format, water)
+		}
+	}
+}
+
+const collidingJSON = `{"tiles": [
+	{"id": 4, "objectgroup": {"objects": [
+		{"id": 1, "name": "hurtbox", "x": 2, "y": 3, "width": 10, "height": 12},
+		{"id": 2, "name": "hitbox", "x": 8, "y": 4, "polygon": [{"x": 0, "y": 0}, {"x": 6, "y": 0}, {"x": 6, "y": 4}]}
+	]}, "properties": [{"name": "events", "type": "string", "value": "footstep"}]}
+]}`
+
+const collidingTSX = `<tileset>
+	<tile id="4">
+		<properties>
+			<property name="events" value="footstep"/>
+		</properties>
+		<objectgroup>
+			<object id="1" name="hurtbox" x="2" y="3" width="10" height="12"/>
+			<object id="2" name="hitbox" x="8" y="4"><polygon points="0,0 6,0 6,4"/></object>
+		</objectgroup>
+	</tile>
+</tileset>`
+
+func Test_TileCollisions(t *testing.T) {
+	var rawJSON tilesetJSON
+	if err := json.Unmarshal([]byte(collidingJSON), &rawJSON); err != nil {
+		t.Fatal(err)
+	}
+	var rawXML tilesetXML
+	if err := xml.Unmarshal([]byte(collidingTSX), &rawXML); err != nil {
+		t.Fatal(err)
+	}
+	collisionsXML, err := collisionsFromXML(rawXML.Tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formats := map[string]struct {
+		collisions map[int][]Object
+		properties map[int]map[string]string
+	}{
+		"JSON": {collisionsFromJSON(rawJSON.Tiles), tilePropertiesFromJSON(rawJSON.Tiles)},
+		"TSX":  {collisionsXML, tilePropertiesFromXML(rawXML.Tiles)},
+	}
+	for format, tiles := range formats {
+		objects := tiles.collisions[4]
+		if len(tiles.collisions) != 1 || len(objects) != 2 {
+			t.Errorf("Tileset.Collisions (%s): expected two objects on tile 4, got %v", format, tiles.collisions)
+			continue
+		}
+		box := objects[0].Outline()
+		if len(box) != 4 || box[0] != (r2.Point{2, 3}) || box[2] != (r2.Point{12, 15}) {
+			t.Errorf("Tileset.Collisions (%s): expected a box from (2, 3) to (12, 15), got %v", format, box)
+		}
+		if triangle := objects[1].Outline(); objects[1].Name != "hitbox" || len(triangle) != 3 || triangle[2] != (r2.Point{14, 8}) {
+			t.Errorf("Tileset.Collisions (%s): expected a hitbox triangle, got %v", format, objects[1])
+		}
+		if events := tiles.properties[4]["events"]; events != "footstep" {
+			t.Errorf("Tileset.Properties (%s): expected events footstep, got %q", format, events)
+		}
+	}
+}
+
+func Test_TilesetMalformed(t *testing.T) {
+	jsonCases := map[string]string{
+		"no columns":    `{"image": "tiles.png", "tilewidth": 16, "tileheight": 16}`,
+		"no tile width": `{"image": "tiles.png", "columns": 4, "tileheight": 16}`,
+		"no height":     `{"image": "tiles.png", "columns": 4, "tilewidth": 16, "tileheight": 0}`,
+	}
+	for name, data := range jsonCases {
+		var raw tilesetJSON
+		if err := json.Unmarshal([]byte(data), &raw); err != nil {
+			t.Fatal(err)
+		}
+		if err := tilesetFromJSON(&Tileset{}, raw, "tiles.json"); err == nil {
+			t.Errorf("tilesetFromJSON (%s): expected an error", name)
+		}
+	}
+	tsxCases := map[string]string{
+		"no columns":    `<tileset tilewidth="16" tileheight="16" tilecount="4" columns="0"><image source="tiles.png"/></tileset>`,
+		"negative size": `<tileset tilewidth="-16" tileheight="16" tilecount="4" columns="2"><image source="tiles.png"/></tileset>`,
+	}
+	for name, data := range tsxCases {
+		var raw tilesetXML
+		if err := xml.Unmarshal([]byte(data), &raw); err != nil {
+			t.Fatal(err)
+		}
+		if err := tilesetFromXML(&Tileset{}, raw, "tiles.tsx"); err == nil {
+			t.Errorf("tilesetFromXML (%s): expected an error", name)
+		}
+	}
+	var raw tilesetXML
+	xml.Unmarshal([]byte(`<tileset tilewidth="16" tileheight="8" tilecount="4" columns="2"><image source="tiles.png"/></tileset>`), &raw)
+	if err := tilesetFromXML(&Tileset{}, raw, "tiles.tsx"); err != nil {
+		t.Errorf("tilesetFromXML (2 columns of 16x8 tiles): expected no error, got %v", err)
+	}
+}